@@ -1,65 +1,275 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/langley-creator/cf-backtester/internal/backtester"
 	"github.com/langley-creator/cf-backtester/internal/database"
 	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/internal/resample"
+	"github.com/langley-creator/cf-backtester/internal/uploader"
 )
 
 func main() {
-	fmt.Println("CF-Backtester Starting...")
-
-	// Database connection string
-	connStr := "host=localhost port=5432 user=cryptobot password=cryptobot123 dbname=cryptobot sslmode=disable"
+	connStr := flag.String("db", getEnv("DATABASE_URL", "host=localhost port=5432 user=cryptobot password=cryptobot123 dbname=cryptobot sslmode=disable"),
+		"Database connection string: PostgreSQL, sqlite://file.db or memory:// (nothing is persisted)")
+	csvPath := flag.String("csv", "", "Candle file to import before running: CSV, Parquet (.parquet) or Arrow IPC (.arrow, .feather)")
+	csvFormat := flag.String("format", "generic", "CSV format preset: "+strings.Join(uploader.FormatPresetNames(), ", "))
+	csvColumns := flag.String("columns", "", "CSV column mapping overriding the preset, e.g. timestamp=Date,volume=5")
+	csvDelimiter := flag.String("delimiter", "", "CSV delimiter overriding the preset: a character, tab, semicolon or pipe (default: detect)")
+	csvTimeUnit := flag.String("time-unit", "", "CSV timestamp unit: s, ms, us or iso (default: detect)")
+	strict := flag.Bool("strict", false, "Abort the CSV import on the first invalid row without importing anything")
+	reportPath := flag.String("report", "", "Write the CSV ingest report as JSON to this file")
+	archiveDir := flag.String("archives", "", "Directory of data.binance.vision kline zip archives to import before running")
+	requireChecksum := flag.Bool("require-checksum", false, "Refuse kline archives without a .CHECKSUM file")
+	fundingPath := flag.String("funding", "", "Funding rate CSV to import for the instrument before running (Binance archive or API export)")
+	symbol := flag.String("symbol", "BTCUSDT", "Instrument symbol")
+	exchange := flag.String("exchange", "BINANCE", "Instrument exchange")
+	interval := flag.String("interval", "1h", "Candle interval")
+	resampleFrom := flag.String("resample-from", "", "Build and store -interval candles from this stored interval before running, e.g. 1m")
+	partial := flag.String("partial", resample.PartialDrop, "Resampled bars missing source candles: DROP or KEEP")
+	exportPath := flag.String("export", "", "Export the -interval candles within -from/-to to a Parquet (.parquet) or Arrow IPC (.arrow) file")
+	strategyName := flag.String("strategy", "", "Strategy to backtest")
+	configPath := flag.String("config", "", "Strategy config JSON file, saved under -strategy before running")
+	strategyVersion := flag.Int("strategy-version", 0, "Strategy config version to backtest (default: current)")
+	debugPath := flag.String("debug", "", "Write the per-candle debug trace (indicators, position state, order events) as JSON to this file")
+	debugMax := flag.Int("debug-max", 0, "Limit the -debug trace to the first N candles (0 = all)")
+	from := flag.String("from", "", "Start date (YYYY-MM-DD, default: first candle)")
+	to := flag.String("to", "", "End date (YYYY-MM-DD, default: now)")
+	repairPolicy := flag.String("repair", quality.RepairNone, "Gap repair policy: NONE, FFILL, INTERPOLATE or SPLIT")
+	repairAt := flag.String("repair-at", "load", "Apply -repair when loading candles for the backtest (load) or when importing -csv (import)")
+	qualityPolicy := flag.String("quality", quality.PolicyWarn, "Data quality policy for the candle range: IGNORE, WARN or REFUSE")
+	flag.Parse()
 
-	// Initialize database
-	db, err := database.NewPostgresDB(connStr)
+	policy, err := quality.ParsePolicy(*qualityPolicy)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		log.Fatal(err)
+	}
+	repair, err := quality.ParseRepairPolicy(*repairPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	importRepair, loadRepair := quality.RepairNone, repair
+	switch *repairAt {
+	case "load":
+	case "import":
+		if repair == quality.RepairSplit {
+			log.Fatal("-repair SPLIT can only be applied on load")
+		}
+		importRepair, loadRepair = repair, quality.RepairNone
+	default:
+		log.Fatalf("invalid -repair-at %q (expected load or import)", *repairAt)
 	}
-	defer db.Close()
 
-	fmt.Println("Connected to PostgreSQL successfully!")
+	fmt.Println("CF-Backtester Starting...")
 
-	// Create tables if they don't exist
-	err = db.CreateTables()
+	// Open the store (PostgreSQL fails if migrations are pending)
+	store, err := database.Open(*connStr)
 	if err != nil {
-		log.Fatal("Failed to create tables:", err)
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer store.Close()
+	fmt.Println("Database ready")
+
+	instrument := &models.Instrument{Symbol: *symbol, Exchange: *exchange}
+	if err := store.SaveInstrument(instrument); err != nil {
+		log.Fatal("Failed to save instrument:", err)
 	}
 
-	fmt.Println("Database tables ready")
+	if *csvPath != "" {
+		format, err := uploader.ParseFormat(*csvFormat, *csvColumns, *csvDelimiter, *csvTimeUnit)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	// Example: Insert sample candle data
-	candle := &models.Candle{
-		Symbol:    "BTCUSDT",
-		Interval:  "1h",
-		Timestamp: 1704067200, // Example timestamp
-		Open:      42000.0,
-		High:      42500.0,
-		Low:       41800.0,
-		Close:     42300.0,
-		Volume:    1250.5,
+		csvUploader := uploader.NewCSVUploader(store)
+		csvUploader.Format = format
+		csvUploader.Strict = *strict
+		csvUploader.Repair = importRepair
+		csvUploader.Progress = func(report uploader.IngestReport) {
+			fmt.Printf("  %d rows, %d new (%.0f rows/s)\n", report.Rows, report.Inserted, report.RowsPerSecond())
+		}
+		report, err := csvUploader.UploadFile(*csvPath, int64(instrument.ID), *interval)
+		printReport(*csvPath, report)
+		if *reportPath != "" {
+			if err := writeJSON(*reportPath, report); err != nil {
+				log.Printf("Failed to write ingest report: %v", err)
+			}
+		}
+		if err != nil {
+			log.Fatal("Failed to import candles: ", err)
+		}
 	}
 
-	err = db.InsertCandle(candle)
-	if err != nil {
-		log.Printf("Note: Could not insert sample candle (might already exist): %v\n", err)
-	} else {
-		fmt.Println("Sample candle inserted successfully")
+	if *archiveDir != "" {
+		importer := uploader.NewArchiveImporter(store)
+		importer.Exchange = *exchange
+		importer.RequireChecksum = *requireChecksum
+		importer.CSV.Strict = *strict
+		importer.Progress = func(file *uploader.ArchiveFileReport) {
+			if file.Error != "" {
+				fmt.Printf("  %s: FAILED: %s\n", file.File, file.Error)
+			} else {
+				fmt.Printf("  %s: %s (checksum %s)\n", file.File, file.Ingest.Summary(), strings.ToLower(file.Checksum))
+			}
+		}
+		report, err := importer.ImportDir(*archiveDir)
+		if err != nil {
+			log.Fatal("Failed to import archives: ", err)
+		}
+		fmt.Printf("Imported %s: %s\n", *archiveDir, report.Summary())
+		if len(report.Instruments) > 0 {
+			fmt.Printf("  created instruments: %s\n", strings.Join(report.Instruments, ", "))
+		}
+		if *reportPath != "" {
+			if err := writeJSON(*reportPath, report); err != nil {
+				log.Printf("Failed to write ingest report: %v", err)
+			}
+		}
+	}
+
+	if *fundingPath != "" {
+		imported, err := uploader.NewFundingUploader(store).UploadFundingCSV(*fundingPath, int64(instrument.ID))
+		if err != nil {
+			log.Fatalf("Failed to import funding rates (%d rows imported): %v", imported, err)
+		}
+		fmt.Printf("Imported %d funding rates from %s\n", imported, *fundingPath)
+	}
+
+	if *configPath != "" {
+		if *strategyName == "" {
+			log.Fatal("-config requires -strategy")
+		}
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatal("Failed to read strategy config:", err)
+		}
+		strat := &models.Strategy{Name: *strategyName}
+		if err := json.Unmarshal(data, &strat.Config); err != nil {
+			log.Fatal("Invalid strategy config:", err)
+		}
+		if err := store.SaveStrategy(strat); err != nil {
+			log.Fatal("Failed to save strategy:", err)
+		}
+	}
+
+	startTime, endTime := time.UnixMilli(0), time.Now()
+	if *from != "" {
+		if startTime, err = time.Parse("2006-01-02", *from); err != nil {
+			log.Fatal("Invalid -from date:", err)
+		}
+	}
+	if *to != "" {
+		if endTime, err = time.Parse("2006-01-02", *to); err != nil {
+			log.Fatal("Invalid -to date:", err)
+		}
+	}
+
+	if *resampleFrom != "" {
+		partialPolicy, err := resample.ParsePartial(*partial)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report, err := resample.Materialize(store, int64(instrument.ID), *resampleFrom, *interval, startTime, endTime, partialPolicy)
+		if err != nil {
+			log.Fatal("Failed to resample candles: ", err)
+		}
+		fmt.Printf("Resampled %d %s candles into %d %s bars (%d new, %d partial, %d dropped)\n",
+			report.SourceCandles, report.From, report.Candles, report.To, report.Inserted, report.Partial, report.Dropped)
+	}
+
+	if *exportPath != "" {
+		n, err := uploader.ExportCandles(store, *exportPath, instrument, *interval, startTime, endTime)
+		if err != nil {
+			log.Fatal("Failed to export candles: ", err)
+		}
+		fmt.Printf("Exported %d %s candles to %s\n", n, *interval, *exportPath)
 	}
 
-	// Fetch candles from database
-	candles, err := db.GetCandles("BTCUSDT", "1h", 0, 9999999999)
+	candles, source, err := resample.Load(store, int64(instrument.ID), *interval, startTime, endTime)
 	if err != nil {
 		log.Fatal("Failed to fetch candles:", err)
 	}
+	fmt.Printf("\nFound %d %s candles for %s", len(candles), *interval, instrument.Symbol)
+	if source != "" && source != *interval {
+		fmt.Printf(" (resampled from %s)", source)
+	}
+	fmt.Println()
+
+	if *strategyName == "" {
+		fmt.Println("\nBacktester ready! Pass -strategy to run a backtest.")
+		return
+	}
+
+	engine := backtester.NewEngine(store, *strategyName)
+	engine.SetQualityPolicy(policy)
+	engine.SetRepairPolicy(loadRepair)
+	engine.SetStrategyVersion(*strategyVersion)
+	if *debugPath != "" {
+		if *debugMax < 0 {
+			log.Fatal("-debug-max must not be negative")
+		}
+		engine.EnableDebug(*debugMax)
+	}
+	result, err := engine.Run(int64(instrument.ID), *interval, startTime, endTime)
+	if err != nil {
+		log.Fatal("Backtest failed:", err)
+	}
+
+	if result.Quality != nil && len(result.Quality.IssueCounts) > 0 {
+		fmt.Printf("\nWARNING: data quality issues: %s\n", quality.Summary(result.Quality))
+	}
+
+	fmt.Printf("\nBacktest #%d (%s v%d)\n", result.ID, *strategyName, result.StrategyVersion)
+	fmt.Printf("  Trades:       %d (%d won, %d lost)\n", result.TotalTrades, result.WinningTrades, result.LosingTrades)
+	fmt.Printf("  Win rate:     %.2f%%\n", result.WinRate)
+	fmt.Printf("  Total PnL:    %.2f\n", result.TotalPnL)
+	fmt.Printf("  Total return: %.2f%%\n", result.TotalReturn)
+	if len(result.Equity) > 0 {
+		fmt.Printf("  Final equity: %.2f\n", result.Equity[len(result.Equity)-1].Equity)
+	}
+
+	if *debugPath != "" {
+		if err := writeJSON(*debugPath, result.Debug); err != nil {
+			log.Fatal("Failed to write debug trace: ", err)
+		}
+		fmt.Printf("Debug trace of %d candles written to %s\n", len(result.Debug), *debugPath)
+	}
+}
+
+// printReport prints an ingest report with the first rejected rows
+func printReport(path string, report *uploader.IngestReport) {
+	fmt.Printf("Imported %s: %s\n", path, report.Summary())
+	const maxPrinted = 20
+	for i, row := range report.Rejected {
+		if i == maxPrinted {
+			fmt.Printf("  ... %d more rejected rows\n", report.Invalid-maxPrinted)
+			break
+		}
+		fmt.Printf("  line %d: %s\n", row.Line, row.Reason)
+	}
+}
 
-	fmt.Printf("\nFound %d candles in database\n", len(candles))
-	if len(candles) > 0 {
-		fmt.Printf("Latest candle: %s at %.2f\n", candles[0].Symbol, candles[0].Close)
+// writeJSON writes v as indented JSON to path
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(path, data, 0o644)
+}
 
-	fmt.Println("\nBacktester ready! Add your strategy logic here.")
+// getEnv gets environment variable with fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
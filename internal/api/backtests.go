@@ -0,0 +1,372 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/backtester"
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Backtests per page of the backtests endpoint
+const (
+	defaultBacktestPage = 50
+	maxBacktestPage     = 500
+)
+
+// Trades per page of the trades endpoint
+const (
+	defaultTradePage = 100
+	maxTradePage     = 10000
+)
+
+// BacktestPage is a page of backtest runs returned by the backtests endpoint
+type BacktestPage struct {
+	Backtests  []*models.BacktestResult `json:"backtests"`
+	NextCursor string                   `json:"next_cursor,omitempty"` // Pass as cursor to get the next page
+}
+
+// BacktestDetail is a backtest run with its config snapshot, metrics and, on request, equity curve
+type BacktestDetail struct {
+	*models.BacktestResult
+	Equity []*models.Equity `json:"equity,omitempty"`
+}
+
+// TradePage is a page of the trades of a backtest run
+type TradePage struct {
+	BacktestID int64           `json:"backtest_id"`
+	Total      int             `json:"total"` // Trades of the run
+	Trades     []*models.Trade `json:"trades"`
+	NextCursor int64           `json:"next_cursor,omitempty"` // Pass as cursor to get the next page
+}
+
+// getBacktests lists backtest runs, newest first unless sorted otherwise
+// Query parameters: instrument_id, strategy (name), status (DONE or FAILED), from and to
+// (YYYY-MM-DD or Unix milliseconds; runs whose tested period overlaps the range), sort (a
+// result column such as total_return or created_at, or a metrics key such as profit_factor),
+// order (asc or desc, default desc), limit (default 50, at most 500) and cursor (next_cursor
+// of the previous page, with the same filters and sort)
+func (s *Server) getBacktests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &database.BacktestFilter{SortBy: "created_at", Desc: true}
+	var err error
+
+	if v := query.Get("instrument_id"); v != "" {
+		if filter.InstrumentID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+			return
+		}
+	}
+	if v := query.Get("status"); v != "" {
+		filter.Status = strings.ToUpper(v)
+		if filter.Status != models.BacktestDone && filter.Status != models.BacktestFailed {
+			responseError(w, http.StatusBadRequest, "Invalid status (expected DONE or FAILED)")
+			return
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseTimeParam(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid from time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseTimeParam(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid to time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+
+	if v := query.Get("sort"); v != "" {
+		if !database.ValidBacktestSort(v) {
+			responseError(w, http.StatusBadRequest, "Invalid sort (expected a result column or metrics key)")
+			return
+		}
+		filter.SortBy = v
+	}
+	switch strings.ToLower(query.Get("order")) {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		responseError(w, http.StatusBadRequest, "Invalid order (expected asc or desc)")
+		return
+	}
+
+	limit := defaultBacktestPage
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxBacktestPage {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit (expected 1 to %d)", maxBacktestPage))
+			return
+		}
+	}
+	filter.Limit = limit + 1 // One more tells whether there is a next page
+
+	if v := query.Get("cursor"); v != "" {
+		if filter.After, err = decodeBacktestCursor(v, filter.SortBy); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	if name := query.Get("strategy"); name != "" {
+		strat, err := s.db.GetStrategyByName(name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				responseError(w, http.StatusNotFound, "Strategy not found")
+			} else {
+				responseError(w, http.StatusInternalServerError, "Failed to fetch strategy")
+			}
+			return
+		}
+		filter.StrategyID = int64(strat.ID)
+	}
+
+	results, err := s.db.ListBacktestResults(filter)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch backtests")
+		return
+	}
+
+	page := &BacktestPage{Backtests: []*models.BacktestResult{}}
+	if len(results) > limit {
+		results = results[:limit]
+		page.NextCursor = encodeBacktestCursor(results[limit-1], filter.SortBy)
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	for _, result := range results {
+		result.Config = nil // Part of the detail view
+		page.Backtests = append(page.Backtests, result)
+	}
+	responseJSON(w, http.StatusOK, page)
+}
+
+// encodeBacktestCursor returns the cursor of the page following result in a listing sorted by sortBy
+func encodeBacktestCursor(result *models.BacktestResult, sortBy string) string {
+	value := strconv.FormatFloat(database.BacktestSortValue(result, sortBy), 'g', -1, 64)
+	return base64.RawURLEncoding.EncodeToString([]byte(sortBy + ":" + value + ":" + strconv.FormatInt(result.ID, 10)))
+}
+
+// decodeBacktestCursor parses a cursor made by encodeBacktestCursor for the same sort key
+func decodeBacktestCursor(cursor, sortBy string) (*database.BacktestCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != sortBy {
+		return nil, errors.New("cursor of another listing")
+	}
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &database.BacktestCursor{Value: value, ID: id}, nil
+}
+
+// getBacktest returns a backtest run with the strategy config it ran with and its metrics
+// Query parameters: equity (true to include the equity curve)
+func (s *Server) getBacktest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid backtest ID")
+		return
+	}
+
+	withEquity := false
+	if v := r.URL.Query().Get("equity"); v != "" {
+		if withEquity, err = strconv.ParseBool(v); err != nil {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Invalid equity flag %q", v))
+			return
+		}
+	}
+
+	result, ok := s.backtestResult(w, id)
+	if !ok {
+		return
+	}
+
+	detail := &BacktestDetail{BacktestResult: result}
+	if withEquity {
+		if detail.Equity, err = s.db.GetEquityByBacktestID(id); err != nil {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch equity curve")
+			return
+		}
+	}
+	responseJSON(w, http.StatusOK, detail)
+}
+
+// getBacktestTrades returns the trades of a backtest run in entry order, as JSON or CSV
+// Query parameters: limit (trades per page, default 100, at most 10000), cursor (next_cursor
+// of the previous page) and format (json or csv); CSV exports all trades unless a limit or
+// cursor is given
+func (s *Server) getBacktestTrades(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid backtest ID")
+		return
+	}
+
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		responseError(w, http.StatusBadRequest, "Invalid format (expected json or csv)")
+		return
+	}
+
+	limit := defaultTradePage
+	if format == "csv" && query.Get("limit") == "" && query.Get("cursor") == "" {
+		limit = 0
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxTradePage {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit (expected 1 to %d)", maxTradePage))
+			return
+		}
+	}
+	var cursor int64
+	if v := query.Get("cursor"); v != "" {
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	if _, ok := s.backtestResult(w, id); !ok {
+		return
+	}
+	trades, err := s.db.GetTradesByBacktestID(id)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch trades")
+		return
+	}
+
+	// The cursor is the ID of the first trade of the page
+	page := &TradePage{BacktestID: id, Total: len(trades), Trades: []*models.Trade{}}
+	from := 0
+	if cursor != 0 {
+		from = -1
+		for i, trade := range trades {
+			if trade.ID == cursor {
+				from = i
+				break
+			}
+		}
+		if from < 0 {
+			responseError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+	trades = trades[from:]
+	if limit > 0 && len(trades) > limit {
+		page.NextCursor = trades[limit].ID
+		trades = trades[:limit]
+	}
+	page.Trades = append(page.Trades, trades...)
+
+	if page.NextCursor != 0 {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(page.NextCursor, 10))
+	}
+	if format == "csv" {
+		writeTradesCSV(w, page.Trades)
+		return
+	}
+	responseJSON(w, http.StatusOK, page)
+}
+
+// rerunBacktest runs a stored backtest again with its instrument, interval, time range,
+// strategy version and policies, and responds with the new run
+func (s *Server) rerunBacktest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid backtest ID")
+		return
+	}
+
+	run, ok := s.backtestResult(w, id)
+	if !ok {
+		return
+	}
+	if run.StrategyVersion == 0 || run.Interval == "" {
+		responseError(w, http.StatusConflict, fmt.Sprintf("Backtest %d predates strategy versions and cannot be re-run", id))
+		return
+	}
+
+	strat, err := s.db.GetStrategyByID(run.StrategyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Strategy not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch strategy")
+		}
+		return
+	}
+
+	// Runs saved before the policies were recorded re-run with the defaults
+	engine := backtester.NewEngine(s.db, strat.Name)
+	engine.SetStrategyVersion(run.StrategyVersion)
+	if run.QualityPolicy != "" {
+		engine.SetQualityPolicy(run.QualityPolicy)
+	}
+	if run.RepairPolicy != "" {
+		engine.SetRepairPolicy(run.RepairPolicy)
+	}
+	s.executeBacktest(w, engine, run.InstrumentID, run.Interval, run.StartTime, run.EndTime)
+}
+
+// backtestResult loads a backtest result, responding with an error if it cannot
+func (s *Server) backtestResult(w http.ResponseWriter, id int64) (*models.BacktestResult, bool) {
+	result, err := s.db.GetBacktestResult(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Backtest not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch backtest")
+		}
+		return nil, false
+	}
+	return result, true
+}
+
+// writeTradesCSV writes trades as CSV with a header row, one row per trade with its number of exit fills
+func writeTradesCSV(w http.ResponseWriter, trades []*models.Trade) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "side", "entry_time", "entry_price", "exit_time", "exit_price", "exit_reason",
+		"size", "fees", "funding", "pnl", "fills"})
+	for _, t := range trades {
+		out.Write([]string{
+			strconv.FormatInt(t.ID, 10),
+			t.Side,
+			t.EntryTime.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(t.EntryPrice, 'f', -1, 64),
+			t.ExitTime.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(t.ExitPrice, 'f', -1, 64),
+			t.ExitReason,
+			strconv.FormatFloat(t.Size, 'f', -1, 64),
+			strconv.FormatFloat(t.Fees, 'f', -1, 64),
+			strconv.FormatFloat(t.Funding, 'f', -1, 64),
+			strconv.FormatFloat(t.PnL, 'f', -1, 64),
+			strconv.Itoa(len(t.Fills)),
+		})
+	}
+	out.Flush()
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/downsample"
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/internal/resample"
+	"github.com/langley-creator/cf-backtester/internal/uploader"
+)
+
+// maxImportBytes limits the size of an uploaded candle CSV
+const maxImportBytes = 2 << 30
+
+// ImportError is the response to a failed candle import, with the ingest report so far
+type ImportError struct {
+	Error  string                 `json:"error"`
+	Report *uploader.IngestReport `json:"report"`
+}
+
+// importCandles imports a candle CSV sent as the request body and returns the ingest report
+// Query parameters: interval (required), format, columns, delimiter, time_unit, strict,
+// repair (FFILL or INTERPOLATE to fill gaps in the file)
+func (s *Server) importCandles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instrumentID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+
+	query := r.URL.Query()
+	interval := query.Get("interval")
+	if interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return
+	}
+
+	csvUploader, err := s.newUploader(query)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	// The uploader reads files, so spool the body to a temporary file
+	file, err := os.CreateTemp("", "candles-*.csv")
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to store upload")
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := io.Copy(file, http.MaxBytesReader(w, r.Body, maxImportBytes)); err != nil {
+		responseError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+		return
+	}
+
+	report, err := csvUploader.UploadCSV(file.Name(), instrumentID, interval)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var rowErr *uploader.RowError
+		if errors.As(err, &rowErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		responseJSON(w, status, ImportError{Error: err.Error(), Report: report})
+		return
+	}
+
+	responseJSON(w, http.StatusOK, report)
+}
+
+// newUploader configures a candle uploader from the import options of a request:
+// format, columns, delimiter, time_unit, strict and repair
+func (s *Server) newUploader(options url.Values) (*uploader.CSVUploader, error) {
+	format, err := uploader.ParseFormat(options.Get("format"), options.Get("columns"), options.Get("delimiter"), options.Get("time_unit"))
+	if err != nil {
+		return nil, err
+	}
+
+	strict := false
+	if v := options.Get("strict"); v != "" {
+		if strict, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid strict flag %q", v)
+		}
+	}
+
+	repair, err := quality.ParseRepairPolicy(options.Get("repair"))
+	if err != nil {
+		return nil, err
+	}
+	if repair == quality.RepairSplit {
+		return nil, errors.New("SPLIT repair applies when running backtests, not at import")
+	}
+
+	csvUploader := uploader.NewCSVUploader(s.db)
+	csvUploader.Format = format
+	csvUploader.Strict = strict
+	csvUploader.Repair = repair
+	return csvUploader, nil
+}
+
+// Candles per page of the candles endpoint
+const (
+	defaultCandlePage = 1000
+	maxCandlePage     = 10000
+)
+
+// CandlePage is a page of candles returned by the candles endpoint
+type CandlePage struct {
+	InstrumentID int64            `json:"instrument_id"`
+	Interval     string           `json:"interval"`
+	Source       string           `json:"source,omitempty"`     // Stored interval the candles were resampled from, if not Interval
+	Downsample   string           `json:"downsample,omitempty"` // Method used to reduce the candles to max_points
+	Total        int              `json:"total,omitempty"`      // Candles in range before downsampling
+	Candles      []*models.Candle `json:"candles"`
+	NextCursor   int64            `json:"next_cursor,omitempty"` // Pass as cursor to get the next page
+}
+
+// getCandles returns the candles of an instrument and interval within a time range, as JSON
+// or CSV; intervals that are not stored are resampled from a lower stored interval
+// Query parameters: interval (required), from and to (YYYY-MM-DD or Unix milliseconds,
+// default: all candles), limit (candles per page, default 1000, at most 10000), cursor
+// (next_cursor of the previous page), max_points to downsample the whole range instead of
+// paging, downsample (OHLC or LTTB, default OHLC) and format (json or csv)
+func (s *Server) getCandles(w http.ResponseWriter, r *http.Request) {
+	instrumentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+
+	query := r.URL.Query()
+	interval := query.Get("interval")
+	if interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return
+	}
+
+	startTime, endTime := time.UnixMilli(0), time.Now()
+	if v := query.Get("from"); v != "" {
+		if startTime, err = parseTimeParam(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid from time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if endTime, err = parseTimeParam(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid to time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		startTime = time.UnixMilli(cursor)
+	}
+
+	limit := defaultCandlePage
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxCandlePage {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit (expected 1 to %d)", maxCandlePage))
+			return
+		}
+	}
+
+	maxPoints := 0
+	if v := query.Get("max_points"); v != "" {
+		if maxPoints, err = strconv.Atoi(v); err != nil || maxPoints <= 0 {
+			responseError(w, http.StatusBadRequest, "Invalid max_points")
+			return
+		}
+	}
+	method, err := downsample.ParseMethod(query.Get("downsample"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		responseError(w, http.StatusBadRequest, "Invalid format (expected json or csv)")
+		return
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	page := &CandlePage{InstrumentID: instrumentID, Interval: interval, Candles: []*models.Candle{}}
+	if maxPoints > 0 {
+		err = s.loadDownsampled(page, startTime, endTime, method, maxPoints)
+	} else {
+		err = s.loadPage(page, startTime, endTime, limit)
+	}
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch candles")
+		return
+	}
+
+	if page.NextCursor != 0 {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(page.NextCursor, 10))
+	}
+	if format == "csv" {
+		writeCandlesCSV(w, page.Candles)
+		return
+	}
+	responseJSON(w, http.StatusOK, page)
+}
+
+// loadPage reads up to limit candles from startTime into page
+// The range is read in windows of limit bars, clamped to the stored candles, so a page
+// never loads more than it returns and gaps cost one query per window
+func (s *Server) loadPage(page *CandlePage, startTime, endTime time.Time, limit int) error {
+	start, end, ok, err := s.candleRange(page.InstrumentID, page.Interval, startTime, endTime)
+	if err != nil || !ok {
+		return err
+	}
+
+	window := int64(math.MaxInt64)
+	if step, err := models.IntervalMillis(page.Interval); err == nil {
+		window = step * int64(limit)
+	}
+
+	from := start
+	for from <= end && len(page.Candles) <= limit {
+		to := end
+		if end-from >= window {
+			to = from + window - 1
+		}
+		candles, source, err := resample.Load(s.db, page.InstrumentID, page.Interval, time.UnixMilli(from), time.UnixMilli(to))
+		if err != nil {
+			return err
+		}
+		if source != "" && source != page.Interval {
+			page.Source = source
+		}
+		page.Candles = append(page.Candles, candles...)
+		if to == end {
+			break
+		}
+		from = to + 1
+	}
+
+	if len(page.Candles) > limit {
+		page.NextCursor = page.Candles[limit].Timestamp
+		page.Candles = page.Candles[:limit]
+	}
+	return nil
+}
+
+// loadDownsampled reads the whole range into page and reduces it to maxPoints candles
+func (s *Server) loadDownsampled(page *CandlePage, startTime, endTime time.Time, method string, maxPoints int) error {
+	candles, source, err := resample.Load(s.db, page.InstrumentID, page.Interval, startTime, endTime)
+	if err != nil {
+		return err
+	}
+	if source != "" && source != page.Interval {
+		page.Source = source
+	}
+	page.Total = len(candles)
+	if len(candles) > maxPoints {
+		page.Downsample = method
+		candles = downsample.Downsample(candles, method, maxPoints)
+	}
+	if candles != nil {
+		page.Candles = candles
+	}
+	return nil
+}
+
+// candleRange clamps a time range to the stored candles of an interval, or of the interval
+// it would be resampled from; false if there are none
+func (s *Server) candleRange(instrumentID int64, interval string, startTime, endTime time.Time) (int64, int64, bool, error) {
+	series, err := s.db.GetCandleSeries(instrumentID)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	stored := interval
+	if !hasSeries(series, interval) {
+		stored = resample.Source(series, interval)
+	}
+	for _, ser := range series {
+		if ser.Interval == stored && stored != "" {
+			start, end := max(startTime.UnixMilli(), ser.First), min(endTime.UnixMilli(), ser.Last)
+			return start, end, start <= end, nil
+		}
+	}
+	return 0, 0, false, nil
+}
+
+// hasSeries reports whether an interval is stored
+func hasSeries(series []*models.CandleSeries, interval string) bool {
+	for _, s := range series {
+		if s.Interval == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCandlesCSV writes candles as CSV with a header row, keeping full float precision
+func writeCandlesCSV(w http.ResponseWriter, candles []*models.Candle) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"timestamp", "open", "high", "low", "close", "volume", "repaired"})
+	for _, c := range candles {
+		out.Write([]string{
+			strconv.FormatInt(c.Timestamp, 10),
+			strconv.FormatFloat(c.Open, 'f', -1, 64),
+			strconv.FormatFloat(c.High, 'f', -1, 64),
+			strconv.FormatFloat(c.Low, 'f', -1, 64),
+			strconv.FormatFloat(c.Close, 'f', -1, 64),
+			strconv.FormatFloat(c.Volume, 'f', -1, 64),
+			c.Repaired,
+		})
+	}
+	out.Flush()
+}
+
+// parseTimeParam parses a time query parameter: a date (YYYY-MM-DD) or Unix milliseconds
+func parseTimeParam(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// ResampleError is the response to a failed resample, with the bars built so far
+type ResampleError struct {
+	Error  string           `json:"error"`
+	Report *resample.Report `json:"report"`
+}
+
+// resampleCandles builds and stores bars of a higher interval from stored candles and returns the report
+// Query parameters: from and to intervals (required), start and end (YYYY-MM-DD, default: all
+// candles), partial (DROP or KEEP bars missing source candles)
+func (s *Server) resampleCandles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instrumentID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		responseError(w, http.StatusBadRequest, "from and to intervals are required")
+		return
+	}
+	if !resample.CanResample(from, to) {
+		responseError(w, http.StatusBadRequest, fmt.Sprintf("%s candles cannot be resampled into %s", from, to))
+		return
+	}
+
+	partial, err := resample.ParsePartial(query.Get("partial"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	startTime, endTime := time.UnixMilli(0), time.Now()
+	if v := query.Get("start"); v != "" {
+		if startTime, err = time.Parse("2006-01-02", v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid start date format")
+			return
+		}
+	}
+	if v := query.Get("end"); v != "" {
+		if endTime, err = time.Parse("2006-01-02", v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid end date format")
+			return
+		}
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	report, err := resample.Materialize(s.db, instrumentID, from, to, startTime, endTime, partial)
+	if err != nil {
+		responseJSON(w, http.StatusInternalServerError, ResampleError{Error: err.Error(), Report: report})
+		return
+	}
+
+	responseJSON(w, http.StatusOK, report)
+}
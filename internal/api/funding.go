@@ -0,0 +1,70 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/uploader"
+)
+
+// FundingImport is the result of a funding rate import
+type FundingImport struct {
+	InstrumentID int64  `json:"instrument_id"`
+	Imported     int    `json:"imported"`        // Rows saved, including events that were already stored
+	Error        string `json:"error,omitempty"` // Row the import stopped on; earlier rows are kept
+}
+
+// importFunding imports a funding rate CSV sent as the request body
+// Binance archive and API exports are detected from the header; headerless files hold
+// timestamp,rate[,mark_price] with Unix millisecond timestamps
+func (s *Server) importFunding(w http.ResponseWriter, r *http.Request) {
+	instrumentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	// The uploader reads files, so spool the body to a temporary file
+	file, err := os.CreateTemp("", "funding-*.csv")
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to store upload")
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := io.Copy(file, http.MaxBytesReader(w, r.Body, maxImportBytes)); err != nil {
+		responseError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+		return
+	}
+
+	imported, err := uploader.NewFundingUploader(s.db).UploadFundingCSV(file.Name(), instrumentID)
+	result := FundingImport{InstrumentID: instrumentID, Imported: imported}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var rowErr *uploader.RowError
+		if errors.As(err, &rowErr) {
+			status = http.StatusUnprocessableEntity
+		}
+		result.Error = err.Error()
+		responseJSON(w, status, result)
+		return
+	}
+
+	responseJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/backtester"
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/internal/resample"
+	"github.com/langley-creator/cf-backtester/internal/strategy"
+)
+
+// maxIndicatorCandles limits the candles evaluated by one indicators request
+const maxIndicatorCandles = 200000
+
+// IndicatorRequest asks for the indicators of an instrument with a stored or inline strategy config
+type IndicatorRequest struct {
+	Interval     string                 `json:"interval"`
+	From         string                 `json:"from,omitempty"` // YYYY-MM-DD or Unix milliseconds, default: first candle
+	To           string                 `json:"to,omitempty"`   // YYYY-MM-DD or Unix milliseconds, default: now
+	StrategyName string                 `json:"strategy_name,omitempty"`
+	Config       *models.StrategyConfig `json:"config,omitempty"` // Evaluated instead of the stored strategy
+
+	// Gap repair as in a backtest: NONE (default), FFILL, INTERPOLATE or SPLIT
+	RepairPolicy string `json:"repair_policy,omitempty"`
+}
+
+// IndicatorSeries holds the indicators the backtester trades on, aligned with the candle
+// timestamps: element i of every series belongs to Timestamps[i]
+type IndicatorSeries struct {
+	InstrumentID int64                 `json:"instrument_id"`
+	Interval     string                `json:"interval"`
+	Source       string                `json:"source,omitempty"` // Stored interval the candles were resampled from, if not Interval
+	Strategy     string                `json:"strategy,omitempty"`
+	Config       models.StrategyConfig `json:"config"`
+	Period       int                   `json:"period"` // ATR and ADX period
+	Timestamps   []int64               `json:"timestamps"`
+	Close        []float64             `json:"close"`
+	Repaired     []string              `json:"repaired,omitempty"` // Repair method per candle, when gaps were filled
+	*strategy.Series
+}
+
+// getIndicators evaluates the indicators of a stored strategy over a range of candles
+// Query parameters: interval and strategy (required), from and to (YYYY-MM-DD or Unix
+// milliseconds, default: all candles), repair (NONE, FFILL, INTERPOLATE or SPLIT)
+func (s *Server) getIndicators(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := IndicatorRequest{
+		Interval:     query.Get("interval"),
+		From:         query.Get("from"),
+		To:           query.Get("to"),
+		StrategyName: query.Get("strategy"),
+		RepairPolicy: query.Get("repair"),
+	}
+	s.indicators(w, r, &req)
+}
+
+// evaluateIndicators evaluates the indicators of a stored strategy or of an inline config
+// sent as an IndicatorRequest body
+func (s *Server) evaluateIndicators(w http.ResponseWriter, r *http.Request) {
+	var req IndicatorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	s.indicators(w, r, &req)
+}
+
+// indicators loads the candles of a request and responds with their IndicatorSeries
+// Candles are loaded and repaired as the engine does, so the values match a backtest over
+// the same range
+func (s *Server) indicators(w http.ResponseWriter, r *http.Request, req *IndicatorRequest) {
+	instrumentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+	if req.Interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return
+	}
+
+	startTime, endTime := time.UnixMilli(0), time.Now()
+	if req.From != "" {
+		if startTime, err = parseTimeParam(req.From); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid from time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+	if req.To != "" {
+		if endTime, err = parseTimeParam(req.To); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid to time (expected YYYY-MM-DD or Unix milliseconds)")
+			return
+		}
+	}
+
+	repair, err := quality.ParseRepairPolicy(req.RepairPolicy)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := &IndicatorSeries{InstrumentID: instrumentID, Interval: req.Interval, Period: strategy.IndicatorPeriod}
+	switch {
+	case req.Config != nil:
+		var configErr *backtester.ConfigError
+		if err := backtester.ValidateConfig(req.Config); errors.As(err, &configErr) {
+			responseJSON(w, http.StatusUnprocessableEntity, StrategyError{Error: "Invalid config", Fields: configErr.Fields})
+			return
+		}
+		result.Config = *req.Config
+	case req.StrategyName != "":
+		strat, err := s.db.GetStrategyByName(req.StrategyName)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				responseError(w, http.StatusNotFound, "Strategy not found")
+			} else {
+				responseError(w, http.StatusInternalServerError, "Failed to fetch strategy")
+			}
+			return
+		}
+		result.Strategy = strat.Name
+		result.Config = strat.Config
+	default:
+		responseError(w, http.StatusBadRequest, "A strategy name or an inline config is required")
+		return
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	candles, source, err := resample.Load(s.db, instrumentID, req.Interval, startTime, endTime)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch candles")
+		return
+	}
+	if source != "" && source != req.Interval {
+		result.Source = source
+	}
+
+	// Check the size before repair, counting the candles that filling gaps would add
+	count := len(candles)
+	if repair == quality.RepairForwardFill || repair == quality.RepairInterpolate {
+		if step, err := models.IntervalMillis(req.Interval); err == nil && count > 1 {
+			count = max(count, int((candles[count-1].Timestamp-candles[0].Timestamp)/step)+1)
+		}
+	}
+	if count > maxIndicatorCandles {
+		responseError(w, http.StatusBadRequest, fmt.Sprintf("Range holds %d candles, at most %d can be evaluated at once", count, maxIndicatorCandles))
+		return
+	}
+
+	sessions := [][]*models.Candle{candles}
+	switch repair {
+	case quality.RepairForwardFill, quality.RepairInterpolate:
+		if candles, _, err = quality.Repair(candles, req.Interval, repair); err != nil {
+			responseError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sessions = [][]*models.Candle{candles}
+	case quality.RepairSplit:
+		sessions = quality.Split(candles, req.Interval)
+	}
+
+	// Indicators start over in every session, as in a backtest
+	result.Series = strategy.CalculateSeries(sessions[0], &result.Config)
+	for _, session := range sessions[1:] {
+		result.Series.Append(strategy.CalculateSeries(session, &result.Config))
+	}
+
+	result.Timestamps = make([]int64, len(candles))
+	result.Close = make([]float64, len(candles))
+	repaired := false
+	for i, candle := range candles {
+		result.Timestamps[i] = candle.Timestamp
+		result.Close[i] = candle.Close
+		repaired = repaired || candle.Repaired != ""
+	}
+	if repaired {
+		result.Repaired = make([]string, len(candles))
+		for i, candle := range candles {
+			result.Repaired[i] = candle.Repaired
+		}
+	}
+
+	responseJSON(w, http.StatusOK, result)
+}
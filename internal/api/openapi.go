@@ -0,0 +1,312 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/resample"
+)
+
+// openAPIVersion is the OpenAPI version of the document
+const openAPIVersion = "3.0.3"
+
+// componentNames name the components of types whose own name is too generic
+var componentNames = map[reflect.Type]string{
+	reflect.TypeOf(resample.Report{}): "ResampleReport",
+}
+
+// readOnlyFields are set by the server; clients leave them out of request bodies
+var readOnlyFields = map[string]bool{"id": true, "created_at": true, "updated_at": true, "version": true}
+
+// getOpenAPI returns the OpenAPI 3 document of the API
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	responseJSON(w, http.StatusOK, s.openAPI)
+}
+
+// buildOpenAPI describes apiOperations as an OpenAPI document; the schemas of request and
+// response bodies are generated from their Go types, as encoding/json marshals them
+func buildOpenAPI() map[string]interface{} {
+	g := &schemaGenerator{components: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	paths := map[string]map[string]interface{}{}
+	for i := range apiOperations {
+		op := &apiOperations[i]
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = g.operation(op)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "CF Backtester API",
+			"version":     strings.TrimPrefix(apiPrefix, "/api/"),
+			"description": "Candle storage, data quality, strategies and backtests of the CF backtester",
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": apiPrefix}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.components},
+	}
+}
+
+// pathParamPattern matches the {name} parameters of a route path
+var pathParamPattern = regexp.MustCompile(`{([a-z_]+)}`)
+
+// operation describes op as an OpenAPI operation object
+func (g *schemaGenerator) operation(op *apiOperation) map[string]interface{} {
+	var params []interface{}
+	documented := map[string]bool{}
+	for _, p := range op.Params {
+		if p.In == "path" {
+			documented[p.Name] = true
+		}
+	}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+		if !documented[match[1]] {
+			params = append(params, apiParam{Name: match[1], In: "path", Type: "integer", Required: true, Description: "ID"}.openAPI())
+		}
+	}
+	for _, p := range op.Params {
+		params = append(params, p.openAPI())
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schemaOf(op.Response)}}
+	if op.CSV {
+		success["text/csv"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	}
+	responses := map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{"description": http.StatusText(status), "content": success},
+	}
+	for status, body := range op.Errors {
+		if body == nil {
+			body = ErrorResponse{}
+		}
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schemaOf(body)}},
+		}
+	}
+
+	operation := map[string]interface{}{
+		"operationId": handlerName(op.Handler),
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"responses":   responses,
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	switch {
+	case op.Body != nil:
+		operation["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schemaOf(op.Body)}},
+		}
+	case op.BodyType == "multipart/form-data":
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{op.BodyType: map[string]interface{}{"schema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"file": map[string]interface{}{"type": "string", "format": "binary"}},
+				"required":   []string{"file"},
+			}}},
+		}
+	case op.BodyType != "":
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{op.BodyType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+		}
+	}
+	return operation
+}
+
+// openAPI describes p as an OpenAPI parameter object
+func (p apiParam) openAPI() map[string]interface{} {
+	param := map[string]interface{}{
+		"name":   p.Name,
+		"in":     p.In,
+		"schema": map[string]interface{}{"type": p.Type},
+	}
+	if p.Required {
+		param["required"] = true
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+// handlerName is the method name of a handler, used as the operation ID
+func handlerName(handler func(*Server, http.ResponseWriter, *http.Request)) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// schemaGenerator builds the OpenAPI schemas of Go types; structs become components
+type schemaGenerator struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns the schema of the type of value
+func (g *schemaGenerator) schemaOf(value interface{}) map[string]interface{} {
+	return g.schema(reflect.TypeOf(value))
+}
+
+// schema returns the schema of t
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return map[string]interface{}{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+	return map[string]interface{}{} // Interfaces hold any JSON value
+}
+
+// ref returns a reference to the component schema of struct t, adding it on first use
+// Components are named after their type, prefixed with the package on a name clash
+func (g *schemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if override, ok := componentNames[t]; ok {
+			name = override
+		}
+		if _, taken := g.components[name]; taken || name == "" {
+			pkg := path.Base(t.PkgPath())
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		g.names[t] = name
+		g.components[name] = nil // Reserved while the fields refer back to t
+		g.components[name] = g.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// object returns the schema of struct t: its JSON fields, and those promoted from embedded
+// structs unless the outer struct has a field of the same name, as with encoding/json
+// Fields without omitempty are always marshaled, so they are required, and nullable if nil
+// values marshal as null
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+			switch field.Type.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+				schema = nullable(schema)
+			}
+		}
+		if readOnlyFields[name] {
+			schema["readOnly"] = true
+		}
+		properties[name] = schema
+	}
+
+	for _, e := range embedded {
+		promoted := g.object(e)
+		for name, schema := range promoted["properties"].(map[string]interface{}) {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+				if contains(promoted["required"], name) {
+					required = append(required, name)
+				}
+			}
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// nullable allows null in place of a value of schema
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if _, ok := schema["$ref"]; ok {
+		// Siblings of $ref are ignored, so wrap the reference
+		return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+	}
+	if len(schema) == 0 {
+		return schema // Any value, null included
+	}
+	schema["nullable"] = true
+	return schema
+}
+
+// contains reports whether list, a []string, holds s
+func contains(list interface{}, s string) bool {
+	names, _ := list.([]string)
+	for _, name := range names {
+		if name == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/database"
+)
+
+// testConfig is a valid strategy config for the memory store scenario
+const testConfig = `{"total_klines":50,"custom_amplitude":1,"main_cf_start":0.1,"second_cf_env":0.5,
+"epsilon":0.001,"new_total_klines_min":10,"new_total_klines_max":50,"second_total_klines_min":10}`
+
+// refPattern matches the schema references of a document
+var refPattern = regexp.MustCompile(`"#/components/schemas/(\w+)"`)
+
+// apiCall is a request of the scenario and the status it must answer with
+type apiCall struct {
+	method      string
+	path        string
+	body        string
+	contentType string
+	status      int
+}
+
+// openAPIChecker validates responses against the OpenAPI document of a server
+type openAPIChecker struct {
+	t        *testing.T
+	server   *Server
+	doc      map[string]interface{}
+	observed map[string]bool // "METHOD /path status" of the validated responses
+}
+
+func newOpenAPIChecker(t *testing.T) *openAPIChecker {
+	server := NewServer(database.NewMemoryStore(), "0")
+
+	// Round-trip through JSON, as clients read the document
+	data, err := json.Marshal(server.openAPI)
+	if err != nil {
+		t.Fatalf("marshal OpenAPI document: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unmarshal OpenAPI document: %v", err)
+	}
+	return &openAPIChecker{t: t, server: server, doc: doc, observed: map[string]bool{}}
+}
+
+// do sends call to the server, checks its status and validates the response against the
+// document; it returns the decoded JSON body
+func (c *openAPIChecker) do(call apiCall) interface{} {
+	c.t.Helper()
+	req := httptest.NewRequest(call.method, apiPrefix+call.path, strings.NewReader(call.body))
+	if call.contentType != "" {
+		req.Header.Set("Content-Type", call.contentType)
+	}
+	rec := httptest.NewRecorder()
+	c.server.router.ServeHTTP(rec, req)
+
+	if rec.Code != call.status {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", call.method, call.path, rec.Code, call.status, rec.Body)
+	}
+
+	var match mux.RouteMatch
+	if !c.server.router.Match(req, &match) {
+		c.t.Fatalf("%s %s: no route", call.method, call.path)
+	}
+	template, _ := match.Route.GetPathTemplate()
+	path := strings.TrimPrefix(template, apiPrefix)
+	operation, _ := lookup(c.doc, "paths", path, strings.ToLower(call.method)).(map[string]interface{})
+	if operation == nil {
+		c.t.Fatalf("%s %s: route is not documented", call.method, path)
+	}
+	response, _ := lookup(operation, "responses", fmt.Sprint(rec.Code)).(map[string]interface{})
+	if response == nil {
+		c.t.Fatalf("%s %s: status %d is not documented", call.method, path, rec.Code)
+	}
+	c.observed[fmt.Sprintf("%s %s %d", call.method, path, rec.Code)] = true
+
+	contentType := rec.Header().Get("Content-Type")
+	if contentType != "application/json" {
+		if lookup(response, "content", contentType) == nil {
+			c.t.Fatalf("%s %s: content type %s is not documented", call.method, path, contentType)
+		}
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		c.t.Fatalf("%s %s: invalid JSON: %v", call.method, path, err)
+	}
+	schema, _ := lookup(response, "content", "application/json", "schema").(map[string]interface{})
+	for _, problem := range c.validate(schema, body, "body", 0) {
+		c.t.Errorf("%s %s %d: %s", call.method, path, rec.Code, problem)
+	}
+	return body
+}
+
+// validate checks a decoded JSON value against schema and returns the mismatches
+// Objects with properties are closed: fields the schema does not list are mismatches
+func (c *openAPIChecker) validate(schema map[string]interface{}, value interface{}, at string, depth int) []string {
+	if depth > 32 {
+		return []string{at + ": schema nested too deep"}
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		component, _ := lookup(c.doc, "components", "schemas", name).(map[string]interface{})
+		if component == nil {
+			return []string{fmt.Sprintf("%s: unresolved %s", at, ref)}
+		}
+		return c.validate(component, value, at, depth+1)
+	}
+	if value == nil {
+		if len(schema) == 0 || schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": null is not allowed"}
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		var problems []string
+		for _, sub := range allOf {
+			problems = append(problems, c.validate(sub.(map[string]interface{}), value, at, depth+1)...)
+		}
+		return problems
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an object", at, value)}
+		}
+		var problems []string
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for key, field := range object {
+			switch {
+			case properties[key] != nil:
+				problems = append(problems, c.validate(properties[key].(map[string]interface{}), field, at+"."+key, depth+1)...)
+			case additional != nil:
+				problems = append(problems, c.validate(additional, field, at+"."+key, depth+1)...)
+			case properties != nil:
+				problems = append(problems, fmt.Sprintf("%s.%s: field is not documented", at, key))
+			}
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := object[key.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: required field is missing", at, key))
+			}
+		}
+		return problems
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an array", at, value)}
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		var problems []string
+		for i, item := range array {
+			problems = append(problems, c.validate(items, item, fmt.Sprintf("%s[%d]", at, i), depth+1)...)
+		}
+		return problems
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not a string", at, value)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", at, s)}
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s: %v is not an integer", at, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a number", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a boolean", at, value)}
+		}
+	}
+	return nil
+}
+
+// lookup follows keys through nested JSON objects, returning nil if one is missing
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// testCandles returns an hourly random walk as a generic candle CSV
+func testCandles(n int) string {
+	rng := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	b.WriteString("timestamp,open,high,low,close,volume\n")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	price := 100.0
+	for i := 0; i < n; i++ {
+		open := price
+		price *= 1 + rng.NormFloat64()*0.01
+		high := math.Max(open, price) * (1 + rng.Float64()*0.004)
+		low := math.Min(open, price) * (1 - rng.Float64()*0.004)
+		fmt.Fprintf(&b, "%d,%.4f,%.4f,%.4f,%.4f,%.2f\n", start+int64(i)*3600000, open, high, low, price, 500+rng.Float64()*500)
+	}
+	return b.String()
+}
+
+// multipartFile returns a multipart/form-data body holding one file and its content type
+func multipartFile(t *testing.T, name, content string) (string, string) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	w.Close()
+	return body.String(), w.FormDataContentType()
+}
+
+// TestOpenAPIResponses runs every endpoint against a memory store and checks that the
+// responses match the OpenAPI document, so a handler whose response drifts from its documented
+// schema fails here; every documented success response must be exercised
+func TestOpenAPIResponses(t *testing.T) {
+	c := newOpenAPIChecker(t)
+	candles := testCandles(2000)
+	strategy := `{"name":"cf","config":` + testConfig + `}`
+
+	calls := []apiCall{
+		{method: "GET", path: "/health", status: 200},
+		{method: "GET", path: "/openapi.json", status: 200},
+
+		// Instruments and candles
+		{method: "GET", path: "/instruments", status: 200},
+		{method: "POST", path: "/instruments", body: `{"symbol":"BTCUSDT","exchange":"BINANCE"}`, status: 201},
+		{method: "POST", path: "/instruments", body: `{`, status: 400},
+		{method: "GET", path: "/instruments", status: 200},
+		{method: "POST", path: "/instruments/1/candles?interval=1h", body: candles, contentType: "text/csv", status: 200},
+		{method: "POST", path: "/instruments/1/candles?interval=1h&strict=true", body: "timestamp,open,high,low,close,volume\nx,1,1,1,1,1\n", contentType: "text/csv", status: 422},
+		{method: "POST", path: "/instruments/9/candles?interval=1h", body: candles, contentType: "text/csv", status: 404},
+		{method: "POST", path: "/instruments/1/funding", body: "fundingTime,fundingRate,markPrice\n1704067200000,0.0001,42000\n1704096000000,-0.00005,42100\n", contentType: "text/csv", status: 200},
+		{method: "POST", path: "/instruments/1/funding", body: "fundingTime,fundingRate\n1704124800000,x\n", contentType: "text/csv", status: 422},
+		{method: "GET", path: "/instruments/1/candles?interval=1h&limit=100", status: 200},
+		{method: "GET", path: "/instruments/1/candles?interval=1h&limit=10&format=csv", status: 200},
+		{method: "GET", path: "/instruments/1/candles?interval=4h&max_points=50&downsample=LTTB", status: 200},
+		{method: "GET", path: "/instruments/1/candles", status: 400},
+		{method: "POST", path: "/instruments/1/resample?from=1h&to=4h", status: 200},
+		{method: "POST", path: "/instruments/1/resample?from=4h&to=1h", status: 400},
+		{method: "GET", path: "/instruments/1/quality?interval=1h", status: 404},
+		{method: "POST", path: "/instruments/1/quality?interval=1h", status: 200},
+		{method: "GET", path: "/instruments/1/quality?interval=1h", status: 200},
+
+		// Strategies
+		{method: "GET", path: "/strategies", status: 200},
+		{method: "POST", path: "/strategies", body: strategy, status: 201},
+		{method: "POST", path: "/strategies", body: strategy, status: 409},
+		{method: "POST", path: "/strategies", body: `{"name":"bad","config":{"epsilon":-1}}`, status: 422},
+		{method: "GET", path: "/strategies/cf", status: 200},
+		{method: "GET", path: "/strategies/nope", status: 404},
+		{method: "PUT", path: "/strategies/cf", body: `{"config":` + strings.Replace(testConfig, `"epsilon":0.001`, `"epsilon":0.002`, 1) + `}`, status: 200},
+		{method: "POST", path: "/strategies/cf/clone", status: 201},
+		{method: "GET", path: "/strategies", status: 200},
+		{method: "GET", path: "/strategies/cf/versions", status: 200},
+		{method: "GET", path: "/strategies/cf/versions/1", status: 200},
+		{method: "GET", path: "/strategies/cf/versions/x", status: 400},
+		{method: "GET", path: "/strategies/cf/diff", status: 200},
+		{method: "GET", path: "/strategies/cf/diff?from=7", status: 404},
+		{method: "DELETE", path: "/strategies/cf-copy", status: 200},
+
+		// Indicators
+		{method: "GET", path: "/instruments/1/indicators?interval=1h&strategy=cf&from=2024-02-01&to=2024-02-10", status: 200},
+		{method: "POST", path: "/instruments/1/indicators", body: `{"interval":"4h","config":` + testConfig + `}`, status: 200},
+		{method: "POST", path: "/instruments/1/indicators", body: `{"interval":"1h"}`, status: 400},
+		{method: "POST", path: "/instruments/1/indicators", body: `{"interval":"1h","config":{"total_klines":-5}}`, status: 422},
+
+		// Backtests
+		{method: "POST", path: "/backtests", body: `{"instrument_id":1,"interval":"1h","strategy_name":"cf","start_date":"2024-01-01","end_date":"2025-01-01","strategy_version":1,"debug":true,"debug_max_candles":50}`, status: 200},
+		{method: "POST", path: "/backtests", body: `{"instrument_id":1,"interval":"1h","strategy_name":"nope","start_date":"2024-01-01","end_date":"2025-01-01"}`, status: 404},
+		{method: "POST", path: "/backtests/1/rerun", status: 200},
+		{method: "GET", path: "/backtests?sort=total_return&limit=1", status: 200},
+		{method: "GET", path: "/backtests?status=x", status: 400},
+		{method: "GET", path: "/backtests/1?equity=true", status: 200},
+		{method: "GET", path: "/backtests/9", status: 404},
+		{method: "GET", path: "/backtests/1/trades?limit=5", status: 200},
+		{method: "GET", path: "/backtests/1/trades?format=csv", status: 200},
+		{method: "DELETE", path: "/strategies/cf", status: 409},
+	}
+	for _, call := range calls {
+		c.do(call)
+	}
+
+	// Background uploads
+	body, contentType := multipartFile(t, "eth.csv", candles)
+	c.do(apiCall{method: "POST", path: "/instruments/1/uploads?interval=1h", body: body, contentType: contentType, status: 202})
+	c.do(apiCall{method: "POST", path: "/instruments/1/uploads", body: body, contentType: contentType, status: 400})
+	c.do(apiCall{method: "POST", path: "/instruments/9/uploads?interval=1h", body: body, contentType: contentType, status: 404})
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		job, _ := c.do(apiCall{method: "GET", path: "/uploads/1", status: 200}).(map[string]interface{})
+		if job["status"] == "DONE" || job["status"] == "FAILED" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("upload job still %v", job["status"])
+		}
+	}
+	c.do(apiCall{method: "GET", path: "/uploads", status: 200})
+	c.do(apiCall{method: "GET", path: "/uploads/9", status: 404})
+
+	// Every success response is exercised, so new endpoints need a call above
+	var missing []string
+	for _, op := range apiOperations {
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		key := fmt.Sprintf("%s %s %d", op.Method, op.Path, status)
+		if !c.observed[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		t.Errorf("%s: not exercised", key)
+	}
+}
+
+// TestOpenAPIDocument checks the document describes every registered route, with unique
+// operation IDs, documented path parameters and resolvable schema references
+func TestOpenAPIDocument(t *testing.T) {
+	c := newOpenAPIChecker(t)
+
+	err := c.server.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, apiPrefix+"/") {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if lookup(c.doc, "paths", strings.TrimPrefix(template, apiPrefix), strings.ToLower(method)) == nil {
+				t.Errorf("%s %s: route is not documented", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]string{}
+	for path, item := range c.doc["paths"].(map[string]interface{}) {
+		for method, value := range item.(map[string]interface{}) {
+			operation := value.(map[string]interface{})
+			id, _ := operation["operationId"].(string)
+			if id == "" || ids[id] != "" {
+				t.Errorf("%s %s: operation ID %q is empty or used by %s", method, path, id, ids[id])
+			}
+			ids[id] = method + " " + path
+
+			params := map[string]bool{}
+			list, _ := operation["parameters"].([]interface{})
+			for _, p := range list {
+				param := p.(map[string]interface{})
+				if param["in"] == "path" {
+					params[param["name"].(string)] = true
+				}
+			}
+			for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+				if !params[match[1]] {
+					t.Errorf("%s %s: path parameter %s is not documented", method, path, match[1])
+				}
+			}
+		}
+	}
+
+	data, _ := json.Marshal(c.doc)
+	for _, match := range refPattern.FindAllStringSubmatch(string(data), -1) {
+		if lookup(c.doc, "components", "schemas", match[1]) == nil {
+			t.Errorf("unresolved reference to %s", match[1])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+)
+
+// checkQuality checks the candles of an instrument and interval, stores the report and returns it
+// Query parameters: interval (required), from and to (YYYY-MM-DD, default: all candles)
+func (s *Server) checkQuality(w http.ResponseWriter, r *http.Request) {
+	instrumentID, interval, ok := s.qualitySeries(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	startTime, endTime := time.UnixMilli(0), time.Now()
+	var err error
+	if v := query.Get("from"); v != "" {
+		if startTime, err = time.Parse("2006-01-02", v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid from date format")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if endTime, err = time.Parse("2006-01-02", v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid to date format")
+			return
+		}
+	}
+
+	candles, err := s.db.GetCandlesByTimeRange(instrumentID, interval, startTime, endTime)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch candles")
+		return
+	}
+
+	report := quality.NewChecker(quality.DefaultOptions()).Check(candles, interval)
+	report.InstrumentID = instrumentID
+	if err := s.db.SaveQualityReport(report); err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to save quality report")
+		return
+	}
+
+	responseJSON(w, http.StatusOK, report)
+}
+
+// getQuality returns the latest stored quality report of an instrument and interval
+// Query parameters: interval (required)
+func (s *Server) getQuality(w http.ResponseWriter, r *http.Request) {
+	instrumentID, interval, ok := s.qualitySeries(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := s.db.GetLatestQualityReport(instrumentID, interval)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "No quality report for this series")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch quality report")
+		}
+		return
+	}
+
+	responseJSON(w, http.StatusOK, report)
+}
+
+// qualitySeries parses the instrument and interval of a quality request and checks the instrument exists
+func (s *Server) qualitySeries(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	instrumentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return 0, "", false
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return 0, "", false
+	}
+
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return 0, "", false
+	}
+
+	return instrumentID, interval, true
+}
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/resample"
+	"github.com/langley-creator/cf-backtester/internal/uploader"
+)
+
+// apiOperation is an API endpoint: the handler registered for it and its OpenAPI description
+type apiOperation struct {
+	Method  string
+	Path    string // Below the API prefix, as registered with the router
+	Handler func(*Server, http.ResponseWriter, *http.Request)
+	Tag     string
+	Summary string
+	Params  []apiParam // Path parameters default to integer IDs
+
+	Body     interface{} // JSON request body, as a value of its type
+	BodyType string      // Content type of a non-JSON request body
+
+	Status   int         // Success status, default 200
+	Response interface{} // Success response body, as a value of its type
+	CSV      bool        // format=csv responds with text/csv
+
+	// Error statuses with their body; nil bodies are ErrorResponse
+	Errors map[int]interface{}
+}
+
+// apiParam is a query or path parameter of an operation
+type apiParam struct {
+	Name        string
+	In          string // query or path
+	Type        string // string, integer, number or boolean
+	Required    bool
+	Description string
+}
+
+// queryParam is an optional query parameter
+func queryParam(name, typ, description string) apiParam {
+	return apiParam{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// requiredParam is a required query parameter
+func requiredParam(name, typ, description string) apiParam {
+	return apiParam{Name: name, In: "query", Type: typ, Required: true, Description: description}
+}
+
+// errorStatuses lists error statuses answered with an ErrorResponse
+func errorStatuses(statuses ...int) map[int]interface{} {
+	m := make(map[int]interface{}, len(statuses)+1)
+	for _, status := range statuses {
+		m[status] = nil
+	}
+	m[http.StatusInternalServerError] = nil
+	return m
+}
+
+// withError adds an error status with its own body to m
+func withError(m map[int]interface{}, status int, body interface{}) map[int]interface{} {
+	m[status] = body
+	return m
+}
+
+// strategyRef is the {id} of the strategy endpoints
+var strategyRef = apiParam{Name: "id", In: "path", Type: "string", Required: true, Description: "Strategy ID or name"}
+
+// importParams are the options of a candle import
+var importParams = []apiParam{
+	requiredParam("interval", "string", "Candle interval, e.g. 1h"),
+	queryParam("format", "string", "CSV format preset, e.g. generic or binance"),
+	queryParam("columns", "string", "Column mapping overriding the preset, e.g. timestamp=Date,volume=5"),
+	queryParam("delimiter", "string", "Delimiter overriding the preset (default: detect)"),
+	queryParam("time_unit", "string", "Timestamp unit: s, ms, us or iso (default: detect)"),
+	queryParam("strict", "boolean", "Abort on the first invalid row without importing anything"),
+	queryParam("repair", "string", "Gap repair: NONE, FFILL or INTERPOLATE"),
+}
+
+// apiOperations are the endpoints of the API, in the order they are documented
+var apiOperations = []apiOperation{
+	{
+		Method: "GET", Path: "/health", Handler: (*Server).healthCheck,
+		Tag: "system", Summary: "Health check",
+		Response: HealthStatus{},
+	},
+	{
+		Method: "GET", Path: "/openapi.json", Handler: (*Server).getOpenAPI,
+		Tag: "system", Summary: "OpenAPI document of the API",
+		Response: map[string]interface{}{},
+	},
+
+	// Instruments
+	{
+		Method: "GET", Path: "/instruments", Handler: (*Server).getInstruments,
+		Tag: "instruments", Summary: "List instruments",
+		Response: []models.Instrument{},
+		Errors:   errorStatuses(),
+	},
+	{
+		Method: "POST", Path: "/instruments", Handler: (*Server).createInstrument,
+		Tag: "instruments", Summary: "Create an instrument",
+		Body:   models.Instrument{},
+		Status: http.StatusCreated, Response: models.Instrument{},
+		Errors: errorStatuses(http.StatusBadRequest),
+	},
+	{
+		Method: "GET", Path: "/instruments/{id}/candles", Handler: (*Server).getCandles,
+		Tag: "candles", Summary: "Query candles, paged or downsampled, resampled if the interval is not stored",
+		Params: []apiParam{
+			requiredParam("interval", "string", "Candle interval, e.g. 1h"),
+			queryParam("from", "string", "Start, YYYY-MM-DD or Unix milliseconds (default: first candle)"),
+			queryParam("to", "string", "End, YYYY-MM-DD or Unix milliseconds (default: last candle)"),
+			queryParam("limit", "integer", "Candles per page, default 1000, at most 10000"),
+			queryParam("cursor", "integer", "next_cursor of the previous page"),
+			queryParam("max_points", "integer", "Downsample the whole range to this many points instead of paging"),
+			queryParam("downsample", "string", "Downsampling method: OHLC (default) or LTTB"),
+			queryParam("format", "string", "json (default) or csv"),
+		},
+		Response: CandlePage{}, CSV: true,
+		Errors: errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/candles", Handler: (*Server).importCandles,
+		Tag: "candles", Summary: "Import a candle CSV sent as the request body",
+		Params:   importParams,
+		BodyType: "text/csv",
+		Response: uploader.IngestReport{},
+		Errors: withError(withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+			http.StatusUnprocessableEntity, ImportError{}), http.StatusInternalServerError, ImportError{}),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/funding", Handler: (*Server).importFunding,
+		Tag: "candles", Summary: "Import a funding rate CSV sent as the request body",
+		BodyType: "text/csv",
+		Response: FundingImport{},
+		Errors: withError(withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+			http.StatusUnprocessableEntity, FundingImport{}), http.StatusInternalServerError, FundingImport{}),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/uploads", Handler: (*Server).createUpload,
+		Tag: "candles", Summary: "Upload a candle file (multipart) for a background import",
+		Params:   importParams,
+		BodyType: "multipart/form-data",
+		Status:   http.StatusAccepted, Response: UploadJob{},
+		Errors: errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/resample", Handler: (*Server).resampleCandles,
+		Tag: "candles", Summary: "Build and store candles of a higher interval",
+		Params: []apiParam{
+			requiredParam("from", "string", "Stored source interval, e.g. 1m"),
+			requiredParam("to", "string", "Interval to build, e.g. 1h"),
+			queryParam("start", "string", "Start date, YYYY-MM-DD (default: first candle)"),
+			queryParam("end", "string", "End date, YYYY-MM-DD (default: now)"),
+			queryParam("partial", "string", "Bars missing source candles: DROP (default) or KEEP"),
+		},
+		Response: resample.Report{},
+		Errors:   withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound), http.StatusInternalServerError, ResampleError{}),
+	},
+	{
+		Method: "GET", Path: "/instruments/{id}/quality", Handler: (*Server).getQuality,
+		Tag: "quality", Summary: "Latest data quality report of a series",
+		Params: []apiParam{
+			requiredParam("interval", "string", "Candle interval"),
+		},
+		Response: models.QualityReport{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/quality", Handler: (*Server).checkQuality,
+		Tag: "quality", Summary: "Check the data quality of a series and store the report",
+		Params: []apiParam{
+			requiredParam("interval", "string", "Candle interval"),
+			queryParam("from", "string", "Start date, YYYY-MM-DD (default: first candle)"),
+			queryParam("to", "string", "End date, YYYY-MM-DD (default: now)"),
+		},
+		Response: models.QualityReport{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "GET", Path: "/instruments/{id}/indicators", Handler: (*Server).getIndicators,
+		Tag: "indicators", Summary: "CF, ATR and ADX series of a stored strategy",
+		Params: []apiParam{
+			requiredParam("interval", "string", "Candle interval"),
+			requiredParam("strategy", "string", "Strategy name"),
+			queryParam("from", "string", "Start, YYYY-MM-DD or Unix milliseconds (default: first candle)"),
+			queryParam("to", "string", "End, YYYY-MM-DD or Unix milliseconds (default: now)"),
+			queryParam("repair", "string", "Gap repair: NONE, FFILL, INTERPOLATE or SPLIT"),
+		},
+		Response: IndicatorSeries{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "POST", Path: "/instruments/{id}/indicators", Handler: (*Server).evaluateIndicators,
+		Tag: "indicators", Summary: "CF, ATR and ADX series of a stored strategy or an inline config",
+		Body:     IndicatorRequest{},
+		Response: IndicatorSeries{},
+		Errors:   withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound), http.StatusUnprocessableEntity, StrategyError{}),
+	},
+
+	// Candle file uploads
+	{
+		Method: "GET", Path: "/uploads", Handler: (*Server).getUploads,
+		Tag: "candles", Summary: "List upload jobs, newest first",
+		Response: []UploadJob{},
+	},
+	{
+		Method: "GET", Path: "/uploads/{id}", Handler: (*Server).getUpload,
+		Tag: "candles", Summary: "Upload job progress and ingest report",
+		Response: UploadJob{},
+		Errors:   map[int]interface{}{http.StatusBadRequest: nil, http.StatusNotFound: nil},
+	},
+
+	// Strategies
+	{
+		Method: "GET", Path: "/strategies", Handler: (*Server).getStrategies,
+		Tag: "strategies", Summary: "List strategies",
+		Response: []models.Strategy{},
+		Errors:   errorStatuses(),
+	},
+	{
+		Method: "POST", Path: "/strategies", Handler: (*Server).createStrategy,
+		Tag: "strategies", Summary: "Create a strategy",
+		Body:   models.Strategy{},
+		Status: http.StatusCreated, Response: models.Strategy{},
+		Errors: withError(errorStatuses(http.StatusBadRequest, http.StatusConflict), http.StatusUnprocessableEntity, StrategyError{}),
+	},
+	{
+		Method: "GET", Path: "/strategies/{id}", Handler: (*Server).getStrategy,
+		Tag: "strategies", Summary: "Get a strategy",
+		Params:   []apiParam{strategyRef},
+		Response: models.Strategy{},
+		Errors:   errorStatuses(http.StatusNotFound),
+	},
+	{
+		Method: "PUT", Path: "/strategies/{id}", Handler: (*Server).updateStrategy,
+		Tag: "strategies", Summary: "Rename a strategy or replace its config, adding a config version",
+		Params:   []apiParam{strategyRef},
+		Body:     StrategyUpdate{},
+		Response: models.Strategy{},
+		Errors:   withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict), http.StatusUnprocessableEntity, StrategyError{}),
+	},
+	{
+		Method: "DELETE", Path: "/strategies/{id}", Handler: (*Server).deleteStrategy,
+		Tag: "strategies", Summary: "Delete a strategy without backtest runs",
+		Params:   []apiParam{strategyRef},
+		Response: models.Strategy{},
+		Errors:   errorStatuses(http.StatusNotFound, http.StatusConflict),
+	},
+	{
+		Method: "POST", Path: "/strategies/{id}/clone", Handler: (*Server).cloneStrategy,
+		Tag: "strategies", Summary: "Copy a strategy under a new name",
+		Params: []apiParam{strategyRef},
+		Body:   StrategyUpdate{},
+		Status: http.StatusCreated, Response: models.Strategy{},
+		Errors: withError(errorStatuses(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict), http.StatusUnprocessableEntity, StrategyError{}),
+	},
+	{
+		Method: "GET", Path: "/strategies/{id}/versions", Handler: (*Server).getStrategyVersions,
+		Tag: "strategies", Summary: "List the config versions of a strategy",
+		Params:   []apiParam{strategyRef},
+		Response: []models.StrategyVersion{},
+		Errors:   errorStatuses(http.StatusNotFound),
+	},
+	{
+		Method: "GET", Path: "/strategies/{id}/versions/{version}", Handler: (*Server).getStrategyVersion,
+		Tag: "strategies", Summary: "Get a config version of a strategy",
+		Params: []apiParam{
+			strategyRef,
+			{Name: "version", In: "path", Type: "integer", Required: true, Description: "Config version, from 1"},
+		},
+		Response: models.StrategyVersion{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "GET", Path: "/strategies/{id}/diff", Handler: (*Server).diffStrategyVersions,
+		Tag: "strategies", Summary: "Config changes between two versions of a strategy",
+		Params: []apiParam{
+			strategyRef,
+			queryParam("from", "integer", "Version to compare from (default: the version before to)"),
+			queryParam("to", "integer", "Version to compare to (default: the current version)"),
+		},
+		Response: StrategyDiff{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+
+	// Backtests
+	{
+		Method: "POST", Path: "/backtests", Handler: (*Server).runBacktest,
+		Tag: "backtests", Summary: "Run a backtest",
+		Body:     BacktestRequest{},
+		Response: models.BacktestResult{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity),
+	},
+	{
+		Method: "GET", Path: "/backtests", Handler: (*Server).getBacktests,
+		Tag: "backtests", Summary: "List backtest runs, filtered, sorted and paged",
+		Params: []apiParam{
+			queryParam("instrument_id", "integer", "Runs of this instrument"),
+			queryParam("strategy", "string", "Runs of this strategy name"),
+			queryParam("status", "string", "DONE or FAILED"),
+			queryParam("from", "string", "Runs whose period ends at or after, YYYY-MM-DD or Unix milliseconds"),
+			queryParam("to", "string", "Runs whose period starts at or before, YYYY-MM-DD or Unix milliseconds"),
+			queryParam("sort", "string", "Result column such as total_return or created_at, or a metrics key (default: created_at)"),
+			queryParam("order", "string", "asc or desc (default)"),
+			queryParam("limit", "integer", "Runs per page, default 50, at most 500"),
+			queryParam("cursor", "string", "next_cursor of the previous page"),
+		},
+		Response: BacktestPage{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "GET", Path: "/backtests/{id}", Handler: (*Server).getBacktest,
+		Tag: "backtests", Summary: "Get a backtest run with its config snapshot and metrics",
+		Params: []apiParam{
+			queryParam("equity", "boolean", "Include the equity curve"),
+		},
+		Response: BacktestDetail{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "GET", Path: "/backtests/{id}/trades", Handler: (*Server).getBacktestTrades,
+		Tag: "backtests", Summary: "Trades of a backtest run in entry order, paged",
+		Params: []apiParam{
+			queryParam("limit", "integer", "Trades per page, default 100, at most 10000"),
+			queryParam("cursor", "integer", "next_cursor of the previous page"),
+			queryParam("format", "string", "json (default) or csv; csv exports all trades unless limit or cursor is given"),
+		},
+		Response: TradePage{}, CSV: true,
+		Errors: errorStatuses(http.StatusBadRequest, http.StatusNotFound),
+	},
+	{
+		Method: "POST", Path: "/backtests/{id}/rerun", Handler: (*Server).rerunBacktest,
+		Tag: "backtests", Summary: "Run a backtest again with its original strategy version and settings",
+		Response: models.BacktestResult{},
+		Errors:   errorStatuses(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+	},
+}
+
+// apiRoutes registers the API endpoints on r, relative to its path prefix
+func (s *Server) apiRoutes(r *mux.Router) {
+	for i := range apiOperations {
+		op := &apiOperations[i]
+		r.HandleFunc(op.Path, func(w http.ResponseWriter, r *http.Request) {
+			op.Handler(s, w, r)
+		}).Methods(op.Method)
+	}
+}
@@ -1,59 +1,65 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/langley-creator/cf-backtester/internal/backtester"
 	"github.com/langley-creator/cf-backtester/internal/database"
 	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/web"
 )
 
+// apiPrefix is the path prefix of the current API version
+const apiPrefix = "/api/v1"
+
 // Server represents the API server
 type Server struct {
-	db     *database.DB
-	router *mux.Router
-	port   string
+	db      database.Store
+	router  *mux.Router
+	port    string
+	uploads *uploadJobs
+	openAPI map[string]interface{} // OpenAPI document of the routes
 }
 
 // NewServer creates a new API server
-func NewServer(db *database.DB, port string) *Server {
+func NewServer(db database.Store, port string) *Server {
 	s := &Server{
-		db:     db,
-		router: mux.NewRouter(),
-		port:   port,
+		db:      db,
+		router:  mux.NewRouter(),
+		port:    port,
+		uploads: &uploadJobs{},
+		openAPI: buildOpenAPI(),
 	}
 	s.setupRoutes()
 	return s
 }
 
 // setupRoutes configures API routes
+// The API is served under /api/v1; /api is a deprecated alias of v1 for existing clients
 func (s *Server) setupRoutes() {
 	// CORS middleware
 	s.router.Use(corsMiddleware)
 
-	// Health check
-	s.router.HandleFunc("/api/health", s.healthCheck).Methods("GET")
-
-	// Instruments
-	s.router.HandleFunc("/api/instruments", s.getInstruments).Methods("GET")
-	s.router.HandleFunc("/api/instruments", s.createInstrument).Methods("POST")
+	s.apiRoutes(s.router.PathPrefix(apiPrefix).Subrouter())
 
-	// Strategies
-	s.router.HandleFunc("/api/strategies", s.getStrategies).Methods("GET")
-	s.router.HandleFunc("/api/strategies", s.createStrategy).Methods("POST")
-	s.router.HandleFunc("/api/strategies/{name}", s.getStrategy).Methods("GET")
+	legacy := s.router.PathPrefix("/api").Subrouter()
+	legacy.Use(deprecatedMiddleware)
+	s.apiRoutes(legacy)
 
-	// Backtests
-	s.router.HandleFunc("/api/backtests", s.runBacktest).Methods("POST")
-	s.router.HandleFunc("/api/backtests", s.getBacktests).Methods("GET")
-	s.router.HandleFunc("/api/backtests/{id}", s.getBacktest).Methods("GET")
-	s.router.HandleFunc("/api/backtests/{id}/trades", s.getBacktestTrades).Methods("GET")
+	// Admin UI
+	admin := http.FileServer(http.FS(web.Admin()))
+	s.router.PathPrefix("/admin/").Handler(http.StripPrefix("/admin", admin)).Methods("GET")
+	s.router.Handle("/admin", http.RedirectHandler("/admin/", http.StatusMovedPermanently)).Methods("GET")
+	s.router.Handle("/", http.RedirectHandler("/admin/", http.StatusFound)).Methods("GET")
 }
 
 // Start starts the API server
@@ -62,6 +68,17 @@ func (s *Server) Start() error {
 	return http.ListenAndServe(":"+s.port, s.router)
 }
 
+// deprecatedMiddleware marks responses of the unversioned /api alias as deprecated and links
+// the /api/v1 endpoint that replaces them
+func deprecatedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor := apiPrefix + strings.TrimPrefix(r.URL.Path, "/api")
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
+
 // corsMiddleware adds CORS headers
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,11 +95,17 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// HealthStatus is the response of the health check
+type HealthStatus struct {
+	Status string `json:"status"`
+	Time   string `json:"time"` // RFC 3339
+}
+
 // healthCheck returns server health status
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
-	responseJSON(w, http.StatusOK, map[string]string{
-		"status": "healthy",
-		"time":   time.Now().Format(time.RFC3339),
+	responseJSON(w, http.StatusOK, HealthStatus{
+		Status: "healthy",
+		Time:   time.Now().Format(time.RFC3339),
 	})
 }
 
@@ -93,6 +116,9 @@ func (s *Server) getInstruments(w http.ResponseWriter, r *http.Request) {
 		responseError(w, http.StatusInternalServerError, "Failed to fetch instruments")
 		return
 	}
+	if instruments == nil {
+		instruments = []*models.Instrument{}
+	}
 	responseJSON(w, http.StatusOK, instruments)
 }
 
@@ -112,48 +138,27 @@ func (s *Server) createInstrument(w http.ResponseWriter, r *http.Request) {
 	responseJSON(w, http.StatusCreated, instrument)
 }
 
-// getStrategies returns all strategies
-func (s *Server) getStrategies(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetAllStrategies in database layer
-	responseJSON(w, http.StatusOK, []string{"capital_flow"})
-}
-
-// createStrategy creates a new strategy
-func (s *Server) createStrategy(w http.ResponseWriter, r *http.Request) {
-	var strategy models.Strategy
-	if err := json.NewDecoder(r.Body).Decode(&strategy); err != nil {
-		responseError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	if err := s.db.SaveStrategy(&strategy); err != nil {
-		responseError(w, http.StatusInternalServerError, "Failed to create strategy")
-		return
-	}
-
-	responseJSON(w, http.StatusCreated, strategy)
-}
-
-// getStrategy returns a specific strategy
-func (s *Server) getStrategy(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	name := vars["name"]
-
-	strategy, err := s.db.GetStrategyByName(name)
-	if err != nil {
-		responseError(w, http.StatusNotFound, "Strategy not found")
-		return
-	}
-
-	responseJSON(w, http.StatusOK, strategy)
-}
-
 // BacktestRequest represents a backtest request
 type BacktestRequest struct {
 	InstrumentID int64  `json:"instrument_id"`
+	Interval     string `json:"interval"`
 	StrategyName string `json:"strategy_name"`
 	StartDate    string `json:"start_date"`
 	EndDate      string `json:"end_date"`
+
+	// Stored config version of the strategy to run, default: the current one
+	StrategyVersion int `json:"strategy_version,omitempty"`
+
+	// Data quality policy: IGNORE, WARN (default) or REFUSE
+	QualityPolicy string `json:"quality_policy,omitempty"`
+
+	// Gap repair on load: NONE (default), FFILL, INTERPOLATE or SPLIT
+	RepairPolicy string `json:"repair_policy,omitempty"`
+
+	// Return the per-candle debug trace in the result, limited to the first
+	// DebugMaxCandles candles (0 = all)
+	Debug           bool `json:"debug,omitempty"`
+	DebugMaxCandles int  `json:"debug_max_candles,omitempty"`
 }
 
 // runBacktest executes a backtest
@@ -164,6 +169,11 @@ func (s *Server) runBacktest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return
+	}
+
 	// Parse dates
 	startTime, err := time.Parse("2006-01-02", req.StartDate)
 	if err != nil {
@@ -177,47 +187,48 @@ func (s *Server) runBacktest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Run backtest
-	engine := backtester.NewEngine(s.db, req.StrategyName)
-	result, err := engine.Run(req.InstrumentID, startTime, endTime)
+	policy, err := quality.ParsePolicy(req.QualityPolicy)
 	if err != nil {
-		responseError(w, http.StatusInternalServerError, fmt.Sprintf("Backtest failed: %v", err))
+		responseError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	responseJSON(w, http.StatusOK, result)
-}
-
-// getBacktests returns all backtest results
-func (s *Server) getBacktests(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetAllBacktests in database layer
-	responseJSON(w, http.StatusOK, []map[string]interface{}{})
-}
-
-// getBacktest returns a specific backtest result
-func (s *Server) getBacktest(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	repair, err := quality.ParseRepairPolicy(req.RepairPolicy)
 	if err != nil {
-		responseError(w, http.StatusBadRequest, "Invalid backtest ID")
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.DebugMaxCandles < 0 {
+		responseError(w, http.StatusBadRequest, "debug_max_candles must not be negative")
 		return
 	}
 
-	// TODO: Implement GetBacktestByID in database layer
-	responseJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+	// Run backtest
+	engine := backtester.NewEngine(s.db, req.StrategyName)
+	engine.SetStrategyVersion(req.StrategyVersion)
+	engine.SetQualityPolicy(policy)
+	engine.SetRepairPolicy(repair)
+	if req.Debug {
+		engine.EnableDebug(req.DebugMaxCandles)
+	}
+	s.executeBacktest(w, engine, req.InstrumentID, req.Interval, startTime, endTime)
 }
 
-// getBacktestTrades returns trades for a specific backtest
-func (s *Server) getBacktestTrades(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 64)
+// executeBacktest runs a configured engine and responds with the result
+func (s *Server) executeBacktest(w http.ResponseWriter, engine *backtester.Engine, instrumentID int64, interval string, startTime, endTime time.Time) {
+	result, err := engine.Run(instrumentID, interval, startTime, endTime)
 	if err != nil {
-		responseError(w, http.StatusBadRequest, "Invalid backtest ID")
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, quality.ErrDirtyData):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, sql.ErrNoRows):
+			status = http.StatusNotFound // Strategy or strategy version
+		}
+		responseError(w, status, fmt.Sprintf("Backtest failed: %v", err))
 		return
 	}
 
-	// TODO: Implement GetTradesByBacktestID in database layer
-	responseJSON(w, http.StatusOK, []map[string]interface{}{})
+	responseJSON(w, http.StatusOK, result)
 }
 
 // responseJSON writes JSON response
@@ -227,7 +238,12 @@ func responseJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// ErrorResponse is the body of error responses
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
 // responseError writes error response
 func responseError(w http.ResponseWriter, status int, message string) {
-	responseJSON(w, status, map[string]string{"error": message})
+	responseJSON(w, status, ErrorResponse{Error: message})
 }
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/backtester"
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// maxStrategyName is the length of the strategies.name column
+const maxStrategyName = 100
+
+// StrategyUpdate changes a strategy, or the copy made by a clone; omitted fields are kept
+type StrategyUpdate struct {
+	Name   string                 `json:"name,omitempty"`
+	Config *models.StrategyConfig `json:"config,omitempty"`
+}
+
+// StrategyError is the response to an invalid strategy, listing the invalid fields
+type StrategyError struct {
+	Error  string                  `json:"error"`
+	Fields []backtester.FieldError `json:"fields"`
+}
+
+// getStrategies returns all strategies ordered by name
+func (s *Server) getStrategies(w http.ResponseWriter, r *http.Request) {
+	strategies, err := s.db.GetAllStrategies()
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch strategies")
+		return
+	}
+	if strategies == nil {
+		strategies = []*models.Strategy{}
+	}
+	responseJSON(w, http.StatusOK, strategies)
+}
+
+// createStrategy creates a new strategy after validating its name and config
+// Responds 409 if the name is taken and 422 with the invalid fields
+func (s *Server) createStrategy(w http.ResponseWriter, r *http.Request) {
+	var strategy models.Strategy
+	if err := json.NewDecoder(r.Body).Decode(&strategy); err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	strategy.Name = strings.TrimSpace(strategy.Name)
+	if !s.validStrategy(w, &strategy, 0) {
+		return
+	}
+
+	if err := s.db.CreateStrategy(&strategy); err != nil {
+		if errors.Is(err, database.ErrConflict) {
+			responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q already exists", strategy.Name))
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to create strategy")
+		}
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, strategy)
+}
+
+// getStrategy returns a strategy by ID or name
+func (s *Server) getStrategy(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	responseJSON(w, http.StatusOK, strategy)
+}
+
+// updateStrategy renames a strategy or replaces its config, sent as a StrategyUpdate body
+func (s *Server) updateStrategy(w http.ResponseWriter, r *http.Request) {
+	var update StrategyUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	strategy, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	update.apply(strategy)
+	if !s.validStrategy(w, strategy, strategy.ID) {
+		return
+	}
+
+	if err := s.db.UpdateStrategy(strategy); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			responseError(w, http.StatusNotFound, "Strategy not found")
+		case errors.Is(err, database.ErrConflict):
+			responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q already exists", strategy.Name))
+		default:
+			responseError(w, http.StatusInternalServerError, "Failed to update strategy")
+		}
+		return
+	}
+
+	responseJSON(w, http.StatusOK, strategy)
+}
+
+// cloneStrategy saves a copy of a strategy under a new name, with the changes of an optional
+// StrategyUpdate body; the name defaults to the original name with a "-copy" suffix
+func (s *Server) cloneStrategy(w http.ResponseWriter, r *http.Request) {
+	var update StrategyUpdate
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	original, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	clone := &models.Strategy{Name: original.Name + "-copy", Config: original.Config}
+	update.apply(clone)
+	if !s.validStrategy(w, clone, 0) {
+		return
+	}
+
+	if err := s.db.CreateStrategy(clone); err != nil {
+		if errors.Is(err, database.ErrConflict) {
+			responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q already exists", clone.Name))
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to create strategy")
+		}
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, clone)
+}
+
+// deleteStrategy deletes a strategy and returns it
+// Strategies with backtest runs are kept, so the runs still name their strategy (409)
+func (s *Server) deleteStrategy(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	runs, err := s.db.ListBacktestResults(&database.BacktestFilter{StrategyID: int64(strategy.ID), Limit: 1})
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch backtests")
+		return
+	}
+	if len(runs) > 0 {
+		responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q has backtest runs and cannot be deleted", strategy.Name))
+		return
+	}
+
+	if err := s.db.DeleteStrategy(int64(strategy.ID)); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			responseError(w, http.StatusNotFound, "Strategy not found")
+		case errors.Is(err, database.ErrConflict):
+			responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q has backtest runs and cannot be deleted", strategy.Name))
+		default:
+			responseError(w, http.StatusInternalServerError, "Failed to delete strategy")
+		}
+		return
+	}
+
+	responseJSON(w, http.StatusOK, strategy)
+}
+
+// apply changes strategy by the fields set in u
+func (u *StrategyUpdate) apply(strategy *models.Strategy) {
+	if name := strings.TrimSpace(u.Name); name != "" {
+		strategy.Name = name
+	}
+	if u.Config != nil {
+		strategy.Config = *u.Config
+	}
+}
+
+// strategyByRef loads a strategy by numeric ID or by name, responding with an error if it cannot
+func (s *Server) strategyByRef(w http.ResponseWriter, ref string) (*models.Strategy, bool) {
+	var strategy *models.Strategy
+	var err error
+	if id, parseErr := strconv.ParseInt(ref, 10, 64); parseErr == nil {
+		strategy, err = s.db.GetStrategyByID(id)
+	} else {
+		strategy, err = s.db.GetStrategyByName(ref)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Strategy not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch strategy")
+		}
+		return nil, false
+	}
+	return strategy, true
+}
+
+// validStrategy checks the name and config of a strategy saved with ID id (0 for a new one),
+// responding 422 with the invalid fields or 409 if another strategy has the name
+// A name taken after the check is reported by the store as database.ErrConflict
+func (s *Server) validStrategy(w http.ResponseWriter, strategy *models.Strategy, id int) bool {
+	var fields []backtester.FieldError
+	switch {
+	case strategy.Name == "":
+		fields = append(fields, backtester.FieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString(strategy.Name) > maxStrategyName:
+		fields = append(fields, backtester.FieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxStrategyName)})
+	case strings.Contains(strategy.Name, "/"):
+		fields = append(fields, backtester.FieldError{Field: "name", Message: "must not contain /"})
+	default:
+		// Numbers are strategy IDs in URLs
+		if _, err := strconv.ParseInt(strategy.Name, 10, 64); err == nil {
+			fields = append(fields, backtester.FieldError{Field: "name", Message: "must not be a number"})
+		}
+	}
+
+	var configErr *backtester.ConfigError
+	if err := backtester.ValidateConfig(&strategy.Config); errors.As(err, &configErr) {
+		fields = append(fields, configErr.Fields...)
+	}
+	if len(fields) > 0 {
+		responseJSON(w, http.StatusUnprocessableEntity, StrategyError{Error: "Invalid strategy", Fields: fields})
+		return false
+	}
+
+	existing, err := s.db.GetStrategyByName(strategy.Name)
+	switch {
+	case err == nil && existing.ID != id:
+		responseError(w, http.StatusConflict, fmt.Sprintf("Strategy %q already exists", strategy.Name))
+		return false
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		responseError(w, http.StatusInternalServerError, "Failed to fetch strategy")
+		return false
+	}
+	return true
+}
+
+// StrategyDiff lists the config fields that changed between two versions of a strategy
+type StrategyDiff struct {
+	StrategyID int64                 `json:"strategy_id"`
+	Name       string                `json:"name"`
+	From       int                   `json:"from"`
+	To         int                   `json:"to"`
+	Changes    []models.ConfigChange `json:"changes"`
+}
+
+// getStrategyVersions returns all config versions of a strategy, oldest first
+func (s *Server) getStrategyVersions(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	versions, err := s.db.GetStrategyVersions(int64(strategy.ID))
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to fetch strategy versions")
+		return
+	}
+	if versions == nil {
+		versions = []*models.StrategyVersion{}
+	}
+	responseJSON(w, http.StatusOK, versions)
+}
+
+// getStrategyVersion returns a config version of a strategy
+func (s *Server) getStrategyVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	number, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid strategy version")
+		return
+	}
+
+	strategy, ok := s.strategyByRef(w, vars["id"])
+	if !ok {
+		return
+	}
+	version, ok := s.strategyVersion(w, strategy, number)
+	if !ok {
+		return
+	}
+	responseJSON(w, http.StatusOK, version)
+}
+
+// diffStrategyVersions returns the config changes between two versions of a strategy
+// Query parameters: from (default: the version before to) and to (default: the current version)
+func (s *Server) diffStrategyVersions(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := s.strategyByRef(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	diff := &StrategyDiff{StrategyID: int64(strategy.ID), Name: strategy.Name, To: strategy.Version}
+	var err error
+	if v := query.Get("to"); v != "" {
+		if diff.To, err = strconv.Atoi(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid to version")
+			return
+		}
+	}
+	diff.From = max(diff.To-1, 1)
+	if v := query.Get("from"); v != "" {
+		if diff.From, err = strconv.Atoi(v); err != nil {
+			responseError(w, http.StatusBadRequest, "Invalid from version")
+			return
+		}
+	}
+
+	from, ok := s.strategyVersion(w, strategy, diff.From)
+	if !ok {
+		return
+	}
+	to, ok := s.strategyVersion(w, strategy, diff.To)
+	if !ok {
+		return
+	}
+
+	diff.Changes = models.DiffConfig(from.Config, to.Config)
+	responseJSON(w, http.StatusOK, diff)
+}
+
+// strategyVersion loads a config version of a strategy, responding with an error if it cannot
+func (s *Server) strategyVersion(w http.ResponseWriter, strategy *models.Strategy, number int) (*models.StrategyVersion, bool) {
+	version, err := s.db.GetStrategyVersion(int64(strategy.ID), number)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, fmt.Sprintf("Strategy %q has no version %d", strategy.Name, number))
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch strategy version")
+		}
+		return nil, false
+	}
+	return version, true
+}
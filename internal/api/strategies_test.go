@@ -0,0 +1,81 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// staleStore hides strategy "cf" from lookups by name and all backtest runs, as if another
+// request had created them after the handler checked
+type staleStore struct {
+	database.Store
+}
+
+func (s staleStore) GetStrategyByName(name string) (*models.Strategy, error) {
+	if name == "cf" {
+		return nil, sql.ErrNoRows
+	}
+	return s.Store.GetStrategyByName(name)
+}
+
+func (s staleStore) ListBacktestResults(*database.BacktestFilter) ([]*models.BacktestResult, error) {
+	return nil, nil
+}
+
+// TestStrategyStoreConflicts checks that conflicts missed by the handler checks but reported
+// by the store respond 409
+func TestStrategyStoreConflicts(t *testing.T) {
+	store := database.NewMemoryStore()
+	var config models.StrategyConfig
+	if err := json.Unmarshal([]byte(testConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	cf := &models.Strategy{Name: "cf", Config: config}
+	other := &models.Strategy{Name: "other", Config: config}
+	for _, strategy := range []*models.Strategy{cf, other} {
+		if err := store.CreateStrategy(strategy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	instrument := &models.Instrument{Symbol: "BTCUSDT", Exchange: "BINANCE"}
+	if err := store.SaveInstrument(instrument); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+	if err := store.SaveBacktestResult(&models.BacktestResult{InstrumentID: int64(instrument.ID), StrategyID: int64(cf.ID),
+		StartTime: start, EndTime: start.Add(24 * time.Hour), Status: models.BacktestDone}); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(staleStore{store}, "0")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "create", method: "POST", path: "/strategies", body: `{"name":"cf","config":` + testConfig + `}`},
+		{name: "clone", method: "POST", path: "/strategies/other/clone", body: `{"name":"cf"}`},
+		{name: "rename", method: "PUT", path: "/strategies/other", body: `{"name":"cf"}`},
+		{name: "delete with backtests", method: "DELETE", path: "/strategies/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, apiPrefix+tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusConflict {
+				t.Errorf("status %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+			}
+		})
+	}
+}
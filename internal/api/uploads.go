@@ -0,0 +1,294 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/langley-creator/cf-backtester/internal/uploader"
+)
+
+// Upload job states
+const (
+	UploadQueued  = "QUEUED"  // Waiting for another import to finish
+	UploadRunning = "RUNNING" // Importing; the report shows the progress so far
+	UploadDone    = "DONE"
+	UploadFailed  = "FAILED"
+)
+
+// maxUploadJobs is the number of finished upload jobs kept for polling
+const maxUploadJobs = 100
+
+// maxUploadField limits the size of a form field in an upload
+const maxUploadField = 4 << 10
+
+// UploadJob is a candle file import running in the background
+type UploadJob struct {
+	ID           int64                  `json:"id"`
+	InstrumentID int64                  `json:"instrument_id"`
+	Interval     string                 `json:"interval"`
+	FileName     string                 `json:"file_name"`
+	Size         int64                  `json:"size"` // Bytes uploaded
+	Status       string                 `json:"status"`
+	Progress     float64                `json:"progress"`         // Fraction of the file imported, 0 to 1
+	Report       *uploader.IngestReport `json:"report,omitempty"` // Progress so far while running, final when done
+	Error        string                 `json:"error,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	FinishedAt   *time.Time             `json:"finished_at,omitempty"`
+}
+
+// uploadJobs keeps the upload jobs of the server and runs them one at a time,
+// so imports do not compete for the store
+type uploadJobs struct {
+	mu   sync.Mutex
+	next int64
+	jobs []*UploadJob // In creation order
+	run  sync.Mutex
+}
+
+// add registers a job, assigning its ID, and forgets the oldest finished jobs beyond maxUploadJobs
+func (j *uploadJobs) add(job *UploadJob) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.next++
+	job.ID = j.next
+	j.jobs = append(j.jobs, job)
+
+	finished := 0
+	for _, other := range j.jobs {
+		if other.FinishedAt != nil {
+			finished++
+		}
+	}
+	kept := j.jobs[:0]
+	for _, other := range j.jobs {
+		if other.FinishedAt != nil && finished > maxUploadJobs {
+			finished--
+			continue
+		}
+		kept = append(kept, other)
+	}
+	j.jobs = kept
+}
+
+// update changes a job under the lock
+func (j *uploadJobs) update(job *UploadJob, fn func(job *UploadJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(job)
+}
+
+// get returns a copy of a job
+func (j *uploadJobs) get(id int64) (UploadJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, job := range j.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return UploadJob{}, false
+}
+
+// list returns copies of all jobs, newest first
+func (j *uploadJobs) list() []UploadJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	jobs := make([]UploadJob, 0, len(j.jobs))
+	for i := len(j.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *j.jobs[i])
+	}
+	return jobs
+}
+
+// createUpload accepts a candle file as multipart/form-data and imports it in the background
+// The file part (any field name) may be CSV, Parquet or Arrow, gzip-compressed (.csv.gz) or a
+// zip of such files, detected from its file name. The other fields, or query parameters, take
+// the options of the import endpoint: interval (required), format, columns, delimiter,
+// time_unit, strict and repair
+// Responds 202 with the job; poll GET /api/v1/uploads/{id} for progress and the ingest report
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instrumentID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid instrument ID")
+		return
+	}
+
+	// Fail before a possibly large upload is read
+	if _, err := s.db.GetInstrumentByID(instrumentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responseError(w, http.StatusNotFound, "Instrument not found")
+		} else {
+			responseError(w, http.StatusInternalServerError, "Failed to fetch instrument")
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	parts, err := r.MultipartReader()
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Expected a multipart/form-data upload")
+		return
+	}
+
+	// Stream the file to disk as it arrives; the import reads it from there
+	options := r.URL.Query()
+	var spool *os.File
+	var fileName string
+	var size int64
+	defer func() {
+		if spool != nil {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+	}()
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+			return
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadField))
+			if err != nil {
+				responseError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+				return
+			}
+			options.Set(part.FormName(), string(value))
+			continue
+		}
+		if spool != nil {
+			responseError(w, http.StatusBadRequest, "Upload one file at a time")
+			return
+		}
+
+		fileName = path.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+		if spool, err = os.CreateTemp("", "upload-*-"+safeFileName(fileName)); err != nil {
+			responseError(w, http.StatusInternalServerError, "Failed to store upload")
+			return
+		}
+		if size, err = io.Copy(spool, part); err != nil {
+			responseError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read upload: %v", err))
+			return
+		}
+	}
+	if spool == nil {
+		responseError(w, http.StatusBadRequest, "No file in upload")
+		return
+	}
+
+	interval := options.Get("interval")
+	if interval == "" {
+		responseError(w, http.StatusBadRequest, "Interval is required")
+		return
+	}
+	csvUploader, err := s.newUploader(options)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := spool.Close(); err != nil {
+		responseError(w, http.StatusInternalServerError, "Failed to store upload")
+		return
+	}
+	filePath := spool.Name()
+	spool = nil // Removed by the job
+
+	job := &UploadJob{
+		InstrumentID: instrumentID,
+		Interval:     interval,
+		FileName:     fileName,
+		Size:         size,
+		Status:       UploadQueued,
+		CreatedAt:    time.Now(),
+	}
+	s.uploads.add(job)
+	go s.runUpload(job, csvUploader, filePath)
+
+	snapshot, _ := s.uploads.get(job.ID)
+	w.Header().Set("Location", fmt.Sprintf("%s/uploads/%d", apiPrefix, job.ID))
+	responseJSON(w, http.StatusAccepted, snapshot)
+}
+
+// runUpload imports the file of a job and removes it
+func (s *Server) runUpload(job *UploadJob, csvUploader *uploader.CSVUploader, filePath string) {
+	defer os.Remove(filePath)
+
+	s.uploads.run.Lock()
+	defer s.uploads.run.Unlock()
+
+	s.uploads.update(job, func(job *UploadJob) { job.Status = UploadRunning })
+	csvUploader.Progress = func(progress uploader.IngestReport) {
+		// The uploader keeps appending to the rejected rows
+		progress.Rejected = append([]uploader.RejectedRow(nil), progress.Rejected...)
+		s.uploads.update(job, func(job *UploadJob) {
+			job.Report = &progress
+			job.Progress = progress.Completion()
+		})
+	}
+
+	// The temporary file keeps the extension of the uploaded file name
+	report, err := csvUploader.UploadFile(filePath, job.InstrumentID, job.Interval)
+	s.uploads.update(job, func(job *UploadJob) {
+		finished := time.Now()
+		job.FinishedAt = &finished
+		job.Report = report
+		if err != nil {
+			job.Status = UploadFailed
+			job.Error = strings.ReplaceAll(err.Error(), filePath, job.FileName)
+			return
+		}
+		job.Status = UploadDone
+		job.Progress = 1
+	})
+}
+
+// getUploads returns the upload jobs, newest first
+func (s *Server) getUploads(w http.ResponseWriter, r *http.Request) {
+	responseJSON(w, http.StatusOK, s.uploads.list())
+}
+
+// getUpload returns an upload job with its progress or final ingest report
+func (s *Server) getUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid upload ID")
+		return
+	}
+
+	job, ok := s.uploads.get(id)
+	if !ok {
+		responseError(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+	responseJSON(w, http.StatusOK, job)
+}
+
+// safeFileName replaces characters that do not belong in a temporary file name
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+)
+
+// unreadBody fails the test if the request body is read
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read([]byte) (int, error) {
+	b.t.Error("upload for an unknown instrument was read")
+	return 0, http.ErrBodyReadAfterClose
+}
+
+// TestUploadUnknownInstrument checks that an upload for an unknown instrument fails before
+// its body is read
+func TestUploadUnknownInstrument(t *testing.T) {
+	server := NewServer(database.NewMemoryStore(), "0")
+	req := httptest.NewRequest("POST", apiPrefix+"/instruments/9/uploads?interval=1h", unreadBody{t})
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body)
+	}
+}
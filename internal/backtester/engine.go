@@ -1,171 +1,446 @@
 package backtester
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
+	"github.com/langley-creator/cf-backtester/internal/database"
 	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/internal/resample"
 	"github.com/langley-creator/cf-backtester/internal/strategy"
 )
 
 // Engine manages backtesting execution
 type Engine struct {
-	db           *models.DB
-	strategyName string
-	cfCalculator *strategy.CFCalculator
-	atrCalc      *strategy.ATRCalculator
-	adxCalc      *strategy.ADXCalculator
+	db              database.Store
+	strategyName    string
+	strategyVersion int // 0 for the current config
+
+	debug           bool
+	debugMaxCandles int
+
+	qualityPolicy string
+	repairPolicy  string
 }
 
+// initialBalance is the account balance a backtest starts with
+const initialBalance = 10000.0
+
 // NewEngine creates a new backtesting engine
-func NewEngine(db *models.DB, strategyName string) *Engine {
+func NewEngine(db database.Store, strategyName string) *Engine {
 	return &Engine{
-		db:           db,
-		strategyName: strategyName,
+		db:            db,
+		strategyName:  strategyName,
+		qualityPolicy: quality.PolicyWarn,
+		repairPolicy:  quality.RepairNone,
 	}
 }
 
-// Run executes backtesting for the specified instrument and time range
-func (e *Engine) Run(instrumentID int64, startTime, endTime time.Time) (*models.BacktestResult, error) {
+// SetStrategyVersion runs a stored config version of the strategy instead of its current config
+func (e *Engine) SetStrategyVersion(version int) {
+	e.strategyVersion = version
+}
+
+// EnableDebug records a per-candle debug trace in the result
+// maxCandles limits the trace length (0 = no limit)
+func (e *Engine) EnableDebug(maxCandles int) {
+	e.debug = true
+	e.debugMaxCandles = maxCandles
+}
+
+// SetQualityPolicy sets how the candle range is checked before running
+// (quality.PolicyIgnore, quality.PolicyWarn or quality.PolicyRefuse; default PolicyWarn)
+func (e *Engine) SetQualityPolicy(policy string) {
+	e.qualityPolicy = policy
+}
+
+// SetRepairPolicy sets how gaps in the loaded candles are repaired
+// (quality.RepairNone, RepairForwardFill, RepairInterpolate or RepairSplit; default RepairNone)
+// Candles repaired at import are already part of the series and need no policy here
+func (e *Engine) SetRepairPolicy(policy string) {
+	e.repairPolicy = policy
+}
+
+// Run executes backtesting for the specified instrument, candle interval and time range
+// A run that fails after the strategy is loaded is still saved, with status FAILED and the error
+func (e *Engine) Run(instrumentID int64, interval string, startTime, endTime time.Time) (*models.BacktestResult, error) {
 	// Load strategy configuration
 	strat, err := e.db.GetStrategyByName(e.strategyName)
 	if err != nil {
 		return nil, err
 	}
+	if e.strategyVersion > 0 && e.strategyVersion != strat.Version {
+		version, err := e.db.GetStrategyVersion(int64(strat.ID), e.strategyVersion)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s version %d: %w", strat.Name, e.strategyVersion, err)
+		}
+		strat.Config = version.Config
+		strat.Version = version.Version
+	}
+
+	// Run settings, saved with the result
+	run := &models.BacktestResult{
+		InstrumentID:    instrumentID,
+		StrategyID:      int64(strat.ID),
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Interval:        interval,
+		StrategyVersion: strat.Version,
+		Config:          &strat.Config,
+		QualityPolicy:   e.qualityPolicy,
+		RepairPolicy:    e.repairPolicy,
+		Metrics:         make(map[string]float64),
+	}
+
+	// Load candles from database, resampled from a finer interval if this one is not stored
+	candles, _, err := resample.Load(e.db, instrumentID, interval, startTime, endTime)
+	if err != nil {
+		return nil, e.saveFailed(run, err)
+	}
 
-	// Initialize calculators
-	e.cfCalculator = strategy.NewCFCalculator(&strat.Config)
-	e.atrCalc = strategy.NewATRCalculator(14)  // Standard ATR period
-	e.adxCalc = strategy.NewADXCalculator(14)  // Standard ADX period
+	// Check data quality; the report is stored even when the run is refused
+	var qualityReport *models.QualityReport
+	if e.qualityPolicy != quality.PolicyIgnore {
+		qualityReport = quality.NewChecker(quality.DefaultOptions()).Check(candles, interval)
+		qualityReport.InstrumentID = instrumentID
+		if err := e.db.SaveQualityReport(qualityReport); err != nil {
+			return nil, e.saveFailed(run, err)
+		}
+		if e.qualityPolicy == quality.PolicyRefuse && !quality.CleanAfterRepair(qualityReport, e.repairPolicy) {
+			return nil, e.saveFailed(run, fmt.Errorf("%w: %s", quality.ErrDirtyData, quality.Summary(qualityReport)))
+		}
+	}
 
-	// Load candles from database
-	candles, err := e.db.GetCandlesByTimeRange(instrumentID, startTime, endTime)
+	// Load funding events (empty for spot instruments)
+	funding, err := e.db.GetFundingRates(instrumentID, startTime, endTime)
 	if err != nil {
-		return nil, err
+		return nil, e.saveFailed(run, err)
 	}
 
-	// Calculate indicators
-	mainCF, secondCF := e.calculateCFIndicators(candles, &strat.Config)
-	atr := e.atrCalc.Calculate(candles)
-	atrPercent := strategy.CalculateATRPercent(candles, atr)
-	adx, plusDI, minusDI := e.adxCalc.Calculate(candles)
+	// Repair gaps: fill them, or cut the series into sessions
+	sessions := [][]*models.Candle{candles}
+	switch e.repairPolicy {
+	case quality.RepairForwardFill, quality.RepairInterpolate:
+		if candles, _, err = quality.Repair(candles, interval, e.repairPolicy); err != nil {
+			return nil, e.saveFailed(run, err)
+		}
+		sessions = [][]*models.Candle{candles}
+	case quality.RepairSplit:
+		sessions = quality.Split(candles, interval)
+	}
 
 	// Execute trading logic
-	result := e.executeBacktest(candles, mainCF, secondCF, atr, atrPercent, adx, plusDI, minusDI, &strat.Config)
+	result := e.runSessions(sessions, funding, &strat.Config)
 
 	// Save result to database
 	result.InstrumentID = instrumentID
-	result.StrategyID = strat.ID
+	result.StrategyID = run.StrategyID
 	result.StartTime = startTime
 	result.EndTime = endTime
+	result.Interval = interval
+	result.StrategyVersion = run.StrategyVersion
+	result.Config = run.Config
+	result.QualityPolicy = run.QualityPolicy
+	result.RepairPolicy = run.RepairPolicy
+	result.Status = models.BacktestDone
 	result.CreatedAt = time.Now()
+	result.Quality = qualityReport
 
-	if err := e.db.SaveBacktestResult(result); err != nil {
-		return nil, err
+	// The run is listed only once its trades and equity are saved with it
+	if err := e.db.SaveBacktestRun(result); err != nil {
+		return nil, e.saveFailed(run, err)
 	}
 
 	return result, nil
 }
 
-// calculateCFIndicators computes Capital Flow indicators
-func (e *Engine) calculateCFIndicators(candles []*models.Candle, config *models.StrategyConfig) (mainCF, secondCF []float64) {
-	mainCF = make([]float64, len(candles))
-	secondCF = make([]float64, len(candles))
+// saveFailed records run as FAILED with err and returns err
+// Saving is best effort: the run error is what the caller needs to see
+func (e *Engine) saveFailed(run *models.BacktestResult, err error) error {
+	run.Status = models.BacktestFailed
+	run.Error = err.Error()
+	run.CreatedAt = time.Now()
+	e.db.SaveBacktestResult(run)
+	return err
+}
 
-	prevTotalKlines := config.TotalKlines
-	prevSecondTotalKlines := config.SecondTotalKlinesMin
-	prevMainCF := 0.0
+// runSessions backtests each candle segment as a separate session: indicators start over
+// and open positions are closed at the end of every segment, while the balance carries over
+func (e *Engine) runSessions(sessions [][]*models.Candle, funding []*models.FundingRate, config *models.StrategyConfig) *models.BacktestResult {
+	if len(sessions) == 1 {
+		result, _ := e.runSession(sessions[0], funding, config, initialBalance, ExitEndOfData)
+		return result
+	}
 
-	for i := range candles {
-		if i < config.TotalKlines {
-			continue
+	result := &models.BacktestResult{
+		Metrics: make(map[string]float64),
+	}
+	balance := initialBalance
+	for i, candles := range sessions {
+		endReason := ExitSessionEnd
+		if i == len(sessions)-1 {
+			endReason = ExitEndOfData
 		}
 
-		// Calculate MainCF
-		mainCF[i], prevTotalKlines = e.cfCalculator.CalculateMainCF(candles, i, prevMainCF)
-		prevMainCF = mainCF[i]
-
-		// Calculate SecondCF (for visualization)
-		if i > 0 && mainCF[i-1] != 0 {
-			secondCF[i], prevSecondTotalKlines = e.cfCalculator.CalculateSecondCF(candles, i, mainCF[i-1], prevSecondTotalKlines)
-		}
+		var session *models.BacktestResult
+		session, balance = e.runSession(candles, funding, config, balance, endReason)
+		result.Trades = append(result.Trades, session.Trades...)
+		result.Equity = append(result.Equity, session.Equity...)
+		result.Debug = append(result.Debug, session.Debug...)
 	}
+	if e.debugMaxCandles > 0 && len(result.Debug) > e.debugMaxCandles {
+		result.Debug = result.Debug[:e.debugMaxCandles]
+	}
+
+	e.calculateMetrics(result, result.Trades, initialBalance, balance)
+	result.Metrics["sessions"] = float64(len(sessions))
+	return result
+}
+
+// runSession calculates indicators on candles and runs the trading simulation
+// starting from balance; it returns the result and the final balance
+func (e *Engine) runSession(
+	candles []*models.Candle,
+	funding []*models.FundingRate,
+	config *models.StrategyConfig,
+	balance float64,
+	endReason string,
+) (*models.BacktestResult, float64) {
+	ind := strategy.CalculateSeries(candles, config)
 
-	return mainCF, secondCF
+	return e.executeBacktest(candles, funding, ind.MainCF, ind.SecondCF, ind.TrailingSecondCF, ind.ATR, ind.ATRPercent, ind.ADX, ind.PlusDI, ind.MinusDI, config, balance, endReason)
 }
 
-// executeBacktest runs trading simulation
+// executeBacktest runs trading simulation starting from startBalance
+// A position still open on the last candle is closed there with endReason
 func (e *Engine) executeBacktest(
 	candles []*models.Candle,
-	mainCF, secondCF, atr, atrPercent, adx, plusDI, minusDI []float64,
+	funding []*models.FundingRate,
+	mainCF, secondCF, trailingSecondCF, atr, atrPercent, adx, plusDI, minusDI []float64,
 	config *models.StrategyConfig,
-) *models.BacktestResult {
+	startBalance float64,
+	endReason string,
+) (*models.BacktestResult, float64) {
 	result := &models.BacktestResult{
 		Metrics: make(map[string]float64),
 	}
 
 	var trades []*models.Trade
 	var currentPosition *Position
-	initialBalance := 10000.0
-	currentBalance := initialBalance
+	var pending *pendingSignal
+	currentBalance := startBalance
+	exec := newExecutionModel(config)
+	orders := NewOrderBook(config.OrderMaxVolumePct)
+	if e.debug {
+		orders.RecordEvents()
+	}
+	useOrders := config.EntryOrderType != "" && config.EntryOrderType != OrderMarket
+	fundingEvents := newFundingSchedule(funding)
+	reason := ""
+
+	// fill executes the pending signal if it is due on bar i
+	fill := func(i int) {
+		if pending == nil || pending.fillIdx != i {
+			return
+		}
+		candle := candles[i]
+		price := exec.fillPrice(candle)
+
+		switch pending.action {
+		case actionEnter:
+			if currentPosition == nil {
+				size := e.positionSize(currentBalance, price)
+				currentPosition = e.openPosition(pending.side, candle, price, size, atr[pending.signalIdx], config)
+				currentPosition.EntryIdx = i
+				currentPosition.StopsFromIdx = i + 1
+				if exec.fillsAtOpen() {
+					currentPosition.StopsFromIdx = i
+				}
+				currentBalance -= currentPosition.Size*price + currentPosition.EntryFee
+				reason = "ENTER"
+			}
+		case actionExit:
+			if currentPosition != nil {
+				trades = append(trades, e.settle(currentPosition, candle, price, pending.reason, &currentBalance))
+				currentPosition = nil
+				reason = "EXIT"
+			}
+		}
+		pending = nil
+	}
 
 	// Trading loop
 	for i := 1; i < len(candles); i++ {
 		candle := candles[i]
+		reason = ""
 
-		// Check for entry signal
-		if currentPosition == nil {
-			signal := e.checkEntrySignal(i, mainCF, secondCF, adx, plusDI, minusDI, atrPercent, config)
-			if signal != "" {
-				currentPosition = e.openPosition(signal, candle, atr[i], currentBalance)
-				if currentPosition != nil {
-					currentBalance -= currentPosition.Size * candle.Close
-				}
+		// Settle funding for events up to this bar's open
+		for _, rate := range fundingEvents.due(candle.Timestamp) {
+			if currentPosition != nil {
+				currentBalance += e.applyFunding(currentPosition, rate, candle.Open)
 			}
-		} else {
-			// Check for exit signal
-			if e.checkExitSignal(i, currentPosition, candle, atr[i]) {
-				trade := e.closePosition(currentPosition, candle)
-				currentBalance += trade.ExitPrice * currentPosition.Size
-
-				trade.PnL = (trade.ExitPrice - trade.EntryPrice) * currentPosition.Size
-				if currentPosition.Side == "SHORT" {
-					trade.PnL = -trade.PnL
+		}
+
+		if exec.fillsAtOpen() {
+			fill(i)
+		}
+
+		// Match working entry orders against the bar's range
+		for _, f := range orders.Process(i, candle) {
+			if currentPosition == nil {
+				currentPosition = e.openPosition(f.Order.Side, candle, f.Price, f.Quantity, f.Order.ATR, config)
+				currentPosition.EntryIdx = i
+				currentPosition.StopsFromIdx = i + 1
+				currentBalance -= f.Quantity*f.Price + currentPosition.EntryFee
+				reason = "ENTER"
+			} else {
+				currentBalance -= f.Quantity*f.Price + currentPosition.addFill(f.Price, f.Quantity)
+			}
+		}
+
+		// Check stop loss / take profit on bars the position was held through
+		// Several scale-out targets can be taken on the same bar
+		for currentPosition != nil && i >= currentPosition.StopsFromIdx && e.checkExitSignal(i, currentPosition, candle, atr[i]) {
+			exitPrice, exitReason := e.stopExit(currentPosition, candle)
+			if exitReason == ExitTakeProfit {
+				if size := currentPosition.targetSize(); size < currentPosition.Size-1e-12 {
+					e.reduce(currentPosition, candle, exitPrice, size, ExitPartialTakeProfit, &currentBalance)
+					currentPosition.nextTarget()
+					reason = "PARTIAL_EXIT"
+					continue
 				}
+			}
+			trades = append(trades, e.settle(currentPosition, candle, exitPrice, exitReason, &currentBalance))
+			currentPosition = nil
+			reason = "EXIT"
+			orders.CancelAll(candle.Timestamp, "position closed")
+			if pending != nil && pending.action == actionExit {
+				pending = nil
+			}
+		}
 
-				trades = append(trades, trade)
-				currentPosition = nil
+		// Ratchet trailing and break-even stops with the closed bar
+		if currentPosition != nil && i >= currentPosition.StopsFromIdx {
+			e.updateStops(currentPosition, candle, atr[i], trailingSecondCF[i], config)
+		}
+
+		// Evaluate signals on the bar close
+		if pending == nil {
+			if currentPosition == nil {
+				signal := e.checkEntrySignal(i, mainCF, secondCF, adx, plusDI, minusDI, atrPercent, config)
+				if signal != "" && useOrders {
+					if orders.Working() {
+						if orders.WorkingSide() == signal {
+							signal = ""
+						} else {
+							orders.CancelAll(candle.Timestamp, "opposite signal")
+						}
+					}
+					if signal != "" {
+						order := newEntryOrder(config, signal, candle.Close, atr[i], e.positionSize(currentBalance, candle.Close), i+1+exec.latency)
+						orders.Submit(order, candle.Timestamp)
+					}
+				} else if signal != "" {
+					pending = exec.schedule(actionEnter, signal, i)
+				}
+			} else if e.timeExitDue(i, currentPosition, config) {
+				orders.CancelAll(candle.Timestamp, "time exit")
+				pending = exec.schedule(actionExit, "", i)
+				pending.reason = ExitTimeLimit
+			} else if config.ExitOnOppositeSignal {
+				signal := e.checkEntrySignal(i, mainCF, secondCF, adx, plusDI, minusDI, atrPercent, config)
+				if signal != "" && signal != currentPosition.Side {
+					orders.CancelAll(candle.Timestamp, "opposite signal")
+					pending = exec.schedule(actionExit, "", i)
+					pending.reason = ExitSignal
+				}
 			}
 		}
+
+		if !exec.fillsAtOpen() {
+			fill(i)
+		}
+
+		// Mark open positions to the bar close
+		equity := currentBalance
+		if currentPosition != nil {
+			equity += currentPosition.markValue(candle.Close)
+		}
+		result.Equity = append(result.Equity, &models.Equity{TS: candle.Timestamp, Equity: equity})
+
+		// Events are drained every bar, so none are kept past the end of the trace
+		events := orders.DrainEvents()
+		if e.debug && (e.debugMaxCandles == 0 || len(result.Debug) < e.debugMaxCandles) {
+			result.Debug = append(result.Debug, &models.DebugCandle{
+				TS:               candle.Timestamp,
+				Open:             candle.Open,
+				High:             candle.High,
+				Low:              candle.Low,
+				Close:            candle.Close,
+				Volume:           candle.Volume,
+				Repaired:         candle.Repaired,
+				MainCF:           mainCF[i],
+				SecondCF:         secondCF[i],
+				TrailingSecondCF: trailingSecondCF[i],
+				ATRShort:         atr[i],
+				ADX:              adx[i],
+				InPosition:       currentPosition != nil,
+				Reason:           reason,
+				OrderEvents:      events,
+			})
+		}
 	}
 
 	// Close any open position at the end
 	if currentPosition != nil {
 		lastCandle := candles[len(candles)-1]
-		trade := e.closePosition(currentPosition, lastCandle)
-		currentBalance += trade.ExitPrice * currentPosition.Size
-
-		trade.PnL = (trade.ExitPrice - trade.EntryPrice) * currentPosition.Size
-		if currentPosition.Side == "SHORT" {
-			trade.PnL = -trade.PnL
-		}
-
-		trades = append(trades, trade)
+		trades = append(trades, e.settle(currentPosition, lastCandle, lastCandle.Close, endReason, &currentBalance))
+		result.Equity[len(result.Equity)-1].Equity = currentBalance
 	}
 
 	// Calculate metrics
-	e.calculateMetrics(result, trades, initialBalance, currentBalance)
+	result.Trades = trades
+	e.calculateMetrics(result, trades, startBalance, currentBalance)
 
-	return result
+	return result, currentBalance
 }
 
 // Position represents an open trading position
 type Position struct {
-	Side        string
-	EntryPrice  float64
-	EntryTime   time.Time
-	Size        float64
-	StopLoss    float64
-	TakeProfit  float64
+	Side         string
+	EntryPrice   float64
+	EntryTime    time.Time
+	EntryIdx     int
+	Size         float64
+	StopLoss     float64
+	TakeProfit   float64
+	ATR          float64 // ATR at the signal bar
+	StopsFromIdx int     // First bar stop loss / take profit are checked on
+	StopReason   string  // Exit reason reported if StopLoss is hit
+	HighWater    float64 // Highest high since entry
+	LowWater     float64 // Lowest low since entry
+
+	// Scale-out state
+	InitialSize float64                   // Total quantity entered
+	Targets     []models.TakeProfitTarget // Partial take-profit targets
+	TargetIdx   int                       // Next target to be hit
+	FeeRate     float64
+	EntryFee    float64
+	Fills       []*models.TradeFill // Exit fills booked so far
+
+	Funding float64 // Cumulative funding received (negative = paid)
+}
+
+// markValue returns the margin held by the position plus its unrealized PnL at price
+func (p *Position) markValue(price float64) float64 {
+	unrealized := (price - p.EntryPrice) * p.Size
+	if p.Side == "SHORT" {
+		unrealized = -unrealized
+	}
+	return p.EntryPrice*p.Size + unrealized
 }
 
 // checkEntrySignal determines if entry conditions are met
@@ -195,25 +470,101 @@ func (e *Engine) checkEntrySignal(
 	return ""
 }
 
-// openPosition creates a new trading position
-func (e *Engine) openPosition(signal string, candle *models.Candle, atr, balance float64) *Position {
+// positionSize returns the quantity for a new position at price
+func (e *Engine) positionSize(balance, price float64) float64 {
+	return balance * 0.1 / price // Risk 10% of balance
+}
+
+// openPosition creates a new trading position filled at price
+func (e *Engine) openPosition(signal string, candle *models.Candle, price, size, atr float64, config *models.StrategyConfig) *Position {
 	position := &Position{
-		Side:       signal,
-		EntryPrice: candle.Close,
-		EntryTime:  candle.Timestamp,
-		Size:       balance * 0.1 / candle.Close, // Risk 10% of balance
+		Side:        signal,
+		EntryPrice:  price,
+		EntryTime:   time.UnixMilli(candle.Timestamp),
+		Size:        size,
+		ATR:         atr,
+		InitialSize: size,
+		Targets:     config.TakeProfitTargets,
+		FeeRate:     config.FeeRate,
+		EntryFee:    price * size * config.FeeRate,
 	}
+	position.setStops()
+
+	return position
+}
+
+// setStops sets stop loss and take profit based on ATR
+func (p *Position) setStops() {
+	p.StopLoss = p.initialStop()
+	p.StopReason = ExitStopLoss
+	p.HighWater = p.EntryPrice
+	p.LowWater = p.EntryPrice
+	p.setTarget()
+}
 
-	// Set stop loss and take profit based on ATR
-	if signal == "LONG" {
-		position.StopLoss = candle.Close - 2*atr
-		position.TakeProfit = candle.Close + 3*atr
+// initialStop returns the stop loss 2 ATR from the entry price
+func (p *Position) initialStop() float64 {
+	if p.Side == "LONG" {
+		return p.EntryPrice - 2*p.ATR
+	}
+	return p.EntryPrice + 2*p.ATR
+}
+
+// setTarget sets take profit to the next scale-out target
+// Without configured targets the whole position is taken at 3 ATR;
+// once all targets are hit the remainder is left to the stops
+func (p *Position) setTarget() {
+	multiple := 3.0
+	if len(p.Targets) > 0 {
+		if p.TargetIdx >= len(p.Targets) {
+			p.TakeProfit = math.Inf(1)
+			if p.Side != "LONG" {
+				p.TakeProfit = math.Inf(-1)
+			}
+			return
+		}
+		multiple = p.Targets[p.TargetIdx].ATRMultiple
+	}
+
+	if p.Side == "LONG" {
+		p.TakeProfit = p.EntryPrice + multiple*p.ATR
 	} else {
-		position.StopLoss = candle.Close + 2*atr
-		position.TakeProfit = candle.Close - 3*atr
+		p.TakeProfit = p.EntryPrice - multiple*p.ATR
 	}
+}
 
-	return position
+// targetSize returns the quantity to close at the current target
+func (p *Position) targetSize() float64 {
+	if p.TargetIdx >= len(p.Targets) {
+		return p.Size
+	}
+	return math.Min(p.Size, p.Targets[p.TargetIdx].Fraction*p.InitialSize)
+}
+
+// nextTarget advances to the following scale-out target
+func (p *Position) nextTarget() {
+	p.TargetIdx++
+	p.setTarget()
+}
+
+// addFill adds a further entry fill, averaging the entry price
+// The initial stop and the target move with the average price, but a stop that trailing or
+// break-even has already tightened beyond the new initial stop is kept, as are the water marks
+// Returns the entry fee charged for the fill
+func (p *Position) addFill(price, size float64) float64 {
+	total := p.Size + size
+	p.EntryPrice = (p.EntryPrice*p.Size + price*size) / total
+	p.Size = total
+	p.InitialSize += size
+
+	stop, reason := p.StopLoss, p.StopReason
+	p.StopLoss, p.StopReason = p.initialStop(), ExitStopLoss
+	p.tightenStop(stop, reason)
+	p.setTarget()
+
+	fee := price * size * p.FeeRate
+	p.EntryFee += fee
+	return fee
 }
 
 // checkExitSignal determines if exit conditions are met
@@ -233,33 +584,82 @@ func (e *Engine) checkExitSignal(idx int, position *Position, candle *models.Can
 	return false
 }
 
-// closePosition closes an open position
-func (e *Engine) closePosition(position *Position, candle *models.Candle) *models.Trade {
-	exitPrice := candle.Close
-
-	// Determine actual exit price based on SL/TP
+// stopExit returns the stop or take profit level hit by the candle and the exit reason
+// The stop is assumed to be hit first when both are inside the bar's range
+func (e *Engine) stopExit(position *Position, candle *models.Candle) (float64, string) {
 	if position.Side == "LONG" {
 		if candle.Low <= position.StopLoss {
-			exitPrice = position.StopLoss
+			return position.StopLoss, position.StopReason
 		} else if candle.High >= position.TakeProfit {
-			exitPrice = position.TakeProfit
+			return position.TakeProfit, ExitTakeProfit
 		}
 	} else {
 		if candle.High >= position.StopLoss {
-			exitPrice = position.StopLoss
+			return position.StopLoss, position.StopReason
 		} else if candle.Low <= position.TakeProfit {
-			exitPrice = position.TakeProfit
+			return position.TakeProfit, ExitTakeProfit
 		}
 	}
 
-	return &models.Trade{
+	return candle.Close, ExitSignal
+}
+
+// reduce closes size of the position at price and books the exit fill
+// The margin for the closed quantity is released together with its net PnL
+func (e *Engine) reduce(position *Position, candle *models.Candle, price, size float64, reason string, balance *float64) {
+	gross := (price - position.EntryPrice) * size
+	if position.Side == "SHORT" {
+		gross = -gross
+	}
+	fee := price * size * position.FeeRate
+
+	position.Fills = append(position.Fills, &models.TradeFill{
+		Time:   time.UnixMilli(candle.Timestamp),
+		Price:  price,
+		Size:   size,
+		Fee:    fee,
+		PnL:    gross - fee,
+		Reason: reason,
+	})
+	position.Size -= size
+
+	*balance += position.EntryPrice*size + gross - fee
+}
+
+// closePosition builds the trade from the position's exit fills
+// ExitPrice is the size-weighted average of all fills and PnL is net of fees and funding
+func (e *Engine) closePosition(position *Position, candle *models.Candle, exitReason string) *models.Trade {
+	trade := &models.Trade{
 		Side:       position.Side,
 		EntryPrice: position.EntryPrice,
 		EntryTime:  position.EntryTime,
-		ExitPrice:  exitPrice,
-		ExitTime:   candle.Timestamp,
-		Size:       position.Size,
+		ExitTime:   time.UnixMilli(candle.Timestamp),
+		ExitReason: exitReason,
+		Size:       position.InitialSize,
+		Fees:       position.EntryFee,
+		Funding:    position.Funding,
+		PnL:        position.Funding - position.EntryFee,
+		Fills:      position.Fills,
 	}
+
+	var exitNotional, exitSize float64
+	for _, fill := range position.Fills {
+		exitNotional += fill.Price * fill.Size
+		exitSize += fill.Size
+		trade.Fees += fill.Fee
+		trade.PnL += fill.PnL
+	}
+	if exitSize > 0 {
+		trade.ExitPrice = exitNotional / exitSize
+	}
+
+	return trade
+}
+
+// settle closes the remaining position at exitPrice and returns the trade
+func (e *Engine) settle(position *Position, candle *models.Candle, exitPrice float64, exitReason string, balance *float64) *models.Trade {
+	e.reduce(position, candle, exitPrice, position.Size, exitReason, balance)
+	return e.closePosition(position, candle, exitReason)
 }
 
 // calculateMetrics computes performance metrics
@@ -274,6 +674,11 @@ func (e *Engine) calculateMetrics(result *models.BacktestResult, trades []*model
 	var totalProfit, totalLoss float64
 
 	for _, trade := range trades {
+		result.Metrics["exits_"+strings.ToLower(trade.ExitReason)]++
+		result.Metrics["total_fees"] += trade.Fees
+		result.Metrics["total_funding"] += trade.Funding
+		result.Metrics["partial_fills"] += float64(len(trade.Fills) - 1)
+
 		if trade.PnL > 0 {
 			winningTrades++
 			totalProfit += trade.PnL
@@ -0,0 +1,157 @@
+package backtester
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
+	"github.com/langley-creator/cf-backtester/internal/strategy"
+)
+
+// runStored saves candles and config in a MemoryStore and runs the engine over all candles
+// configure sets the engine's policies before the run
+func runStored(t *testing.T, candles []*models.Candle, funding []*models.FundingRate, config *models.StrategyConfig, configure func(*Engine)) *models.BacktestResult {
+	t.Helper()
+	store := database.NewMemoryStore()
+	instrument := &models.Instrument{Symbol: "TEST", Exchange: "TEST"}
+	if err := store.SaveInstrument(instrument); err != nil {
+		t.Fatal(err)
+	}
+	for _, candle := range candles {
+		candle.InstrumentID = int64(instrument.ID)
+	}
+	if _, err := store.SaveCandles(candles); err != nil {
+		t.Fatal(err)
+	}
+	for _, rate := range funding {
+		rate.InstrumentID = int64(instrument.ID)
+		if err := store.SaveFundingRate(rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveStrategy(&models.Strategy{Name: "test", Config: *config}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(store, "test")
+	engine.SetQualityPolicy(quality.PolicyIgnore)
+	if configure != nil {
+		configure(engine)
+	}
+	result, err := engine.Run(int64(instrument.ID), candles[0].Interval,
+		time.UnixMilli(candles[0].Timestamp), time.UnixMilli(candles[len(candles)-1].Timestamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// signalIdx is the bar of the entry signal in a signalSeries
+const signalIdx = 80
+
+// bar is the open, high, low and close of a candle
+type bar struct{ open, high, low, close float64 }
+
+// signalSeries returns flat candles at 100 with a breakout on signalIdx that signals side with
+// cfConfig, closing at 101 for LONG and 99 for SHORT, followed by path; every candle trades 1000
+func signalSeries(side string, path ...bar) []*models.Candle {
+	bars := make([]bar, signalIdx, signalIdx+1+len(path))
+	for i := range bars {
+		bars[i] = bar{100, 100.5, 99.5, 100}
+	}
+	if side == "LONG" {
+		bars = append(bars, bar{100, 101.5, 99.8, 101})
+	} else {
+		bars = append(bars, bar{100, 100.2, 98.5, 99})
+	}
+	bars = append(bars, path...)
+
+	candles := make([]*models.Candle, len(bars))
+	for i, b := range bars {
+		candles[i] = &models.Candle{
+			Interval:  "1h",
+			Timestamp: int64(i) * 3600_000,
+			Open:      b.open,
+			High:      b.high,
+			Low:       b.low,
+			Close:     b.close,
+			Volume:    1000,
+		}
+	}
+	return candles
+}
+
+// flat repeats a quiet bar around price n times
+func flat(price float64, n int) []bar {
+	bars := make([]bar, n)
+	for i := range bars {
+		bars[i] = bar{price, price + 0.3, price - 0.3, price}
+	}
+	return bars
+}
+
+// atrOf returns the ATR series the engine computes for candles
+func atrOf(candles []*models.Candle) []float64 {
+	return strategy.NewATRCalculator(strategy.IndicatorPeriod).Calculate(candles)
+}
+
+// near reports whether a and b are equal up to rounding
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestDebugTraceFlagsRepairedCandles checks that candles filled at import or on load carry
+// their repair method in the debug trace, and market candles none
+func TestDebugTraceFlagsRepairedCandles(t *testing.T) {
+	const gapFrom, gapTo = 100, 105 // Bars missing from the market data
+
+	tests := []struct {
+		name   string
+		policy string // Repair on load
+		stored string // Repair method of the gap candles stored at import, "" if missing
+		want   string // Repair method of the gap candles in the trace, "" if absent
+	}{
+		{"forward fill on load", quality.RepairForwardFill, "", models.RepairForwardFill},
+		{"interpolate on load", quality.RepairInterpolate, "", models.RepairInterpolate},
+		{"filled at import", quality.RepairNone, models.RepairForwardFill, models.RepairForwardFill},
+		{"split", quality.RepairSplit, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var candles []*models.Candle
+			for i, candle := range randomWalk(200, 1) {
+				if i >= gapFrom && i < gapTo {
+					if tt.stored == "" {
+						continue
+					}
+					candle.Repaired = tt.stored
+				}
+				candles = append(candles, candle)
+			}
+
+			result := runStored(t, candles, nil, cfConfig(), func(e *Engine) {
+				e.SetRepairPolicy(tt.policy)
+				e.EnableDebug(0)
+			})
+
+			var repaired int
+			for _, candle := range result.Debug {
+				inGap := candle.TS >= int64(gapFrom)*3600_000 && candle.TS < int64(gapTo)*3600_000
+				switch {
+				case inGap && candle.Repaired != tt.want:
+					t.Errorf("candle %d: repaired %q, want %q", candle.TS, candle.Repaired, tt.want)
+				case !inGap && candle.Repaired != "":
+					t.Errorf("market candle %d flagged as %q", candle.TS, candle.Repaired)
+				case inGap:
+					repaired++
+				}
+			}
+			if want := gapTo - gapFrom; tt.want != "" && repaired != want {
+				t.Errorf("%d repaired candles in the trace, want %d", repaired, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package backtester
+
+import (
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Execution timing modes
+const (
+	ExecSignalClose = "SIGNAL_CLOSE" // Fill at the close of the signal bar
+	ExecNextOpen    = "NEXT_OPEN"    // Fill at the open of the following bar
+	ExecNextVWAP    = "NEXT_VWAP"    // Fill at the typical price of the following bar
+)
+
+// Signal actions scheduled for execution
+const (
+	actionEnter = "ENTER"
+	actionExit  = "EXIT"
+)
+
+// pendingSignal is a signal waiting for its execution bar
+type pendingSignal struct {
+	action    string // ENTER or EXIT
+	side      string // LONG or SHORT (entries only)
+	reason    string // Exit reason (exits only)
+	signalIdx int
+	fillIdx   int
+}
+
+// executionModel decides on which bar and at what price a signal is filled
+type executionModel struct {
+	mode    string
+	latency int
+}
+
+// newExecutionModel creates an execution model from strategy config
+// Unknown or empty modes fall back to SIGNAL_CLOSE
+func newExecutionModel(config *models.StrategyConfig) *executionModel {
+	mode := config.ExecutionMode
+	if mode != ExecNextOpen && mode != ExecNextVWAP {
+		mode = ExecSignalClose
+	}
+
+	latency := config.ExecutionLatency
+	if latency < 0 {
+		latency = 0
+	}
+
+	return &executionModel{mode: mode, latency: latency}
+}
+
+// schedule creates a pending signal for the bar it should be filled on
+func (m *executionModel) schedule(action, side string, signalIdx int) *pendingSignal {
+	fillIdx := signalIdx + m.latency
+	if m.mode != ExecSignalClose {
+		fillIdx++
+	}
+
+	return &pendingSignal{
+		action:    action,
+		side:      side,
+		signalIdx: signalIdx,
+		fillIdx:   fillIdx,
+	}
+}
+
+// fillsAtOpen reports whether fills happen before the bar's range is traded,
+// in which case stops can already trigger on the fill bar
+func (m *executionModel) fillsAtOpen() bool {
+	return m.mode == ExecNextOpen
+}
+
+// fillPrice returns the execution price on the fill bar
+func (m *executionModel) fillPrice(candle *models.Candle) float64 {
+	switch m.mode {
+	case ExecNextOpen:
+		return candle.Open
+	case ExecNextVWAP:
+		// Typical price approximates VWAP when only OHLCV is available
+		return (candle.High + candle.Low + candle.Close) / 3
+	default:
+		return candle.Close
+	}
+}
@@ -0,0 +1,112 @@
+package backtester
+
+import (
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/strategy"
+)
+
+// Fill prices of the execution modes
+var (
+	atClose = func(c *models.Candle) float64 { return c.Close }
+	atOpen  = func(c *models.Candle) float64 { return c.Open }
+	atVWAP  = func(c *models.Candle) float64 { return (c.High + c.Low + c.Close) / 3 }
+)
+
+// TestExecutionTiming checks the bar and price entries and opposite-signal exits fill at for
+// each execution mode and latency
+func TestExecutionTiming(t *testing.T) {
+	rise := []bar{{101.1, 101.6, 100.9, 101.3}, {101.3, 101.9, 101.1, 101.7}, {101.7, 102.2, 101.5, 102}, {102, 102.4, 101.8, 102.1}}
+	rise = append(rise, flat(102.1, 3)...)
+
+	// Trades through the initial stop on the bar after the signal
+	dip := append([]bar{{101, 101.2, 98.7, 100.8}}, flat(100.8, 3)...)
+
+	// Slides under the entry without reaching the stop until MainCF turns negative
+	var decline []bar
+	for p := 101.0; p > 99.3; p -= 0.05 {
+		decline = append(decline, bar{p + 0.05, p + 0.2, p - 0.2, p})
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		latency  int
+		opposite bool  // Exit on the opposite signal
+		path     []bar // rise, dip or decline
+		entry    int   // Bars from the signal to the entry fill
+		price    func(*models.Candle) float64
+		reason   string // Exit reason
+		exit     int    // Bars from the opposite signal to the exit fill, or from the entry to the stop
+	}{
+		{name: "signal close", mode: ExecSignalClose, path: rise, entry: 0, price: atClose, reason: ExitEndOfData},
+		{name: "signal close with latency", mode: ExecSignalClose, latency: 2, path: rise, entry: 2, price: atClose, reason: ExitEndOfData},
+		{name: "default mode", path: rise, entry: 0, price: atClose, reason: ExitEndOfData},
+		{name: "negative latency", mode: ExecSignalClose, latency: -1, path: rise, entry: 0, price: atClose, reason: ExitEndOfData},
+		{name: "next open", mode: ExecNextOpen, path: rise, entry: 1, price: atOpen, reason: ExitEndOfData},
+		{name: "next open with latency", mode: ExecNextOpen, latency: 2, path: rise, entry: 3, price: atOpen, reason: ExitEndOfData},
+		{name: "next VWAP", mode: ExecNextVWAP, path: rise, entry: 1, price: atVWAP, reason: ExitEndOfData},
+		{name: "next VWAP with latency", mode: ExecNextVWAP, latency: 2, path: rise, entry: 3, price: atVWAP, reason: ExitEndOfData},
+
+		// Only fills at the open happen before the bar's range can trigger the stops
+		{name: "stop on the next open fill bar", mode: ExecNextOpen, path: dip, entry: 1, price: atOpen, reason: ExitStopLoss, exit: 0},
+		{name: "stop after the next VWAP fill bar", mode: ExecNextVWAP, path: dip, entry: 1, price: atVWAP, reason: ExitEndOfData},
+		{name: "stop after the signal close fill bar", mode: ExecSignalClose, path: dip, entry: 0, price: atClose, reason: ExitStopLoss, exit: 1},
+
+		{name: "opposite signal ignored", mode: ExecSignalClose, path: decline, entry: 0, price: atClose, reason: ExitEndOfData},
+		{name: "opposite signal at close", mode: ExecSignalClose, opposite: true, path: decline, entry: 0, price: atClose, reason: ExitSignal, exit: 0},
+		{name: "opposite signal with latency", mode: ExecSignalClose, latency: 1, opposite: true, path: decline, entry: 1, price: atClose, reason: ExitSignal, exit: 1},
+		{name: "opposite signal at next open", mode: ExecNextOpen, opposite: true, path: decline, entry: 1, price: atOpen, reason: ExitSignal, exit: 1},
+		{name: "opposite signal at next open with latency", mode: ExecNextOpen, latency: 2, opposite: true, path: decline, entry: 3, price: atOpen, reason: ExitSignal, exit: 3},
+		{name: "opposite signal at next VWAP", mode: ExecNextVWAP, opposite: true, path: decline, entry: 1, price: atVWAP, reason: ExitSignal, exit: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := cfConfig()
+			config.ExecutionMode = tt.mode
+			config.ExecutionLatency = tt.latency
+			config.ExitOnOppositeSignal = tt.opposite
+			candles := signalSeries("LONG", tt.path...)
+			result := runStored(t, candles, nil, config, nil)
+
+			if len(result.Trades) != 1 {
+				t.Fatalf("%d trades, want 1", len(result.Trades))
+			}
+			trade := result.Trades[0]
+			fill := candles[signalIdx+tt.entry]
+			if trade.EntryTime.UnixMilli() != fill.Timestamp || !near(trade.EntryPrice, tt.price(fill)) {
+				t.Errorf("entry at %v for %v, want bar %d for %v", trade.EntryTime, trade.EntryPrice, signalIdx+tt.entry, tt.price(fill))
+			}
+
+			exit := candles[len(candles)-1]
+			price := exit.Close
+			switch tt.reason {
+			case ExitSignal:
+				exit = candles[oppositeSignal(t, candles, config)+tt.exit]
+				price = tt.price(exit)
+			case ExitStopLoss:
+				exit = candles[signalIdx+tt.entry+tt.exit]
+				price = tt.price(fill) - 2*atrOf(candles)[signalIdx]
+			}
+			if trade.ExitReason != tt.reason || trade.ExitTime.UnixMilli() != exit.Timestamp || !near(trade.ExitPrice, price) {
+				t.Errorf("%s exit at %v for %v, want %s at %v for %v",
+					trade.ExitReason, trade.ExitTime, trade.ExitPrice, tt.reason, exit.Timestamp, price)
+			}
+		})
+	}
+}
+
+// oppositeSignal returns the first bar after signalIdx that signals SHORT
+func oppositeSignal(t *testing.T, candles []*models.Candle, config *models.StrategyConfig) int {
+	t.Helper()
+	ind := strategy.CalculateSeries(candles, config)
+	e := &Engine{}
+	for i := signalIdx + 1; i < len(candles); i++ {
+		if e.checkEntrySignal(i, ind.MainCF, ind.SecondCF, ind.ADX, ind.PlusDI, ind.MinusDI, ind.ATRPercent, config) == "SHORT" {
+			return i
+		}
+	}
+	t.Fatal("no opposite signal")
+	return 0
+}
@@ -0,0 +1,92 @@
+package backtester
+
+import (
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Exit reasons reported on trades
+const (
+	ExitStopLoss          = "STOP_LOSS"
+	ExitTakeProfit        = "TAKE_PROFIT"
+	ExitPartialTakeProfit = "PARTIAL_TAKE_PROFIT" // Scale-out fill that leaves the position open
+	ExitTrailingStop      = "TRAILING_STOP"
+	ExitBreakEven         = "BREAK_EVEN"
+	ExitTimeLimit         = "TIME_EXIT"
+	ExitSignal            = "SIGNAL"
+	ExitEndOfData         = "END_OF_DATA"
+	ExitSessionEnd        = "SESSION_END" // Last bar before a gap when the series is split into sessions
+)
+
+// Trailing stop modes
+const (
+	TrailATRChandelier = "ATR_CHANDELIER" // Extreme since entry minus N ATR
+	TrailPercent       = "PERCENT"        // Extreme since entry minus a percentage
+	TrailSecondCF      = "SECOND_CF"      // ATR trail, tightened to the bar when the trailing SecondCF turns against the trade
+)
+
+// updateStops ratchets the stop loss after a bar closes
+// Stops only ever move in the trade's favour; StopReason tracks which rule set the level
+func (e *Engine) updateStops(position *Position, candle *models.Candle, atr, secondCF float64, config *models.StrategyConfig) {
+	long := position.Side == "LONG"
+
+	if candle.High > position.HighWater {
+		position.HighWater = candle.High
+	}
+	if candle.Low < position.LowWater {
+		position.LowWater = candle.Low
+	}
+
+	// Move to break-even once the trade has run BreakEvenATR in profit
+	if config.BreakEvenATR > 0 && position.ATR > 0 {
+		favourable := position.HighWater - position.EntryPrice
+		if !long {
+			favourable = position.EntryPrice - position.LowWater
+		}
+		if favourable >= config.BreakEvenATR*position.ATR {
+			position.tightenStop(position.EntryPrice, ExitBreakEven)
+		}
+	}
+
+	extreme := position.HighWater
+	dir := 1.0
+	if !long {
+		extreme = position.LowWater
+		dir = -1.0
+	}
+
+	switch config.TrailingStopMode {
+	case TrailATRChandelier:
+		position.tightenStop(extreme-dir*config.TrailingATRMult*atr, ExitTrailingStop)
+
+	case TrailPercent:
+		position.tightenStop(extreme*(1-dir*config.TrailingPercent/100), ExitTrailingStop)
+
+	case TrailSecondCF:
+		if secondCF*dir < 0 {
+			// SecondCF flipped against the trade: trail right behind the bar
+			if long {
+				position.tightenStop(candle.Low, ExitTrailingStop)
+			} else {
+				position.tightenStop(candle.High, ExitTrailingStop)
+			}
+		} else {
+			position.tightenStop(extreme-dir*config.TrailingATRMult*atr, ExitTrailingStop)
+		}
+	}
+}
+
+// tightenStop moves the stop to level if that reduces risk
+func (p *Position) tightenStop(level float64, reason string) {
+	if p.Side == "LONG" && level > p.StopLoss {
+		p.StopLoss = level
+		p.StopReason = reason
+	} else if p.Side != "LONG" && level < p.StopLoss {
+		p.StopLoss = level
+		p.StopReason = reason
+	}
+}
+
+// timeExitDue reports whether the position has been held for MaxBarsInTrade bars
+func (e *Engine) timeExitDue(idx int, position *Position, config *models.StrategyConfig) bool {
+	return config.MaxBarsInTrade > 0 && idx-position.EntryIdx >= config.MaxBarsInTrade
+}
@@ -0,0 +1,372 @@
+package backtester
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/strategy"
+)
+
+// randomWalk returns n hourly candles of a random walk starting at 100
+func randomWalk(n int, seed int64) []*models.Candle {
+	rng := rand.New(rand.NewSource(seed))
+	candles := make([]*models.Candle, n)
+	price := 100.0
+	for i := range candles {
+		open := price
+		price *= 1 + rng.NormFloat64()*0.01
+		spread := price * rng.Float64() * 0.005
+		candles[i] = &models.Candle{
+			Interval:  "1h",
+			Timestamp: int64(i) * 3600_000,
+			Open:      open,
+			High:      max(open, price) + spread,
+			Low:       min(open, price) - spread,
+			Close:     price,
+			Volume:    1000,
+		}
+	}
+	return candles
+}
+
+func cfConfig() *models.StrategyConfig {
+	return &models.StrategyConfig{
+		TotalKlines:          50,
+		CustomAmplitude:      1,
+		SecondCFEnv:          20, // Keeps the SecondCF window near its minimum
+		Epsilon:              0.001,
+		NewTotalKlinesMin:    10,
+		NewTotalKlinesMax:    50,
+		SecondTotalKlinesMin: 10,
+	}
+}
+
+// TestTrailingSecondCFIsCausal checks that the SECOND_CF stop at bar i does not depend on
+// the candles after i
+func TestTrailingSecondCFIsCausal(t *testing.T) {
+	config := cfConfig()
+	config.TrailingStopMode = TrailSecondCF
+	config.TrailingATRMult = 3
+
+	tests := []struct {
+		side     string
+		step     float64 // Trend the position is held through
+		entryIdx int
+		stopIdx  int
+	}{
+		{"LONG", 0.005, 110, 130},
+		{"LONG", 0.002, 120, 190},
+		{"SHORT", -0.005, 110, 130},
+		{"SHORT", -0.002, 120, 190},
+	}
+	for _, tt := range tests {
+		walk := randomWalk(100, 7)
+		candles := append(walk, trend(walk[len(walk)-1], 100, tt.step)...)
+		original := trailStop(candles, tt.side, tt.entryIdx, tt.stopIdx, config)
+
+		// Same candles up to stopIdx, then a steady rise or fall
+		for _, step := range []float64{0.02, -0.02} {
+			altered := append(append([]*models.Candle{}, candles[:tt.stopIdx+1]...), trend(candles[tt.stopIdx], 50, step)...)
+			changed := trailStop(altered, tt.side, tt.entryIdx, tt.stopIdx, config)
+			if original.StopLoss != changed.StopLoss || original.StopReason != changed.StopReason {
+				t.Errorf("%s %d-%d: stop %v (%s) became %v (%s) when later candles changed by %v per bar",
+					tt.side, tt.entryIdx, tt.stopIdx, original.StopLoss, original.StopReason, changed.StopLoss, changed.StopReason, step)
+			}
+		}
+	}
+}
+
+// trend returns n hourly candles following last, each closing step (a fraction) beyond the previous one
+func trend(last *models.Candle, n int, step float64) []*models.Candle {
+	candles := make([]*models.Candle, n)
+	price := last.Close
+	for i := range candles {
+		open := price
+		price *= 1 + step
+		candles[i] = &models.Candle{
+			Interval:  last.Interval,
+			Timestamp: last.Timestamp + int64(i+1)*3600_000,
+			Open:      open,
+			High:      max(open, price),
+			Low:       min(open, price),
+			Close:     price,
+			Volume:    1000,
+		}
+	}
+	return candles
+}
+
+// trailStop opens a position on the close of entryIdx and ratchets its stop up to stopIdx
+func trailStop(candles []*models.Candle, side string, entryIdx, stopIdx int, config *models.StrategyConfig) *Position {
+	ind := strategy.CalculateSeries(candles, config)
+	e := &Engine{}
+	position := e.openPosition(side, candles[entryIdx], candles[entryIdx].Close, 1, ind.ATR[entryIdx], config)
+	for i := entryIdx + 1; i <= stopIdx; i++ {
+		e.updateStops(position, candles[i], ind.ATR[i], ind.TrailingSecondCF[i], config)
+	}
+	return position
+}
+
+// TestSecondCFTradesAreCausal checks that the trades a SECOND_CF backtest closes by bar i
+// are the same whatever candles follow i
+func TestSecondCFTradesAreCausal(t *testing.T) {
+	config := cfConfig()
+	config.TrailingStopMode = TrailSecondCF
+	config.TrailingATRMult = 3
+
+	candles := randomWalk(600, 3)
+	e := &Engine{}
+	full, _ := e.runSession(candles, nil, config, initialBalance, ExitEndOfData)
+
+	var compared int
+	for cut := 100; cut < len(candles); cut += 25 {
+		for _, step := range []float64{0.02, -0.02} {
+			altered := append(append([]*models.Candle{}, candles[:cut+1]...), trend(candles[cut], 50, step)...)
+			changed, _ := e.runSession(altered, nil, config, initialBalance, ExitEndOfData)
+
+			for i, trade := range full.Trades {
+				if trade.ExitTime.UnixMilli() >= candles[cut].Timestamp {
+					break
+				}
+				if i >= len(changed.Trades) || *trade.Fills[0] != *changed.Trades[i].Fills[0] {
+					t.Fatalf("trade %d changed when the candles after %d rose or fell by %v per bar", i, cut, step)
+				}
+				compared++
+			}
+		}
+	}
+	if compared == 0 {
+		t.Fatal("no trades closed before the cuts")
+	}
+}
+
+// TestEngineExits checks the exit rules of a position opened on the close of signalIdx
+func TestEngineExits(t *testing.T) {
+	tests := []struct {
+		name   string
+		side   string
+		setup  func(*models.StrategyConfig)
+		path   []bar
+		reason string   // Exit reason of the trade
+		fills  []string // Reasons of its exit fills
+		price  func(atr []float64) float64
+	}{
+		{
+			name: "take profit", side: "LONG",
+			path:   []bar{{101, 105, 100.9, 104.5}},
+			reason: ExitTakeProfit, fills: []string{ExitTakeProfit},
+			price: func(atr []float64) float64 { return 101 + 3*atr[signalIdx] },
+		},
+		{
+			name: "stop loss", side: "LONG",
+			path:   []bar{{101, 101.2, 98.7, 100.8}},
+			reason: ExitStopLoss, fills: []string{ExitStopLoss},
+			price: func(atr []float64) float64 { return 101 - 2*atr[signalIdx] },
+		},
+		{
+			name: "stop loss before take profit on the same bar", side: "LONG",
+			path:   []bar{{101, 105, 98, 101}},
+			reason: ExitStopLoss, fills: []string{ExitStopLoss},
+			price: func(atr []float64) float64 { return 101 - 2*atr[signalIdx] },
+		},
+		{
+			name: "short take profit", side: "SHORT",
+			path:   []bar{{99, 99.1, 95, 95.5}},
+			reason: ExitTakeProfit, fills: []string{ExitTakeProfit},
+			price: func(atr []float64) float64 { return 99 - 3*atr[signalIdx] },
+		},
+		{
+			name: "short stop loss", side: "SHORT",
+			path:   []bar{{99, 101.3, 98.8, 99.2}},
+			reason: ExitStopLoss, fills: []string{ExitStopLoss},
+			price: func(atr []float64) float64 { return 99 + 2*atr[signalIdx] },
+		},
+		{
+			name: "break-even", side: "LONG",
+			setup:  func(c *models.StrategyConfig) { c.BreakEvenATR = 1 },
+			path:   []bar{{101, 102.5, 100.9, 102.2}, {102.2, 102.3, 100.8, 101}},
+			reason: ExitBreakEven, fills: []string{ExitBreakEven},
+			price: func([]float64) float64 { return 101 },
+		},
+		{
+			name: "short break-even", side: "SHORT",
+			setup:  func(c *models.StrategyConfig) { c.BreakEvenATR = 1 },
+			path:   []bar{{99, 99.1, 97.5, 97.8}, {97.8, 99.2, 97.7, 99}},
+			reason: ExitBreakEven, fills: []string{ExitBreakEven},
+			price: func([]float64) float64 { return 99 },
+		},
+		{
+			name: "break-even not reached", side: "LONG",
+			setup:  func(c *models.StrategyConfig) { c.BreakEvenATR = 2 },
+			path:   append([]bar{{101, 102.5, 100.9, 102.2}, {102.2, 102.3, 100.8, 101}}, flat(101, 3)...),
+			reason: ExitEndOfData, fills: []string{ExitEndOfData},
+			price: func([]float64) float64 { return 101 },
+		},
+		{
+			name: "ATR chandelier trail", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.TrailingStopMode = TrailATRChandelier
+				c.TrailingATRMult = 1
+			},
+			path:   []bar{{101, 103.5, 101, 103.2}, {103.2, 103.3, 102, 102.5}},
+			reason: ExitTrailingStop, fills: []string{ExitTrailingStop},
+			price: func(atr []float64) float64 { return 103.5 - atr[signalIdx+1] },
+		},
+		{
+			name: "short ATR chandelier trail", side: "SHORT",
+			setup: func(c *models.StrategyConfig) {
+				c.TrailingStopMode = TrailATRChandelier
+				c.TrailingATRMult = 1
+			},
+			path:   []bar{{99, 99, 96.5, 96.8}, {96.8, 98, 96.7, 97.5}},
+			reason: ExitTrailingStop, fills: []string{ExitTrailingStop},
+			price: func(atr []float64) float64 { return 96.5 + atr[signalIdx+1] },
+		},
+		{
+			name: "percent trail", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.TrailingStopMode = TrailPercent
+				c.TrailingPercent = 1
+			},
+			path:   []bar{{101, 103.5, 101, 103.2}, {103.2, 103.3, 102, 102.5}},
+			reason: ExitTrailingStop, fills: []string{ExitTrailingStop},
+			price: func([]float64) float64 { return 103.5 * 0.99 },
+		},
+		{
+			name: "partial take profits", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.TakeProfitTargets = []models.TakeProfitTarget{{ATRMultiple: 1, Fraction: 0.5}, {ATRMultiple: 2, Fraction: 0.5}}
+			},
+			path:   []bar{{101, 102.5, 100.9, 102.3}, {102.3, 103.5, 102.2, 103.4}},
+			reason: ExitTakeProfit, fills: []string{ExitPartialTakeProfit, ExitTakeProfit},
+			price: func(atr []float64) float64 { return 101 + 1.5*atr[signalIdx] },
+		},
+		{
+			name: "partial take profits on one bar", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.TakeProfitTargets = []models.TakeProfitTarget{{ATRMultiple: 1, Fraction: 0.5}, {ATRMultiple: 2, Fraction: 0.5}}
+			},
+			path:   []bar{{101, 104, 100.9, 103.9}},
+			reason: ExitTakeProfit, fills: []string{ExitPartialTakeProfit, ExitTakeProfit},
+			price: func(atr []float64) float64 { return 101 + 1.5*atr[signalIdx] },
+		},
+		{
+			name: "partial take profit then stop loss", side: "SHORT",
+			setup: func(c *models.StrategyConfig) {
+				c.TakeProfitTargets = []models.TakeProfitTarget{{ATRMultiple: 1, Fraction: 0.5}, {ATRMultiple: 2, Fraction: 0.5}}
+			},
+			path:   []bar{{99, 99.1, 97.5, 97.7}, {97.7, 102, 97.6, 101.5}},
+			reason: ExitStopLoss, fills: []string{ExitPartialTakeProfit, ExitStopLoss},
+			price: func(atr []float64) float64 { return 99 + 0.5*atr[signalIdx] },
+		},
+		{
+			name: "time limit", side: "LONG",
+			setup:  func(c *models.StrategyConfig) { c.MaxBarsInTrade = 2 },
+			path:   flat(101, 4),
+			reason: ExitTimeLimit, fills: []string{ExitTimeLimit},
+			price: func([]float64) float64 { return 101 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := cfConfig()
+			if tt.setup != nil {
+				tt.setup(config)
+			}
+			candles := signalSeries(tt.side, tt.path...)
+			result := runStored(t, candles, nil, config, nil)
+
+			if len(result.Trades) != 1 {
+				t.Fatalf("%d trades, want 1", len(result.Trades))
+			}
+			trade := result.Trades[0]
+			if trade.Side != tt.side || trade.EntryTime.UnixMilli() != candles[signalIdx].Timestamp {
+				t.Fatalf("%s trade entered at %v, want %s on the signal bar", trade.Side, trade.EntryTime, tt.side)
+			}
+			if trade.ExitReason != tt.reason {
+				t.Errorf("exit reason %s, want %s", trade.ExitReason, tt.reason)
+			}
+			if want := tt.price(atrOf(candles)); !near(trade.ExitPrice, want) {
+				t.Errorf("exit price %v, want %v", trade.ExitPrice, want)
+			}
+
+			var reasons []string
+			var size float64
+			for _, fill := range trade.Fills {
+				reasons = append(reasons, fill.Reason)
+				size += fill.Size
+			}
+			if fmt.Sprint(reasons) != fmt.Sprint(tt.fills) {
+				t.Errorf("fills %v, want %v", reasons, tt.fills)
+			}
+			if !near(size, trade.Size) {
+				t.Errorf("fills close %v of a %v position", size, trade.Size)
+			}
+		})
+	}
+}
+
+// TestEntryFillKeepsTightenedStop checks that a volume-capped entry order filling its rest
+// after the trail has moved leaves the trailing stop in place
+func TestEntryFillKeepsTightenedStop(t *testing.T) {
+	config := cfConfig()
+	config.EntryOrderType = OrderLimit
+	config.EntryOffsetATR = 0.5
+	config.OrderTimeInForce = TIFGoodTillCancel
+	config.OrderMaxVolumePct = 0.005 // 5 per bar of the 9.9 ordered
+	config.TrailingStopMode = TrailATRChandelier
+	config.TrailingATRMult = 1
+
+	candles := signalSeries("LONG",
+		bar{100.8, 101, 100.3, 100.8},   // Fills 5 at the limit
+		bar{100.8, 103, 100.6, 102.8},   // Rallies above the limit, moving the trail
+		bar{102.8, 102.9, 100.4, 100.6}, // Fills the rest at the limit, then falls through the trail
+		bar{100.6, 100.9, 100.3, 100.6},
+	)
+	result := runStored(t, candles, nil, config, nil)
+
+	if len(result.Trades) != 1 {
+		t.Fatalf("%d trades, want 1", len(result.Trades))
+	}
+	trade := result.Trades[0]
+	atr := atrOf(candles)
+	if want := initialBalance * 0.1 / candles[signalIdx].Close; !near(trade.Size, want) {
+		t.Errorf("size %v, want the full order %v", trade.Size, want)
+	}
+	if want := 101 - 0.5*atr[signalIdx]; !near(trade.EntryPrice, want) {
+		t.Errorf("entry price %v, want %v", trade.EntryPrice, want)
+	}
+	if trade.ExitReason != ExitTrailingStop || trade.ExitTime.UnixMilli() != candles[signalIdx+3].Timestamp {
+		t.Fatalf("%s exit at %v, want a trailing stop on the bar of the last entry fill", trade.ExitReason, trade.ExitTime)
+	}
+	if want := 103 - atr[signalIdx+2]; !near(trade.ExitPrice, want) {
+		t.Errorf("exit price %v, want %v", trade.ExitPrice, want)
+	}
+}
+
+// TestDebugTraceSecondCF checks that the debug trace reports SecondCF unchanged, next to the
+// trailing SecondCF the stops use
+func TestDebugTraceSecondCF(t *testing.T) {
+	config := cfConfig()
+	candles := randomWalk(300, 5)
+	ind := strategy.CalculateSeries(candles, config)
+
+	e := &Engine{}
+	e.EnableDebug(0)
+	result, _ := e.runSession(candles, nil, config, initialBalance, ExitEndOfData)
+
+	var differ bool
+	for _, candle := range result.Debug {
+		i := int(candle.TS / 3600_000)
+		if candle.SecondCF != ind.SecondCF[i] || candle.TrailingSecondCF != ind.TrailingSecondCF[i] {
+			t.Fatalf("candle %d: second_cf %v and trailing_second_cf %v, want %v and %v",
+				i, candle.SecondCF, candle.TrailingSecondCF, ind.SecondCF[i], ind.TrailingSecondCF[i])
+		}
+		differ = differ || ind.SecondCF[i] != ind.TrailingSecondCF[i]
+	}
+	if len(result.Debug) == 0 || !differ {
+		t.Fatal("the trace cannot tell SecondCF from the trailing SecondCF")
+	}
+}
@@ -0,0 +1,42 @@
+package backtester
+
+import (
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// fundingSchedule hands out funding events in time order as the backtest advances
+type fundingSchedule struct {
+	rates []*models.FundingRate
+	next  int
+}
+
+// newFundingSchedule creates a schedule from rates sorted by timestamp
+func newFundingSchedule(rates []*models.FundingRate) *fundingSchedule {
+	return &fundingSchedule{rates: rates}
+}
+
+// due returns the events at or before ts that have not been returned yet
+func (f *fundingSchedule) due(ts int64) []*models.FundingRate {
+	start := f.next
+	for f.next < len(f.rates) && f.rates[f.next].Timestamp <= ts {
+		f.next++
+	}
+	return f.rates[start:f.next]
+}
+
+// applyFunding settles a funding event on the position and returns the payment
+// Longs pay and shorts receive when the rate is positive; the notional is valued
+// at the mark price, or at price when the event has none
+func (e *Engine) applyFunding(position *Position, rate *models.FundingRate, price float64) float64 {
+	if rate.MarkPrice > 0 {
+		price = rate.MarkPrice
+	}
+
+	payment := -rate.Rate * position.Size * price
+	if position.Side == "SHORT" {
+		payment = -payment
+	}
+
+	position.Funding += payment
+	return payment
+}
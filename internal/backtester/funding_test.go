@@ -0,0 +1,52 @@
+package backtester
+
+import (
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// TestEngineFunding checks that funding events are settled on open positions only, at the
+// next bar's open or at the event's mark price, and carried into the trade PnL
+func TestEngineFunding(t *testing.T) {
+	const hour = 3600_000
+
+	tests := []struct {
+		side  string
+		price float64 // Entry price and open of the bars the position is held through
+		want  func(size float64) float64
+	}{
+		{"LONG", 101, func(size float64) float64 { return -0.001*size*101 + 0.002*size*110 }},
+		{"SHORT", 99, func(size float64) float64 { return 0.001*size*99 - 0.002*size*110 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.side, func(t *testing.T) {
+			funding := []*models.FundingRate{
+				{Timestamp: 70 * hour, Rate: 0.01},                                     // Before the entry
+				{Timestamp: (signalIdx + 2) * hour, Rate: 0.001},                       // Settled at that bar's open
+				{Timestamp: (signalIdx+3)*hour + hour/2, Rate: -0.002, MarkPrice: 110}, // Settled on the next bar at the mark price
+			}
+			candles := signalSeries(tt.side, flat(tt.price, 4)...)
+			result := runStored(t, candles, funding, cfConfig(), nil)
+
+			if len(result.Trades) != 1 {
+				t.Fatalf("%d trades, want 1", len(result.Trades))
+			}
+			trade := result.Trades[0]
+			if trade.ExitReason != ExitEndOfData || trade.ExitPrice != tt.price {
+				t.Fatalf("trade exited at %v (%s), want %v at the end of data", trade.ExitPrice, trade.ExitReason, tt.price)
+			}
+
+			want := tt.want(trade.Size)
+			if !near(trade.Funding, want) {
+				t.Errorf("funding %v, want %v", trade.Funding, want)
+			}
+			if !near(trade.PnL, want) {
+				t.Errorf("PnL %v, want the funding %v", trade.PnL, want)
+			}
+			if !near(result.Metrics["total_funding"], want) {
+				t.Errorf("total_funding %v, want %v", result.Metrics["total_funding"], want)
+			}
+		})
+	}
+}
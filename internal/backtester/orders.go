@@ -0,0 +1,325 @@
+package backtester
+
+import (
+	"math"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Order types
+const (
+	OrderMarket    = "MARKET"
+	OrderLimit     = "LIMIT"
+	OrderStop      = "STOP"
+	OrderStopLimit = "STOP_LIMIT"
+)
+
+// Time in force
+const (
+	TIFGoodTillCancel = "GTC"  // Working until filled or cancelled
+	TIFBarsToLive     = "BARS" // Expires after BarsToLive bars
+)
+
+// Order lifecycle events
+const (
+	EventSubmitted          = "SUBMITTED"
+	EventTriggered          = "TRIGGERED"
+	EventFilled             = "FILLED"
+	EventPartiallyFilled    = "PARTIALLY_FILLED"
+	EventCancelled          = "CANCELLED"
+	EventPartiallyCancelled = "PARTIALLY_CANCELLED"
+	EventExpired            = "EXPIRED"
+)
+
+// Order is a pending entry order
+type Order struct {
+	ID          int
+	Type        string // LIMIT, STOP or STOP_LIMIT
+	Side        string // LONG buys, SHORT sells
+	Quantity    float64
+	Filled      float64
+	LimitPrice  float64
+	StopPrice   float64
+	TimeInForce string
+	BarsToLive  int
+	ActiveIdx   int     // First bar the order can fill on
+	ATR         float64 // ATR at the signal bar, used for exits
+	triggered   bool
+}
+
+// Remaining returns the unfilled quantity
+func (o *Order) Remaining() float64 {
+	return o.Quantity - o.Filled
+}
+
+// OrderFill is a (possibly partial) execution of an order
+type OrderFill struct {
+	Order    *Order
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook holds working orders and, when recording, their event log
+type OrderBook struct {
+	nextID       int
+	orders       []*Order
+	maxVolumePct float64
+	recording    bool
+	events       []models.OrderEvent
+}
+
+// NewOrderBook creates an empty order book
+// maxVolumePct limits fills to a share of each bar's volume (0 = unlimited)
+func NewOrderBook(maxVolumePct float64) *OrderBook {
+	return &OrderBook{nextID: 1, maxVolumePct: maxVolumePct}
+}
+
+// RecordEvents keeps a log of order events for DrainEvents; without it no events are kept
+func (b *OrderBook) RecordEvents() {
+	b.recording = true
+}
+
+// Submit adds an order to the book
+func (b *OrderBook) Submit(order *Order, ts int64) *Order {
+	order.ID = b.nextID
+	b.nextID++
+	if order.TimeInForce != TIFBarsToLive {
+		order.TimeInForce = TIFGoodTillCancel
+	}
+	b.orders = append(b.orders, order)
+	b.record(order, ts, EventSubmitted, b.referencePrice(order), order.Quantity, "")
+	return order
+}
+
+// Working returns true if any order is still open
+func (b *OrderBook) Working() bool {
+	return len(b.orders) > 0
+}
+
+// WorkingSide returns the side of the oldest working order
+func (b *OrderBook) WorkingSide() string {
+	if len(b.orders) == 0 {
+		return ""
+	}
+	return b.orders[0].Side
+}
+
+// Cancel removes an order and cancels its remaining quantity
+// Partially filled orders keep their fills and report a partial cancellation of the rest,
+// as do BARS orders that expire after a partial fill
+func (b *OrderBook) Cancel(id int, ts int64, reason string) {
+	for i, order := range b.orders {
+		if order.ID != id {
+			continue
+		}
+		event := EventCancelled
+		if order.Filled > 0 {
+			event = EventPartiallyCancelled
+		}
+		b.record(order, ts, event, b.referencePrice(order), order.Remaining(), reason)
+		b.orders = append(b.orders[:i], b.orders[i+1:]...)
+		return
+	}
+}
+
+// CancelAll cancels every working order
+func (b *OrderBook) CancelAll(ts int64, reason string) {
+	for len(b.orders) > 0 {
+		b.Cancel(b.orders[0].ID, ts, reason)
+	}
+}
+
+// Process matches working orders against the candle at idx and returns fills
+// Orders past their time in force expire before matching
+func (b *OrderBook) Process(idx int, candle *models.Candle) []OrderFill {
+	var fills []OrderFill
+	remaining := b.orders[:0]
+
+	for _, order := range b.orders {
+		if idx < order.ActiveIdx {
+			remaining = append(remaining, order)
+			continue
+		}
+		if order.TimeInForce == TIFBarsToLive && idx >= order.ActiveIdx+order.BarsToLive {
+			event := EventExpired
+			if order.Filled > 0 {
+				event = EventPartiallyCancelled
+			}
+			b.record(order, candle.Timestamp, event, b.referencePrice(order), order.Remaining(), "time in force")
+			continue
+		}
+
+		price, ok := b.match(order, candle)
+		if !ok {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		quantity := order.Remaining()
+		if b.maxVolumePct > 0 {
+			quantity = math.Min(quantity, candle.Volume*b.maxVolumePct)
+		}
+		if quantity <= 0 {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		order.Filled += quantity
+		fills = append(fills, OrderFill{Order: order, Price: price, Quantity: quantity})
+
+		if order.Remaining() > 1e-12 {
+			b.record(order, candle.Timestamp, EventPartiallyFilled, price, quantity, "")
+			remaining = append(remaining, order)
+			continue
+		}
+		b.record(order, candle.Timestamp, EventFilled, price, quantity, "")
+	}
+
+	b.orders = remaining
+	return fills
+}
+
+// match returns the fill price if the candle trades through the order
+// Gaps through the order price fill at the open
+func (b *OrderBook) match(order *Order, candle *models.Candle) (float64, bool) {
+	buy := order.Side == "LONG"
+
+	switch order.Type {
+	case OrderLimit:
+		return matchLimit(buy, order.LimitPrice, candle)
+
+	case OrderStop:
+		return matchStop(buy, order.StopPrice, candle)
+
+	case OrderStopLimit:
+		if !order.triggered {
+			trigger, ok := matchStop(buy, order.StopPrice, candle)
+			if !ok {
+				return 0, false
+			}
+			order.triggered = true
+			b.record(order, candle.Timestamp, EventTriggered, trigger, order.Remaining(), "")
+
+			// Fill at the trigger price only if it is within the limit,
+			// otherwise the order rests as a limit from the next bar
+			if (buy && trigger <= order.LimitPrice) || (!buy && trigger >= order.LimitPrice) {
+				return trigger, true
+			}
+			return 0, false
+		}
+		return matchLimit(buy, order.LimitPrice, candle)
+	}
+
+	return 0, false
+}
+
+// matchLimit fills buys at or below price and sells at or above it
+func matchLimit(buy bool, price float64, candle *models.Candle) (float64, bool) {
+	if buy {
+		if candle.Open <= price {
+			return candle.Open, true
+		}
+		if candle.Low <= price {
+			return price, true
+		}
+		return 0, false
+	}
+
+	if candle.Open >= price {
+		return candle.Open, true
+	}
+	if candle.High >= price {
+		return price, true
+	}
+	return 0, false
+}
+
+// matchStop fills buys at or above price and sells at or below it
+func matchStop(buy bool, price float64, candle *models.Candle) (float64, bool) {
+	if buy {
+		if candle.Open >= price {
+			return candle.Open, true
+		}
+		if candle.High >= price {
+			return price, true
+		}
+		return 0, false
+	}
+
+	if candle.Open <= price {
+		return candle.Open, true
+	}
+	if candle.Low <= price {
+		return price, true
+	}
+	return 0, false
+}
+
+// referencePrice is the price reported for non-fill events
+func (b *OrderBook) referencePrice(order *Order) float64 {
+	if order.Type == OrderStop || (order.Type == OrderStopLimit && !order.triggered) {
+		return order.StopPrice
+	}
+	return order.LimitPrice
+}
+
+// record appends an event to the log if events are recorded
+func (b *OrderBook) record(order *Order, ts int64, event string, price, quantity float64, reason string) {
+	if !b.recording {
+		return
+	}
+	b.events = append(b.events, models.OrderEvent{
+		OrderID:  order.ID,
+		TS:       ts,
+		Type:     order.Type,
+		Side:     order.Side,
+		Event:    event,
+		Price:    price,
+		Quantity: quantity,
+		Reason:   reason,
+	})
+}
+
+// DrainEvents returns and clears events recorded since the last call
+func (b *OrderBook) DrainEvents() []models.OrderEvent {
+	events := b.events
+	b.events = nil
+	return events
+}
+
+// newEntryOrder builds an entry order for a signal on the given close and ATR
+// LIMIT orders wait for a pullback, STOP orders for a continuation, and
+// STOP_LIMIT orders cap the price paid after the stop triggers
+func newEntryOrder(config *models.StrategyConfig, side string, close, atr, quantity float64, activeIdx int) *Order {
+	dir := 1.0
+	if side == "SHORT" {
+		dir = -1.0
+	}
+	offset := config.EntryOffsetATR * atr
+
+	order := &Order{
+		Type:        config.EntryOrderType,
+		Side:        side,
+		Quantity:    quantity,
+		TimeInForce: config.OrderTimeInForce,
+		BarsToLive:  config.OrderBarsToLive,
+		ActiveIdx:   activeIdx,
+		ATR:         atr,
+	}
+
+	switch order.Type {
+	case OrderLimit:
+		order.LimitPrice = close - dir*offset
+	case OrderStop:
+		order.StopPrice = close + dir*offset
+	case OrderStopLimit:
+		order.StopPrice = close + dir*offset
+		order.LimitPrice = order.StopPrice + dir*config.StopLimitOffsetATR*atr
+	}
+
+	if order.TimeInForce == TIFBarsToLive && order.BarsToLive <= 0 {
+		order.BarsToLive = 1
+	}
+
+	return order
+}
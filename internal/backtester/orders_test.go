@@ -0,0 +1,177 @@
+package backtester
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// TestEngineEntryOrders checks how entry orders submitted on the close of signalIdx fill
+// against the high and low of the following bars, and when they expire
+func TestEngineEntryOrders(t *testing.T) {
+	limit := func(c *models.StrategyConfig) {
+		c.EntryOrderType = OrderLimit
+		c.EntryOffsetATR = 0.5
+		c.OrderTimeInForce = TIFGoodTillCancel
+	}
+	stopLimit := func(c *models.StrategyConfig) {
+		c.EntryOrderType = OrderStopLimit
+		c.EntryOffsetATR = 0.5
+		c.StopLimitOffsetATR = 0.2
+		c.OrderTimeInForce = TIFGoodTillCancel
+	}
+
+	tests := []struct {
+		name     string
+		side     string
+		setup    func(*models.StrategyConfig)
+		path     []bar
+		events   []string // Order events in the debug trace
+		entryIdx int      // Bar the position opens on, 0 if it never does
+		price    func(atr float64) float64
+		size     float64 // Position size if capped by volume, 0 for the full size
+	}{
+		{
+			name: "limit fills at its price", side: "LONG", setup: limit,
+			path:     []bar{{101, 101.2, 100.3, 100.8}},
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 101 - 0.5*atr },
+		},
+		{
+			name: "limit gapped through fills at the open", side: "LONG", setup: limit,
+			path:     []bar{{100.2, 100.6, 100.1, 100.5}},
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(float64) float64 { return 100.2 },
+		},
+		{
+			name: "short limit", side: "SHORT", setup: limit,
+			path:     []bar{{99, 99.7, 98.9, 99.2}},
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 99 + 0.5*atr },
+		},
+		{
+			name: "good till cancelled", side: "LONG", setup: limit,
+			path:     append(flat(101, 2), bar{101, 101, 100, 100.5}),
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 3,
+			price:    func(atr float64) float64 { return 101 - 0.5*atr },
+		},
+		{
+			name: "bars to live expiry", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				limit(c)
+				c.OrderTimeInForce = TIFBarsToLive
+				c.OrderBarsToLive = 2
+			},
+			path:   append(flat(101, 2), bar{101, 101, 100, 100.5}),
+			events: []string{EventSubmitted, EventExpired},
+		},
+		{
+			name: "stop fills at its price", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.EntryOrderType = OrderStop
+				c.EntryOffsetATR = 0.5
+			},
+			path:     []bar{{101, 101.8, 100.9, 101.7}},
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 101 + 0.5*atr },
+		},
+		{
+			name: "stop gapped through fills at the open", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				c.EntryOrderType = OrderStop
+				c.EntryOffsetATR = 0.5
+			},
+			path:     []bar{{102, 102.3, 101.9, 102.2}},
+			events:   []string{EventSubmitted, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(float64) float64 { return 102 },
+		},
+		{
+			name: "stop-limit fills at the trigger", side: "LONG", setup: stopLimit,
+			path:     []bar{{101, 101.7, 100.9, 101.6}},
+			events:   []string{EventSubmitted, EventTriggered, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 101 + 0.5*atr },
+		},
+		{
+			name: "stop-limit gapped over its limit rests as a limit", side: "LONG", setup: stopLimit,
+			path:     []bar{{102, 102.3, 101.9, 102.2}, {102.1, 102.1, 101.6, 101.8}},
+			events:   []string{EventSubmitted, EventTriggered, EventFilled},
+			entryIdx: signalIdx + 2,
+			price:    func(atr float64) float64 { return 101 + 0.7*atr },
+		},
+		{
+			name: "volume cap fills over several bars", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				limit(c)
+				c.OrderMaxVolumePct = 0.005
+			},
+			path:     []bar{{100.8, 101, 100.3, 100.8}, {100.8, 101, 100.3, 100.8}, {100.8, 101, 100.3, 100.8}},
+			events:   []string{EventSubmitted, EventPartiallyFilled, EventFilled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 101 - 0.5*atr },
+		},
+		{
+			name: "volume cap then expiry", side: "LONG",
+			setup: func(c *models.StrategyConfig) {
+				limit(c)
+				c.OrderMaxVolumePct = 0.005
+				c.OrderTimeInForce = TIFBarsToLive
+				c.OrderBarsToLive = 1
+			},
+			path:     []bar{{100.8, 101, 100.3, 100.8}, {100.8, 101, 100.3, 100.8}, {100.8, 101, 100.3, 100.8}},
+			events:   []string{EventSubmitted, EventPartiallyFilled, EventPartiallyCancelled},
+			entryIdx: signalIdx + 1,
+			price:    func(atr float64) float64 { return 101 - 0.5*atr },
+			size:     5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := cfConfig()
+			tt.setup(config)
+			candles := signalSeries(tt.side, tt.path...)
+			result := runStored(t, candles, nil, config, func(e *Engine) { e.EnableDebug(0) })
+
+			var events []string
+			for _, candle := range result.Debug {
+				for _, event := range candle.OrderEvents {
+					events = append(events, event.Event)
+				}
+			}
+			if fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("order events %v, want %v", events, tt.events)
+			}
+
+			if tt.entryIdx == 0 {
+				if len(result.Trades) != 0 {
+					t.Fatalf("%d trades, want none", len(result.Trades))
+				}
+				return
+			}
+			if len(result.Trades) != 1 {
+				t.Fatalf("%d trades, want 1", len(result.Trades))
+			}
+			trade := result.Trades[0]
+			if trade.Side != tt.side || trade.EntryTime.UnixMilli() != candles[tt.entryIdx].Timestamp {
+				t.Errorf("%s trade entered at %v, want %s on bar %d", trade.Side, trade.EntryTime, tt.side, tt.entryIdx)
+			}
+			if want := tt.price(atrOf(candles)[signalIdx]); !near(trade.EntryPrice, want) {
+				t.Errorf("entry price %v, want %v", trade.EntryPrice, want)
+			}
+			size := tt.size
+			if size == 0 {
+				size = initialBalance * 0.1 / candles[signalIdx].Close
+			}
+			if !near(trade.Size, size) {
+				t.Errorf("size %v, want %v", trade.Size, size)
+			}
+		})
+	}
+}
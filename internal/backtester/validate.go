@@ -0,0 +1,171 @@
+package backtester
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// FieldError is a strategy config field that failed validation
+type FieldError struct {
+	Field   string `json:"field"` // JSON name of the field, e.g. take_profit_targets[1].fraction
+	Message string `json:"message"`
+}
+
+// ConfigError lists the invalid fields of a strategy config
+type ConfigError struct {
+	Fields []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "invalid strategy config: " + strings.Join(parts, "; ")
+}
+
+// configValidator collects the field errors of a config
+type configValidator struct {
+	fields []FieldError
+}
+
+// fail records a field error
+func (v *configValidator) fail(field, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// positive requires value > 0
+func (v *configValidator) positive(field string, value float64) {
+	if value <= 0 {
+		v.fail(field, "must be greater than 0")
+	}
+}
+
+// nonNegative requires value >= 0
+func (v *configValidator) nonNegative(field string, value float64) {
+	if value < 0 {
+		v.fail(field, "must not be negative")
+	}
+}
+
+// between requires lo <= value <= hi
+func (v *configValidator) between(field string, value, lo, hi float64) {
+	if value < lo || value > hi {
+		v.fail(field, "must be between %g and %g", lo, hi)
+	}
+}
+
+// oneOf requires value to be empty or one of allowed
+func (v *configValidator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(field, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// ValidateConfig checks a strategy config against the values the engine supports
+// It returns a *ConfigError listing every invalid field, or nil; zero values of optional
+// settings (modes, periods, exits) select the defaults and are valid
+func ValidateConfig(config *models.StrategyConfig) error {
+	v := &configValidator{}
+
+	// General
+	v.nonNegative("initial_equity", config.InitialEquity)
+	v.nonNegative("max_open_positions", float64(config.MaxOpenPositions))
+	v.nonNegative("leverage", config.Leverage)
+
+	// CF parameters
+	v.positive("total_klines", float64(config.TotalKlines))
+	v.positive("custom_amplitude", config.CustomAmplitude)
+	v.nonNegative("main_cf_start", config.MainCFStart)
+	v.nonNegative("second_cf_env", config.SecondCFEnv)
+	v.positive("epsilon", config.Epsilon)
+
+	// Bounds
+	v.positive("new_total_klines_min", float64(config.NewTotalKlinesMin))
+	v.positive("new_total_klines_max", float64(config.NewTotalKlinesMax))
+	if config.NewTotalKlinesMin > config.NewTotalKlinesMax {
+		v.fail("new_total_klines_min", "must not exceed new_total_klines_max (%d)", config.NewTotalKlinesMax)
+	}
+	v.positive("second_total_klines_min", float64(config.SecondTotalKlinesMin))
+
+	// ATR/ADX
+	v.nonNegative("atr_short_period", float64(config.ATRShortPeriod))
+	v.nonNegative("atr_long_period", float64(config.ATRLongPeriod))
+	if config.ATRShortPeriod > 0 && config.ATRLongPeriod > 0 && config.ATRShortPeriod > config.ATRLongPeriod {
+		v.fail("atr_short_period", "must not exceed atr_long_period (%d)", config.ATRLongPeriod)
+	}
+	v.nonNegative("adx_period", float64(config.ADXPeriod))
+	v.between("adx_threshold", config.ADXThreshold, 0, 100)
+	v.between("adx_min", config.ADXMin, 0, 100)
+	v.nonNegative("kvol", config.Kvol)
+
+	// Execution
+	v.oneOf("execution_mode", config.ExecutionMode, ExecSignalClose, ExecNextOpen, ExecNextVWAP)
+	v.nonNegative("execution_latency", float64(config.ExecutionLatency))
+
+	// Entry orders
+	v.oneOf("entry_order_type", config.EntryOrderType, OrderMarket, OrderLimit, OrderStop, OrderStopLimit)
+	v.nonNegative("entry_offset_atr", config.EntryOffsetATR)
+	v.nonNegative("stop_limit_offset_atr", config.StopLimitOffsetATR)
+	v.oneOf("order_time_in_force", config.OrderTimeInForce, TIFGoodTillCancel, TIFBarsToLive)
+	if config.OrderTimeInForce == TIFBarsToLive {
+		v.positive("order_bars_to_live", float64(config.OrderBarsToLive))
+	} else {
+		v.nonNegative("order_bars_to_live", float64(config.OrderBarsToLive))
+	}
+	v.between("order_max_volume_pct", config.OrderMaxVolumePct, 0, 1)
+
+	// Exit management
+	v.oneOf("trailing_stop_mode", config.TrailingStopMode, TrailATRChandelier, TrailPercent, TrailSecondCF)
+	switch config.TrailingStopMode {
+	case TrailATRChandelier, TrailSecondCF:
+		v.positive("trailing_atr_mult", config.TrailingATRMult)
+	default:
+		v.nonNegative("trailing_atr_mult", config.TrailingATRMult)
+	}
+	if config.TrailingStopMode == TrailPercent {
+		if config.TrailingPercent <= 0 || config.TrailingPercent >= 100 {
+			v.fail("trailing_percent", "must be greater than 0 and less than 100")
+		}
+	} else {
+		v.nonNegative("trailing_percent", config.TrailingPercent)
+	}
+	v.nonNegative("break_even_atr", config.BreakEvenATR)
+	v.nonNegative("max_bars_in_trade", float64(config.MaxBarsInTrade))
+
+	// Scale-out targets
+	total := 0.0
+	for i, target := range config.TakeProfitTargets {
+		field := fmt.Sprintf("take_profit_targets[%d]", i)
+		v.positive(field+".atr_multiple", target.ATRMultiple)
+		if target.Fraction <= 0 || target.Fraction > 1 {
+			v.fail(field+".fraction", "must be greater than 0 and at most 1")
+		}
+		if i > 0 && target.ATRMultiple <= config.TakeProfitTargets[i-1].ATRMultiple {
+			v.fail(field+".atr_multiple", "must be greater than the previous target's")
+		}
+		total += target.Fraction
+	}
+	if total > 1+1e-9 {
+		v.fail("take_profit_targets", "fractions must add up to at most 1 (got %g)", total)
+	}
+
+	// Fees and risk
+	if config.FeeRate < 0 || config.FeeRate >= 1 {
+		v.fail("fee_rate", "must be at least 0 and less than 1")
+	}
+	v.nonNegative("max_drawdown_stop", config.MaxDrawdownStop)
+
+	if len(v.fields) > 0 {
+		return &ConfigError{Fields: v.fields}
+	}
+	return nil
+}
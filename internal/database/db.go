@@ -3,125 +3,127 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/glebarez/go-sqlite"
 	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/lib/pq"
 )
 
 // DB wraps database connection and provides data access methods
 type DB struct {
-	conn *sql.DB
+	conn    *sql.DB
+	dialect *dialect
 }
 
-// InitDB initializes database connection and creates tables
+// InitDB initializes database connection and verifies the schema is up to date
+// PostgreSQL returns ErrSchemaOutOfDate if migrations are pending; a local SQLite
+// file is migrated in place since no other process shares it
 func InitDB(connString string) (*DB, error) {
-	conn, err := sql.Open("postgres", connString)
+	db, err := Connect(connString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	if err := conn.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	migrator, err := db.Migrator()
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	db := &DB{conn: conn}
-	if err := db.createTables(); err != nil {
+	if db.dialect == sqliteDialect {
+		_, err = migrator.Up()
+	} else {
+		err = migrator.Check()
+	}
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
+// Connect opens a database connection without checking the schema
+// The backend is selected by connection string, see parseConnString
+func Connect(connString string) (*DB, error) {
+	d, dsn, err := parseConnString(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// SQLite allows a single writer; serialize access instead of failing with SQLITE_BUSY
+	if d == sqliteDialect {
+		conn.SetMaxOpenConns(1)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &DB{conn: conn, dialect: d}, nil
+}
+
 // Close closes database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// createTables creates all necessary database tables
-func (db *DB) createTables() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS instruments (
-		id SERIAL PRIMARY KEY,
-		symbol VARCHAR(50) NOT NULL,
-		timeframe VARCHAR(10) NOT NULL,
-		exchange VARCHAR(50) NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(symbol, timeframe, exchange)
-	);
-
-	CREATE TABLE IF NOT EXISTS candles (
-		id SERIAL PRIMARY KEY,
-		instrument_id INTEGER NOT NULL REFERENCES instruments(id),
-		timestamp BIGINT NOT NULL,
-		open NUMERIC(20, 8) NOT NULL,
-		high NUMERIC(20, 8) NOT NULL,
-		low NUMERIC(20, 8) NOT NULL,
-		close NUMERIC(20, 8) NOT NULL,
-		volume NUMERIC(20, 8) NOT NULL,
-		UNIQUE(instrument_id, timestamp)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_candles_instrument_timestamp 
-		ON candles(instrument_id, timestamp);
-
-	CREATE TABLE IF NOT EXISTS strategies (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(100) NOT NULL UNIQUE,
-		config JSONB NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS backtest_results (
-		id SERIAL PRIMARY KEY,
-		instrument_id INTEGER NOT NULL REFERENCES instruments(id),
-		strategy_id INTEGER NOT NULL REFERENCES strategies(id),
-		start_time TIMESTAMP NOT NULL,
-		end_time TIMESTAMP NOT NULL,
-		total_trades INTEGER NOT NULL,
-		winning_trades INTEGER NOT NULL,
-		losing_trades INTEGER NOT NULL,
-		win_rate NUMERIC(5, 2) NOT NULL,
-		total_pnl NUMERIC(20, 8) NOT NULL,
-		total_return NUMERIC(10, 4) NOT NULL,
-		metrics JSONB,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS trades (
-		id SERIAL PRIMARY KEY,
-		backtest_id INTEGER NOT NULL REFERENCES backtest_results(id),
-		side VARCHAR(10) NOT NULL,
-		entry_price NUMERIC(20, 8) NOT NULL,
-		entry_time TIMESTAMP NOT NULL,
-		exit_price NUMERIC(20, 8) NOT NULL,
-		exit_time TIMESTAMP NOT NULL,
-		size NUMERIC(20, 8) NOT NULL,
-		pnl NUMERIC(20, 8) NOT NULL
-	);
-	`
-
-	_, err := db.conn.Exec(schema)
-	return err
+// Migrator returns a schema migrator for this database
+func (db *DB) Migrator() (*Migrator, error) {
+	return newMigrator(db.conn, db.dialect)
+}
+
+// Backend returns the name of the database backend (postgres or sqlite)
+func (db *DB) Backend() string {
+	return db.dialect.name
+}
+
+// instrumentColumns lists instrument columns in models.Instrument order
+func (db *DB) instrumentColumns() string {
+	return `id, symbol, exchange, kind, vol_bucket, ` + db.dialect.epochOf("created_at")
 }
 
 // SaveInstrument saves an instrument to database
+// An existing instrument with the same symbol and exchange is reused; its kind and volatility
+// bucket are only replaced by non-empty values, and inst is updated with the stored ones
 func (db *DB) SaveInstrument(inst *models.Instrument) error {
 	query := `
-		INSERT INTO instruments (symbol, timeframe, exchange) 
-		VALUES ($1, $2, $3) 
-		ON CONFLICT (symbol, timeframe, exchange) DO UPDATE SET symbol = EXCLUDED.symbol
-		RETURNING id`
-	return db.conn.QueryRow(query, inst.Symbol, inst.Timeframe, inst.Exchange).Scan(&inst.ID)
+		INSERT INTO instruments (symbol, exchange, kind, vol_bucket) 
+		VALUES ($1, $2, $3, $4) 
+		ON CONFLICT (symbol, exchange) DO UPDATE SET
+			kind = COALESCE(NULLIF(EXCLUDED.kind, ''), instruments.kind),
+			vol_bucket = COALESCE(NULLIF(EXCLUDED.vol_bucket, ''), instruments.vol_bucket)
+		RETURNING id, kind, vol_bucket, ` + db.dialect.epochOf("created_at")
+	return db.conn.QueryRow(query, inst.Symbol, inst.Exchange, inst.Kind, inst.VolBucket).Scan(&inst.ID, &inst.Kind, &inst.VolBucket, &inst.CreatedAt)
 }
 
-// GetInstrumentBySymbol retrieves an instrument by symbol and timeframe
-func (db *DB) GetInstrumentBySymbol(symbol, timeframe string) (*models.Instrument, error) {
-	query := `SELECT id, symbol, timeframe, exchange FROM instruments WHERE symbol = $1 AND timeframe = $2`
+// GetInstrumentBySymbol retrieves an instrument by symbol and exchange
+func (db *DB) GetInstrumentBySymbol(symbol, exchange string) (*models.Instrument, error) {
+	query := `SELECT ` + db.instrumentColumns() + ` FROM instruments WHERE symbol = $1 AND exchange = $2`
 	inst := &models.Instrument{}
-	err := db.conn.QueryRow(query, symbol, timeframe).Scan(&inst.ID, &inst.Symbol, &inst.Timeframe, &inst.Exchange)
+	err := db.conn.QueryRow(query, symbol, exchange).Scan(&inst.ID, &inst.Symbol, &inst.Exchange,
+		&inst.Kind, &inst.VolBucket, &inst.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// GetInstrumentByID retrieves an instrument by ID
+func (db *DB) GetInstrumentByID(id int64) (*models.Instrument, error) {
+	query := `SELECT ` + db.instrumentColumns() + ` FROM instruments WHERE id = $1`
+	inst := &models.Instrument{}
+	err := db.conn.QueryRow(query, id).Scan(&inst.ID, &inst.Symbol, &inst.Exchange,
+		&inst.Kind, &inst.VolBucket, &inst.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +132,7 @@ func (db *DB) GetInstrumentBySymbol(symbol, timeframe string) (*models.Instrumen
 
 // GetAllInstruments retrieves all instruments from database
 func (db *DB) GetAllInstruments() ([]*models.Instrument, error) {
-	query := `SELECT id, symbol, timeframe, exchange FROM instruments ORDER BY symbol`
+	query := `SELECT ` + db.instrumentColumns() + ` FROM instruments ORDER BY symbol`
 	rows, err := db.conn.Query(query)
 	if err != nil {
 		return nil, err
@@ -140,7 +142,8 @@ func (db *DB) GetAllInstruments() ([]*models.Instrument, error) {
 	var instruments []*models.Instrument
 	for rows.Next() {
 		inst := &models.Instrument{}
-		if err := rows.Scan(&inst.ID, &inst.Symbol, &inst.Timeframe, &inst.Exchange); err != nil {
+		if err := rows.Scan(&inst.ID, &inst.Symbol, &inst.Exchange,
+			&inst.Kind, &inst.VolBucket, &inst.CreatedAt); err != nil {
 			return nil, err
 		}
 		instruments = append(instruments, inst)
@@ -151,27 +154,167 @@ func (db *DB) GetAllInstruments() ([]*models.Instrument, error) {
 // SaveCandle saves a candle to database
 func (db *DB) SaveCandle(candle *models.Candle) error {
 	query := `
-		INSERT INTO candles (instrument_id, timestamp, open, high, low, close, volume)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (instrument_id, timestamp) DO NOTHING
+		INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume, repaired)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (instrument_id, interval, timestamp) DO NOTHING
 		RETURNING id`
-	err := db.conn.QueryRow(query, candle.InstrumentID, candle.Timestamp.UnixMilli(), 
-		candle.Open, candle.High, candle.Low, candle.Close, candle.Volume).Scan(&candle.ID)
+	err := db.conn.QueryRow(query, candle.InstrumentID, candle.Interval, candle.Timestamp,
+		candle.Open, candle.High, candle.Low, candle.Close, candle.Volume, candle.Repaired).Scan(&candle.ID)
 	if err == sql.ErrNoRows {
 		return nil // Duplicate, ignore
 	}
 	return err
 }
 
-// GetCandlesByTimeRange retrieves candles for an instrument within a time range
-func (db *DB) GetCandlesByTimeRange(instrumentID int64, startTime, endTime time.Time) ([]*models.Candle, error) {
+// SaveCandles saves a batch of candles in one transaction and returns how many were new
+// Candles already stored for the same bar are kept, like SaveCandle; IDs are not set
+// PostgreSQL streams the batch with COPY into a staging table and merges it with one INSERT
+func (db *DB) SaveCandles(candles []*models.Candle) (int, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var inserted int64
+	if db.dialect == postgresDialect {
+		inserted, err = copyCandles(tx, candles)
+	} else {
+		inserted, err = insertCandles(tx, candles)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(inserted), nil
+}
+
+// copyCandles loads candles with COPY and merges them into the candles table
+func copyCandles(tx *sql.Tx, candles []*models.Candle) (int64, error) {
+	_, err := tx.Exec(`
+		CREATE TEMP TABLE IF NOT EXISTS candle_staging (
+			instrument_id INTEGER NOT NULL,
+			interval VARCHAR(10) NOT NULL,
+			timestamp BIGINT NOT NULL,
+			open NUMERIC(20, 8) NOT NULL,
+			high NUMERIC(20, 8) NOT NULL,
+			low NUMERIC(20, 8) NOT NULL,
+			close NUMERIC(20, 8) NOT NULL,
+			volume NUMERIC(20, 8) NOT NULL,
+			repaired VARCHAR(16) NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("candle_staging",
+		"instrument_id", "interval", "timestamp", "open", "high", "low", "close", "volume", "repaired"))
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range candles {
+		if _, err := stmt.Exec(c.InstrumentID, c.Interval, c.Timestamp, c.Open, c.High, c.Low, c.Close, c.Volume, c.Repaired); err != nil {
+			stmt.Close()
+			return 0, err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume, repaired)
+		SELECT instrument_id, interval, timestamp, open, high, low, close, volume, repaired FROM candle_staging
+		ON CONFLICT (instrument_id, interval, timestamp) DO NOTHING`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// insertRowsPerStatement is the number of candles per multi-row INSERT
+// The SQLite driver binds arguments in quadratic time, so larger statements get slower
+const insertRowsPerStatement = 50
+
+// insertCandles inserts candles with multi-row INSERT statements (SQLite)
+func insertCandles(tx *sql.Tx, candles []*models.Candle) (int64, error) {
+	var inserted int64
+	var stmt *sql.Stmt
+	stmtRows := 0
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+
+	for start := 0; start < len(candles); start += insertRowsPerStatement {
+		chunk := candles[start:min(start+insertRowsPerStatement, len(candles))]
+
+		// Reuse the prepared statement for every full chunk
+		if len(chunk) != stmtRows {
+			if stmt != nil {
+				stmt.Close()
+			}
+			var err error
+			if stmt, err = tx.Prepare(candleInsertQuery(len(chunk))); err != nil {
+				stmt = nil
+				return 0, err
+			}
+			stmtRows = len(chunk)
+		}
+
+		args := make([]interface{}, 0, len(chunk)*9)
+		for _, c := range chunk {
+			args = append(args, c.InstrumentID, c.Interval, c.Timestamp, c.Open, c.High, c.Low, c.Close, c.Volume, c.Repaired)
+		}
+		res, err := stmt.Exec(args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += n
+	}
+	return inserted, nil
+}
+
+// candleInsertQuery builds an INSERT of rows candles that skips existing bars
+// Anonymous ? placeholders keep binding linear; $N parameters are resolved by name
+func candleInsertQuery(rows int) string {
+	var b strings.Builder
+	b.WriteString(`INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume, repaired) VALUES `)
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	}
+	b.WriteString(` ON CONFLICT (instrument_id, interval, timestamp) DO NOTHING`)
+	return b.String()
+}
+
+// GetCandlesByTimeRange retrieves candles for an instrument and interval within a time range
+func (db *DB) GetCandlesByTimeRange(instrumentID int64, interval string, startTime, endTime time.Time) ([]*models.Candle, error) {
 	query := `
-		SELECT id, instrument_id, timestamp, open, high, low, close, volume
+		SELECT id, instrument_id, interval, timestamp, open, high, low, close, volume, repaired
 		FROM candles
-		WHERE instrument_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		WHERE instrument_id = $1 AND interval = $2 AND timestamp >= $3 AND timestamp <= $4
 		ORDER BY timestamp ASC`
 	
-	rows, err := db.conn.Query(query, instrumentID, startTime.UnixMilli(), endTime.UnixMilli())
+	rows, err := db.conn.Query(query, instrumentID, interval, startTime.UnixMilli(), endTime.UnixMilli())
 	if err != nil {
 		return nil, err
 	}
@@ -180,39 +323,88 @@ func (db *DB) GetCandlesByTimeRange(instrumentID int64, startTime, endTime time.
 	var candles []*models.Candle
 	for rows.Next() {
 		candle := &models.Candle{}
-		var timestamp int64
-		if err := rows.Scan(&candle.ID, &candle.InstrumentID, &timestamp, 
-			&candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Volume); err != nil {
+		if err := rows.Scan(&candle.ID, &candle.InstrumentID, &candle.Interval, &candle.Timestamp,
+			&candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Volume, &candle.Repaired); err != nil {
 			return nil, err
 		}
-		candle.Timestamp = time.UnixMilli(timestamp)
 		candles = append(candles, candle)
 	}
-	return candles, nil
+	return candles, rows.Err()
 }
 
-// SaveStrategy saves a strategy to database
-func (db *DB) SaveStrategy(strategy *models.Strategy) error {
-	configJSON, err := json.Marshal(strategy.Config)
+// GetCandleSeries lists the stored intervals of an instrument with their time range and size
+func (db *DB) GetCandleSeries(instrumentID int64) ([]*models.CandleSeries, error) {
+	query := `
+		SELECT interval, MIN(timestamp), MAX(timestamp), COUNT(*)
+		FROM candles WHERE instrument_id = $1
+		GROUP BY interval ORDER BY interval`
+
+	rows, err := db.conn.Query(query, instrumentID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
+
+	var series []*models.CandleSeries
+	for rows.Next() {
+		s := &models.CandleSeries{InstrumentID: instrumentID}
+		if err := rows.Scan(&s.Interval, &s.First, &s.Last, &s.Count); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
 
+// SaveFundingRate saves a funding event to database
+func (db *DB) SaveFundingRate(rate *models.FundingRate) error {
 	query := `
-		INSERT INTO strategies (name, config) 
-		VALUES ($1, $2) 
-		ON CONFLICT (name) DO UPDATE SET config = EXCLUDED.config, updated_at = CURRENT_TIMESTAMP
+		INSERT INTO funding_rates (instrument_id, timestamp, rate, mark_price)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (instrument_id, timestamp) DO NOTHING
 		RETURNING id`
-	return db.conn.QueryRow(query, strategy.Name, configJSON).Scan(&strategy.ID)
+	err := db.conn.QueryRow(query, rate.InstrumentID, rate.Timestamp, rate.Rate, rate.MarkPrice).Scan(&rate.ID)
+	if err == sql.ErrNoRows {
+		return nil // Duplicate, ignore
+	}
+	return err
 }
 
-// GetStrategyByName retrieves a strategy by name
-func (db *DB) GetStrategyByName(name string) (*models.Strategy, error) {
-	query := `SELECT id, name, config FROM strategies WHERE name = $1`
+// GetFundingRates retrieves funding events for an instrument within a time range
+func (db *DB) GetFundingRates(instrumentID int64, startTime, endTime time.Time) ([]*models.FundingRate, error) {
+	query := `
+		SELECT id, instrument_id, timestamp, rate, mark_price
+		FROM funding_rates
+		WHERE instrument_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp ASC`
+
+	rows, err := db.conn.Query(query, instrumentID, startTime.UnixMilli(), endTime.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []*models.FundingRate
+	for rows.Next() {
+		rate := &models.FundingRate{}
+		if err := rows.Scan(&rate.ID, &rate.InstrumentID, &rate.Timestamp, &rate.Rate, &rate.MarkPrice); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// strategyColumns lists the strategy columns read by scanStrategy
+func (db *DB) strategyColumns() string {
+	return `id, name, config, ` + db.dialect.epochOf("created_at") + `, ` + db.dialect.epochOf("updated_at") + `, version`
+}
+
+// scanStrategy reads a row of strategyColumns
+func scanStrategy(row rowScanner) (*models.Strategy, error) {
 	strategy := &models.Strategy{}
 	var configJSON []byte
-	err := db.conn.QueryRow(query, name).Scan(&strategy.ID, &strategy.Name, &configJSON)
-	if err != nil {
+	if err := row.Scan(&strategy.ID, &strategy.Name, &configJSON, &strategy.CreatedAt, &strategy.UpdatedAt, &strategy.Version); err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal(configJSON, &strategy.Config); err != nil {
@@ -221,32 +413,669 @@ func (db *DB) GetStrategyByName(name string) (*models.Strategy, error) {
 	return strategy, nil
 }
 
-// SaveBacktestResult saves backtest result to database
+// SaveStrategy saves a strategy to database
+// A new config of an existing strategy is added as its next version
+func (db *DB) SaveStrategy(strategy *models.Strategy) error {
+	configJSON, err := json.Marshal(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A new strategy gets version 1 from saveStrategyConfig
+	err = tx.QueryRow(`SELECT id FROM strategies WHERE name = $1`, strategy.Name).Scan(&strategy.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.QueryRow(`INSERT INTO strategies (name, config, version) VALUES ($1, $2, 0) RETURNING id`,
+			strategy.Name, configJSON).Scan(&strategy.ID)
+	}
+	if err != nil {
+		return conflict(err)
+	}
+
+	if err := db.saveStrategyConfig(tx, strategy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateStrategy saves a new strategy with its config as version 1, returning ErrConflict if
+// the name is taken
+func (db *DB) CreateStrategy(strategy *models.Strategy) error {
+	configJSON, err := json.Marshal(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO strategies (name, config, version) VALUES ($1, $2, 0) RETURNING id`,
+		strategy.Name, configJSON).Scan(&strategy.ID)
+	if err != nil {
+		return conflict(err)
+	}
+
+	if err := db.saveStrategyConfig(tx, strategy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateStrategy renames the strategy with strategy.ID and adds its config as the next version
+// if it changed
+func (db *DB) UpdateStrategy(strategy *models.Strategy) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE strategies SET name = $1 WHERE id = $2`, strategy.Name, strategy.ID)
+	if err != nil {
+		return conflict(err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := db.saveStrategyConfig(tx, strategy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveStrategyConfig adds the config of a stored strategy as its next version unless it equals
+// the latest one, and reads back the version and timestamps
+func (db *DB) saveStrategyConfig(tx *sql.Tx, strategy *models.Strategy) error {
+	configJSON, err := json.Marshal(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	var latest int
+	var latestJSON []byte
+	err = tx.QueryRow(`SELECT version, config FROM strategy_versions WHERE strategy_id = $1 ORDER BY version DESC LIMIT 1`,
+		strategy.ID).Scan(&latest, &latestJSON)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	changed := latest == 0
+	if !changed {
+		var latestConfig models.StrategyConfig
+		if err := json.Unmarshal(latestJSON, &latestConfig); err != nil {
+			return err
+		}
+		changed = !sameConfig(latestConfig, strategy.Config)
+	}
+	if changed {
+		_, err := tx.Exec(`INSERT INTO strategy_versions (strategy_id, version, config) VALUES ($1, $2, $3)`,
+			strategy.ID, latest+1, configJSON)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE strategies SET config = $1, version = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`,
+			configJSON, latest+1, strategy.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `SELECT version, ` + db.dialect.epochOf("created_at") + `, ` + db.dialect.epochOf("updated_at") +
+		` FROM strategies WHERE id = $1`
+	return tx.QueryRow(query, strategy.ID).Scan(&strategy.Version, &strategy.CreatedAt, &strategy.UpdatedAt)
+}
+
+// GetStrategyVersions retrieves all config versions of a strategy, oldest first
+func (db *DB) GetStrategyVersions(strategyID int64) ([]*models.StrategyVersion, error) {
+	query := `SELECT ` + db.strategyVersionColumns() + ` FROM strategy_versions WHERE strategy_id = $1 ORDER BY version`
+	rows, err := db.conn.Query(query, strategyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.StrategyVersion
+	for rows.Next() {
+		version, err := scanStrategyVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetStrategyVersion retrieves a config version of a strategy
+func (db *DB) GetStrategyVersion(strategyID int64, version int) (*models.StrategyVersion, error) {
+	query := `SELECT ` + db.strategyVersionColumns() + ` FROM strategy_versions WHERE strategy_id = $1 AND version = $2`
+	return scanStrategyVersion(db.conn.QueryRow(query, strategyID, version))
+}
+
+// strategyVersionColumns lists the strategy version columns read by scanStrategyVersion
+func (db *DB) strategyVersionColumns() string {
+	return `id, strategy_id, version, config, ` + db.dialect.epochOf("created_at")
+}
+
+// scanStrategyVersion reads a row of strategyVersionColumns
+func scanStrategyVersion(row rowScanner) (*models.StrategyVersion, error) {
+	version := &models.StrategyVersion{}
+	var configJSON []byte
+	if err := row.Scan(&version.ID, &version.StrategyID, &version.Version, &configJSON, &version.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(configJSON, &version.Config); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetStrategyByName retrieves a strategy by name
+func (db *DB) GetStrategyByName(name string) (*models.Strategy, error) {
+	query := `SELECT ` + db.strategyColumns() + ` FROM strategies WHERE name = $1`
+	return scanStrategy(db.conn.QueryRow(query, name))
+}
+
+// GetStrategyByID retrieves a strategy by ID
+func (db *DB) GetStrategyByID(id int64) (*models.Strategy, error) {
+	query := `SELECT ` + db.strategyColumns() + ` FROM strategies WHERE id = $1`
+	return scanStrategy(db.conn.QueryRow(query, id))
+}
+
+// GetAllStrategies retrieves all strategies ordered by name
+func (db *DB) GetAllStrategies() ([]*models.Strategy, error) {
+	query := `SELECT ` + db.strategyColumns() + ` FROM strategies ORDER BY name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var strategies []*models.Strategy
+	for rows.Next() {
+		strategy, err := scanStrategy(rows)
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, strategy)
+	}
+	return strategies, rows.Err()
+}
+
+// DeleteStrategy deletes a strategy by ID
+// Strategies referenced by backtest results cannot be deleted
+func (db *DB) DeleteStrategy(id int64) error {
+	res, err := db.conn.Exec(`DELETE FROM strategies WHERE id = $1`, id)
+	if err != nil {
+		return conflict(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// conflict wraps unique and foreign key violations of either backend in ErrConflict
+func conflict(err error) error {
+	var pqErr *pq.Error
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &pqErr) && (pqErr.Code == "23505" || pqErr.Code == "23503") ||
+		errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintForeignKey) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+// queryer is a *sql.DB or *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SaveBacktestResult saves a backtest result to database, without trades or equity
 func (db *DB) SaveBacktestResult(result *models.BacktestResult) error {
+	return saveBacktestResult(db.conn, result)
+}
+
+// SaveBacktestRun saves a backtest result with its trades and equity curve in one transaction,
+// so a listed run is never missing either
+func (db *DB) SaveBacktestRun(result *models.BacktestResult) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveBacktestResult(tx, result); err != nil {
+		return err
+	}
+	for _, trade := range result.Trades {
+		if err := saveTrade(tx, result.ID, trade); err != nil {
+			return err
+		}
+	}
+	if err := db.saveEquity(tx, result.ID, result.Equity); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveBacktestResult inserts result and sets its ID
+func saveBacktestResult(q queryer, result *models.BacktestResult) error {
 	metricsJSON, err := json.Marshal(result.Metrics)
 	if err != nil {
 		return err
 	}
+	var configJSON []byte
+	if result.Config != nil {
+		if configJSON, err = json.Marshal(result.Config); err != nil {
+			return err
+		}
+	}
+	if result.Status == "" {
+		result.Status = models.BacktestDone
+	}
+	var strategyVersion sql.NullInt64
+	if result.StrategyVersion > 0 {
+		strategyVersion = sql.NullInt64{Int64: int64(result.StrategyVersion), Valid: true}
+	}
 
 	query := `
 		INSERT INTO backtest_results 
 		(instrument_id, strategy_id, start_time, end_time, total_trades, winning_trades, losing_trades, 
-		win_rate, total_pnl, total_return, metrics)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		win_rate, total_pnl, total_return, metrics, interval, config, status, error,
+		strategy_version, quality_policy, repair_policy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id`
 	
-	return db.conn.QueryRow(query, result.InstrumentID, result.StrategyID, result.StartTime, result.EndTime,
+	return q.QueryRow(query, result.InstrumentID, result.StrategyID, result.StartTime, result.EndTime,
 		result.TotalTrades, result.WinningTrades, result.LosingTrades, result.WinRate, 
-		result.TotalPnL, result.TotalReturn, metricsJSON).Scan(&result.ID)
+		result.TotalPnL, result.TotalReturn, metricsJSON, result.Interval, configJSON, result.Status,
+		result.Error, strategyVersion, result.QualityPolicy, result.RepairPolicy).Scan(&result.ID)
+}
+
+// backtestColumns lists the backtest result columns read by scanBacktestResult
+const backtestColumns = `id, instrument_id, strategy_id, start_time, end_time, total_trades, winning_trades, losing_trades,
+		win_rate, total_pnl, total_return, metrics, created_at, interval, config, status, error,
+		strategy_version, quality_policy, repair_policy`
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBacktestResult reads a row of backtestColumns
+func scanBacktestResult(row rowScanner) (*models.BacktestResult, error) {
+	result := &models.BacktestResult{}
+	var metricsJSON, configJSON []byte
+	var strategyVersion sql.NullInt64
+	err := row.Scan(&result.ID, &result.InstrumentID, &result.StrategyID,
+		&result.StartTime, &result.EndTime, &result.TotalTrades, &result.WinningTrades, &result.LosingTrades,
+		&result.WinRate, &result.TotalPnL, &result.TotalReturn, &metricsJSON, &result.CreatedAt,
+		&result.Interval, &configJSON, &result.Status, &result.Error,
+		&strategyVersion, &result.QualityPolicy, &result.RepairPolicy)
+	if err != nil {
+		return nil, err
+	}
+	result.StrategyVersion = int(strategyVersion.Int64)
+
+	result.Metrics = make(map[string]float64)
+	if len(metricsJSON) > 0 {
+		if err := json.Unmarshal(metricsJSON, &result.Metrics); err != nil {
+			return nil, err
+		}
+	}
+	if len(configJSON) > 0 {
+		result.Config = &models.StrategyConfig{}
+		if err := json.Unmarshal(configJSON, result.Config); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// GetBacktestResult retrieves a backtest result by ID, without trades or equity
+func (db *DB) GetBacktestResult(id int64) (*models.BacktestResult, error) {
+	query := `SELECT ` + backtestColumns + ` FROM backtest_results WHERE id = $1`
+	return scanBacktestResult(db.conn.QueryRow(query, id))
 }
 
-// SaveTrade saves a trade to database
-func (db *DB) SaveTrade(backtestID int64, trade *models.Trade) error {
+// ListBacktestResults retrieves the backtest results matching filter, without trades or equity
+func (db *DB) ListBacktestResults(filter *BacktestFilter) ([]*models.BacktestResult, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	if !ValidBacktestSort(sortBy) {
+		return nil, fmt.Errorf("invalid sort key %q", sortBy)
+	}
+
+	// Every sort key is compared as a number, so a cursor value matches the column it came from;
+	// times are whole seconds on both sides, as BacktestSortValue computes them
+	var sortExpr string
+	switch sortBy {
+	case "created_at", "start_time", "end_time":
+		sortExpr = db.dialect.epochOf(sortBy)
+	case "total_trades", "winning_trades", "losing_trades", "win_rate", "total_pnl", "total_return":
+		sortExpr = `CAST(` + sortBy + ` AS DOUBLE PRECISION)`
+	default:
+		sortExpr = db.dialect.jsonNumberOf("metrics", sortBy)
+	}
+
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.InstrumentID != 0 {
+		where = append(where, "instrument_id = "+arg(filter.InstrumentID))
+	}
+	if filter.StrategyID != 0 {
+		where = append(where, "strategy_id = "+arg(filter.StrategyID))
+	}
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(filter.Status))
+	}
+	if !filter.From.IsZero() {
+		where = append(where, db.dialect.epochOf("end_time")+" >= "+arg(filter.From.Unix()))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, db.dialect.epochOf("start_time")+" <= "+arg(filter.To.Unix()))
+	}
+
+	order, cmp := "ASC", ">"
+	if filter.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if filter.After != nil {
+		value, id := arg(filter.After.Value), arg(filter.After.ID)
+		where = append(where, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))", sortExpr, cmp, value, sortExpr, value, cmp, id))
+	}
+
+	query := `SELECT ` + backtestColumns + ` FROM backtest_results`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, id %s`, sortExpr, order, order)
+	if filter.Limit > 0 {
+		query += ` LIMIT ` + arg(filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.BacktestResult
+	for rows.Next() {
+		result, err := scanBacktestResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// saveTrade saves a trade and its exit fills
+func saveTrade(q queryer, backtestID int64, trade *models.Trade) error {
 	query := `
-		INSERT INTO trades (backtest_id, side, entry_price, entry_time, exit_price, exit_time, size, pnl)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO trades (backtest_id, side, entry_price, entry_time, exit_price, exit_time, exit_reason, size, fees, funding, pnl)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id`
 	
-	return db.conn.QueryRow(query, backtestID, trade.Side, trade.EntryPrice, trade.EntryTime,
-		trade.ExitPrice, trade.ExitTime, trade.Size, trade.PnL).Scan(&trade.ID)
+	err := q.QueryRow(query, backtestID, trade.Side, trade.EntryPrice, trade.EntryTime,
+		trade.ExitPrice, trade.ExitTime, trade.ExitReason, trade.Size, trade.Fees, trade.Funding, trade.PnL).Scan(&trade.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, fill := range trade.Fills {
+		fill.TradeID = trade.ID
+		if err := saveTradeFill(q, fill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveTradeFill saves a single exit fill of a trade
+func saveTradeFill(q queryer, fill *models.TradeFill) error {
+	query := `
+		INSERT INTO trade_fills (trade_id, fill_time, price, size, fee, pnl, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	return q.QueryRow(query, fill.TradeID, fill.Time, fill.Price, fill.Size,
+		fill.Fee, fill.PnL, fill.Reason).Scan(&fill.ID)
+}
+
+// GetTradesByBacktestID retrieves the trades of a backtest with their exit fills
+func (db *DB) GetTradesByBacktestID(backtestID int64) ([]*models.Trade, error) {
+	query := `
+		SELECT id, side, entry_price, entry_time, exit_price, exit_time, exit_reason, size, fees, funding, pnl
+		FROM trades WHERE backtest_id = $1
+		ORDER BY entry_time ASC, id ASC`
+
+	rows, err := db.conn.Query(query, backtestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []*models.Trade
+	byID := make(map[int64]*models.Trade)
+	for rows.Next() {
+		trade := &models.Trade{}
+		if err := rows.Scan(&trade.ID, &trade.Side, &trade.EntryPrice, &trade.EntryTime, &trade.ExitPrice,
+			&trade.ExitTime, &trade.ExitReason, &trade.Size, &trade.Fees, &trade.Funding, &trade.PnL); err != nil {
+			return nil, err
+		}
+		trades = append(trades, trade)
+		byID[trade.ID] = trade
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fillQuery := `
+		SELECT f.id, f.trade_id, f.fill_time, f.price, f.size, f.fee, f.pnl, f.reason
+		FROM trade_fills f JOIN trades t ON t.id = f.trade_id
+		WHERE t.backtest_id = $1
+		ORDER BY f.fill_time ASC, f.id ASC`
+
+	fillRows, err := db.conn.Query(fillQuery, backtestID)
+	if err != nil {
+		return nil, err
+	}
+	defer fillRows.Close()
+
+	for fillRows.Next() {
+		fill := &models.TradeFill{}
+		if err := fillRows.Scan(&fill.ID, &fill.TradeID, &fill.Time, &fill.Price, &fill.Size,
+			&fill.Fee, &fill.PnL, &fill.Reason); err != nil {
+			return nil, err
+		}
+		if trade, ok := byID[fill.TradeID]; ok {
+			trade.Fills = append(trade.Fills, fill)
+		}
+	}
+	return trades, fillRows.Err()
+}
+
+// equityRowsPerStatement is the number of equity points per multi-row INSERT, binding as
+// many arguments per statement as a candle INSERT
+const equityRowsPerStatement = insertRowsPerStatement * 3
+
+// saveEquity saves the equity curve of a new backtest run; IDs are not set
+// PostgreSQL loads the points with COPY, SQLite with multi-row INSERT statements
+func (db *DB) saveEquity(tx *sql.Tx, backtestID int64, points []*models.Equity) error {
+	for _, point := range points {
+		point.StrategyRunID = backtestID
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if db.dialect == postgresDialect {
+		stmt, err := tx.Prepare(pq.CopyIn("equity_points", "backtest_id", "ts", "equity"))
+		if err != nil {
+			return err
+		}
+		for _, point := range points {
+			if _, err := stmt.Exec(backtestID, point.TS, point.Equity); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return err
+		}
+		return stmt.Close()
+	}
+
+	var stmt *sql.Stmt
+	stmtRows := 0
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+
+	for start := 0; start < len(points); start += equityRowsPerStatement {
+		chunk := points[start:min(start+equityRowsPerStatement, len(points))]
+
+		// Reuse the prepared statement for every full chunk
+		if len(chunk) != stmtRows {
+			if stmt != nil {
+				stmt.Close()
+			}
+			var err error
+			if stmt, err = tx.Prepare(equityInsertQuery(len(chunk))); err != nil {
+				stmt = nil
+				return err
+			}
+			stmtRows = len(chunk)
+		}
+
+		args := make([]interface{}, 0, len(chunk)*3)
+		for _, point := range chunk {
+			args = append(args, backtestID, point.TS, point.Equity)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// equityInsertQuery builds an INSERT of rows equity points with anonymous ? placeholders
+func equityInsertQuery(rows int) string {
+	var b strings.Builder
+	b.WriteString(`INSERT INTO equity_points (backtest_id, ts, equity) VALUES `)
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(?, ?, ?)")
+	}
+	return b.String()
+}
+
+// GetEquityByBacktestID retrieves the equity curve of a backtest
+func (db *DB) GetEquityByBacktestID(backtestID int64) ([]*models.Equity, error) {
+	query := `SELECT id, backtest_id, ts, equity FROM equity_points WHERE backtest_id = $1 ORDER BY ts ASC`
+
+	rows, err := db.conn.Query(query, backtestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []*models.Equity
+	for rows.Next() {
+		point := &models.Equity{}
+		if err := rows.Scan(&point.ID, &point.StrategyRunID, &point.TS, &point.Equity); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// qualityDetails holds the listed gaps and issues of a quality report in one JSON column
+type qualityDetails struct {
+	Gaps   []models.CandleGap    `json:"gaps"`
+	Issues []models.QualityIssue `json:"issues"`
+}
+
+// SaveQualityReport saves a data quality report
+func (db *DB) SaveQualityReport(report *models.QualityReport) error {
+	countsJSON, err := json.Marshal(report.IssueCounts)
+	if err != nil {
+		return err
+	}
+	detailsJSON, err := json.Marshal(qualityDetails{Gaps: report.Gaps, Issues: report.Issues})
+	if err != nil {
+		return err
+	}
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO quality_reports
+		(instrument_id, interval, range_start, range_end, candles, missing_bars, clean, issue_counts, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`
+
+	return db.conn.QueryRow(query, report.InstrumentID, report.Interval, report.RangeStart, report.RangeEnd,
+		report.Candles, report.MissingBars, report.Clean, countsJSON, detailsJSON, report.CreatedAt).Scan(&report.ID)
+}
+
+// GetLatestQualityReport retrieves the most recent quality report of a candle series
+func (db *DB) GetLatestQualityReport(instrumentID int64, interval string) (*models.QualityReport, error) {
+	query := `
+		SELECT id, instrument_id, interval, range_start, range_end, candles, missing_bars, clean,
+		issue_counts, details, created_at
+		FROM quality_reports WHERE instrument_id = $1 AND interval = $2
+		ORDER BY created_at DESC, id DESC LIMIT 1`
+
+	report := &models.QualityReport{}
+	var countsJSON, detailsJSON []byte
+	err := db.conn.QueryRow(query, instrumentID, interval).Scan(&report.ID, &report.InstrumentID, &report.Interval,
+		&report.RangeStart, &report.RangeEnd, &report.Candles, &report.MissingBars, &report.Clean,
+		&countsJSON, &detailsJSON, &report.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(countsJSON, &report.IssueCounts); err != nil {
+		return nil, err
+	}
+	var details qualityDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return nil, err
+	}
+	report.Gaps, report.Issues = details.Gaps, details.Issues
+	return report, nil
 }
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	_ "github.com/glebarez/go-sqlite"
+	_ "github.com/lib/pq"
+)
+
+// dialect holds the SQL differences between supported backends
+// Queries use $N placeholders, which both PostgreSQL and SQLite accept
+type dialect struct {
+	name        string
+	driver      string
+	migrations  string        // Embedded migrations directory
+	epoch       string        // Format converting a TIMESTAMP column to Unix seconds, truncated as time.Time.Unix does
+	jsonNumber  string        // Format reading key of a JSON column as a number, 0 if missing
+	tableExists string        // Query reporting whether table $1 exists
+	legacy      *legacySchema // Conversion of legacy candle layouts in unversioned databases
+}
+
+var (
+	postgresDialect = &dialect{
+		name:        "postgres",
+		driver:      "postgres",
+		migrations:  "migrations/postgres",
+		epoch:       `COALESCE(FLOOR(EXTRACT(EPOCH FROM %s)), 0)::BIGINT`,
+		jsonNumber:  `COALESCE((%s->>'%s')::DOUBLE PRECISION, 0)`,
+		tableExists: `SELECT to_regclass($1) IS NOT NULL`,
+		legacy:      postgresLegacy,
+	}
+
+	sqliteDialect = &dialect{
+		name:        "sqlite",
+		driver:      "sqlite",
+		migrations:  "migrations/sqlite",
+		epoch:       `COALESCE(CAST(strftime('%%s', %s) AS INTEGER), 0)`,
+		jsonNumber:  `COALESCE(CAST(json_extract(%s, '$.%s') AS REAL), 0)`,
+		tableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`,
+		legacy:      sqliteLegacy,
+	}
+)
+
+// sqlitePragmas are applied to every SQLite connection
+// WAL and a busy timeout let the API read while a backtest or import is writing
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+// Extended SQLite result codes of constraint violations, reported with the pragmas above
+const (
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintUnique     = 2067
+)
+
+// parseConnString selects the backend by URL scheme and returns the driver DSN
+//   - sqlite://path/to/file.db, sqlite:file.db or file:file.db open a local SQLite file
+//   - anything else (postgres://... or key=value pairs) is passed to PostgreSQL
+func parseConnString(connString string) (*dialect, string, error) {
+	var path string
+	switch {
+	case strings.HasPrefix(connString, "sqlite://"):
+		path = strings.TrimPrefix(connString, "sqlite://")
+	case strings.HasPrefix(connString, "sqlite:"):
+		path = strings.TrimPrefix(connString, "sqlite:")
+	case strings.HasPrefix(connString, "file:"):
+		path = connString
+	case strings.HasPrefix(connString, "memory:"):
+		return nil, "", fmt.Errorf("the in-memory store has no SQL connection; use database.Open")
+	default:
+		return postgresDialect, connString, nil
+	}
+
+	if path == "" || strings.HasPrefix(path, "?") {
+		return nil, "", fmt.Errorf("sqlite connection string %q has no file path", connString)
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	for _, pragma := range sqlitePragmas {
+		path += separator + "_pragma=" + pragma
+		separator = "&"
+	}
+	return sqliteDialect, path, nil
+}
+
+// epochOf returns an expression converting column to Unix seconds
+func (d *dialect) epochOf(column string) string {
+	return fmt.Sprintf(d.epoch, column)
+}
+
+// jsonNumberOf returns an expression reading key of a JSON column as a number
+// key is inserted verbatim and must be validated by the caller
+func (d *dialect) jsonNumberOf(column, key string) string {
+	return fmt.Sprintf(d.jsonNumber, column, key)
+}
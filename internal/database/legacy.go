@@ -0,0 +1,350 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Legacy candle layouts that predate the unified candle store:
+//   - symbol layout: candles keyed by (symbol, interval, timestamp) in seconds,
+//     written by the former PostgresDB/CSVUploader pair
+//   - instrument layout: candles keyed by (instrument_id, timestamp) in milliseconds,
+//     with the interval stored as instruments.timeframe
+//
+// Both may sit next to the baseline instruments table, which has a timeframe column and is
+// unique on (symbol, timeframe, exchange)
+const (
+	legacyNone       = ""
+	legacySymbol     = "symbol"
+	legacyInstrument = "instrument"
+)
+
+// legacyExchange is assigned to instruments created from the symbol layout
+const legacyExchange = "BINANCE"
+
+// legacySchema holds the backend-specific statements of the legacy conversion
+type legacySchema struct {
+	columnExists string   // Query reporting whether table $1 has column $2
+	setAside     []string // Run after candles is renamed to legacy_candles, freeing its index names
+	instruments  string   // Unified instruments table, created if missing
+	candles      string   // Unified candles table
+	unifyTable   []string // Drop instruments.timeframe and make instruments unique on (symbol, exchange)
+
+	// Foreign keys are turned off around the transaction and checked before it commits
+	keysOff, keysOn string
+	checkKeys       string // Query listing rows that break a foreign key
+}
+
+var (
+	postgresLegacy = &legacySchema{
+		columnExists: `
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+			)`,
+		setAside: []string{
+			`ALTER INDEX IF EXISTS candles_pkey RENAME TO legacy_candles_pkey`,
+			`ALTER INDEX IF EXISTS candles_symbol_interval_timestamp_key RENAME TO legacy_candles_symbol_interval_timestamp_key`,
+			`ALTER INDEX IF EXISTS candles_instrument_id_timestamp_key RENAME TO legacy_candles_instrument_id_timestamp_key`,
+			`ALTER SEQUENCE IF EXISTS candles_id_seq RENAME TO legacy_candles_id_seq`,
+		},
+		instruments: `CREATE TABLE IF NOT EXISTS instruments (
+			id SERIAL PRIMARY KEY,
+			symbol VARCHAR(50) NOT NULL,
+			exchange VARCHAR(50) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(symbol, exchange)
+		)`,
+		candles: `CREATE TABLE candles (
+			id SERIAL PRIMARY KEY,
+			instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+			interval VARCHAR(10) NOT NULL,
+			timestamp BIGINT NOT NULL,
+			open NUMERIC(20, 8) NOT NULL,
+			high NUMERIC(20, 8) NOT NULL,
+			low NUMERIC(20, 8) NOT NULL,
+			close NUMERIC(20, 8) NOT NULL,
+			volume NUMERIC(20, 8) NOT NULL,
+			UNIQUE(instrument_id, interval, timestamp)
+		)`,
+		// Dropping the column also drops the (symbol, timeframe, exchange) constraint
+		unifyTable: []string{
+			`ALTER TABLE instruments DROP COLUMN timeframe`,
+			`ALTER TABLE instruments ADD CONSTRAINT instruments_symbol_exchange_key UNIQUE (symbol, exchange)`,
+		},
+	}
+
+	sqliteLegacy = &legacySchema{
+		columnExists: `SELECT EXISTS (SELECT 1 FROM pragma_table_info($1) WHERE name = $2)`,
+		instruments: `CREATE TABLE IF NOT EXISTS instruments (
+			id INTEGER PRIMARY KEY,
+			symbol TEXT NOT NULL,
+			exchange TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(symbol, exchange)
+		)`,
+		candles: `CREATE TABLE candles (
+			id INTEGER PRIMARY KEY,
+			instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+			interval TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			open REAL NOT NULL,
+			high REAL NOT NULL,
+			low REAL NOT NULL,
+			close REAL NOT NULL,
+			volume REAL NOT NULL,
+			UNIQUE(instrument_id, interval, timestamp)
+		)`,
+		// SQLite cannot drop a column that is part of a constraint, so the table is rebuilt
+		// with foreign keys off, as dropping instruments would otherwise fail on its references
+		unifyTable: []string{
+			`CREATE TABLE unified_instruments (
+				id INTEGER PRIMARY KEY,
+				symbol TEXT NOT NULL,
+				exchange TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(symbol, exchange)
+			)`,
+			`INSERT INTO unified_instruments (id, symbol, exchange, created_at)
+				SELECT id, symbol, exchange, created_at FROM instruments`,
+			`DROP TABLE instruments`,
+			`ALTER TABLE unified_instruments RENAME TO instruments`,
+		},
+		keysOff:   `PRAGMA foreign_keys = OFF`,
+		keysOn:    `PRAGMA foreign_keys = ON`,
+		checkKeys: `PRAGMA foreign_key_check`,
+	}
+)
+
+// migrateLegacyCandles converts candles stored in either legacy layout to the
+// unified (instrument_id, interval, timestamp in ms) layout
+// It runs in a single transaction before the first versioned migration and is
+// a no-op on new databases
+func migrateLegacyCandles(db *sql.DB, d *dialect) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Foreign key enforcement cannot change inside a transaction
+	if d.legacy.keysOff != "" {
+		if _, err := conn.ExecContext(ctx, d.legacy.keysOff); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, d.legacy.keysOn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	l := &legacyMigration{tx: tx, dialect: d, schema: d.legacy}
+	layout, err := l.detect()
+	if err != nil {
+		return err
+	}
+
+	switch layout {
+	case legacySymbol:
+		err = l.migrateSymbolLayout()
+	case legacyInstrument:
+		err = l.migrateInstrumentLayout()
+	default:
+		return nil
+	}
+	if err == nil {
+		err = l.checkKeys()
+	}
+	if err != nil {
+		return fmt.Errorf("%s layout: %w", layout, err)
+	}
+
+	return tx.Commit()
+}
+
+// legacyMigration converts a legacy layout within a transaction
+type legacyMigration struct {
+	tx      *sql.Tx
+	dialect *dialect
+	schema  *legacySchema
+}
+
+// hasColumn reports whether table exists and has column
+func (l *legacyMigration) hasColumn(table, column string) (bool, error) {
+	var exists bool
+	err := l.tx.QueryRow(l.schema.columnExists, table, column).Scan(&exists)
+	return exists, err
+}
+
+// hasTable reports whether table exists
+func (l *legacyMigration) hasTable(table string) (bool, error) {
+	var exists bool
+	err := l.tx.QueryRow(l.dialect.tableExists, table).Scan(&exists)
+	return exists, err
+}
+
+// checkKeys fails if the converted tables break a foreign key while enforcement is off
+func (l *legacyMigration) checkKeys() error {
+	if l.schema.checkKeys == "" {
+		return nil
+	}
+	rows, err := l.tx.Query(l.schema.checkKeys)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table string
+		var rowID, parent, key sql.NullString
+		if err := rows.Scan(&table, &rowID, &parent, &key); err != nil {
+			return err
+		}
+		return fmt.Errorf("row %s of %s references a missing %s", rowID.String, table, parent.String)
+	}
+	return rows.Err()
+}
+
+// detect inspects the candles and instruments columns
+func (l *legacyMigration) detect() (string, error) {
+	symbol, err := l.hasColumn("candles", "symbol")
+	if err != nil {
+		return legacyNone, err
+	}
+	if symbol {
+		return legacySymbol, nil
+	}
+
+	timeframe, err := l.hasColumn("instruments", "timeframe")
+	if err != nil {
+		return legacyNone, err
+	}
+	if timeframe {
+		return legacyInstrument, nil
+	}
+
+	return legacyNone, nil
+}
+
+// setAsideCandles renames candles to legacy_candles and creates the unified candles table
+func (l *legacyMigration) setAsideCandles() error {
+	statements := []string{`ALTER TABLE candles RENAME TO legacy_candles`}
+	statements = append(statements, l.schema.setAside...)
+	statements = append(statements,
+		`DROP INDEX IF EXISTS idx_candles_symbol_interval`,
+		`DROP INDEX IF EXISTS idx_candles_timestamp`,
+		`DROP INDEX IF EXISTS idx_candles_instrument_timestamp`,
+		l.schema.instruments,
+		l.schema.candles,
+	)
+	return execAll(l.tx, statements)
+}
+
+// migrateSymbolLayout moves symbol-keyed candles into the unified table,
+// creating an instrument per symbol and converting seconds to milliseconds
+// A baseline instruments table next to the candles is unified first
+func (l *legacyMigration) migrateSymbolLayout() error {
+	if err := l.setAsideCandles(); err != nil {
+		return err
+	}
+	if err := l.unifyInstruments(); err != nil {
+		return err
+	}
+
+	// WHERE true keeps SQLite from reading ON CONFLICT as part of the join
+	return execAll(l.tx, []string{
+		fmt.Sprintf(`
+			INSERT INTO instruments (symbol, exchange)
+			SELECT DISTINCT symbol, '%s' FROM legacy_candles WHERE true
+			ON CONFLICT (symbol, exchange) DO NOTHING`, legacyExchange),
+		fmt.Sprintf(`
+			INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume)
+			SELECT i.id, l.interval, l.timestamp * 1000, l.open, l.high, l.low, l.close, l.volume
+			FROM legacy_candles l
+			JOIN instruments i ON i.symbol = l.symbol AND i.exchange = '%s'
+			WHERE true
+			ON CONFLICT (instrument_id, interval, timestamp) DO NOTHING`, legacyExchange),
+		`DROP TABLE legacy_candles`,
+	})
+}
+
+// migrateInstrumentLayout moves instruments.timeframe onto candles.interval and
+// merges instruments that only differed by timeframe into one per symbol and exchange
+func (l *legacyMigration) migrateInstrumentLayout() error {
+	if err := l.setAsideCandles(); err != nil {
+		return err
+	}
+
+	// legacy_candles references the instruments about to be merged, so it goes first
+	err := execAll(l.tx, []string{
+		`INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume)
+			SELECT l.instrument_id, i.timeframe, l.timestamp, l.open, l.high, l.low, l.close, l.volume
+			FROM legacy_candles l
+			JOIN instruments i ON i.id = l.instrument_id`,
+		`DROP TABLE legacy_candles`,
+	})
+	if err != nil {
+		return err
+	}
+
+	return l.unifyInstruments()
+}
+
+// unifyInstruments converts a baseline instruments table with a timeframe column: instruments
+// that only differed by timeframe are merged into the one with the lowest id, and the table
+// becomes unique on (symbol, exchange)
+// It is a no-op if instruments has no timeframe column
+func (l *legacyMigration) unifyInstruments() error {
+	timeframe, err := l.hasColumn("instruments", "timeframe")
+	if err != nil || !timeframe {
+		return err
+	}
+
+	statements := []string{
+		// Map every instrument to the lowest id sharing its symbol and exchange
+		`CREATE TEMP TABLE instrument_merge AS
+			SELECT id, MIN(id) OVER (PARTITION BY symbol, exchange) AS keep_id FROM instruments`,
+		`DELETE FROM instrument_merge WHERE id = keep_id`,
+	}
+	for _, table := range []string{"candles", "backtest_results", "funding_rates"} {
+		exists, err := l.hasTable(table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if table == "funding_rates" {
+			// Keep the surviving instrument's rate where both have one for the same time
+			statements = append(statements, `
+				DELETE FROM funding_rates
+				WHERE instrument_id IN (SELECT id FROM instrument_merge) AND EXISTS (
+					SELECT 1 FROM funding_rates k JOIN instrument_merge m ON k.instrument_id = m.keep_id
+					WHERE m.id = funding_rates.instrument_id AND k.timestamp = funding_rates.timestamp
+				)`)
+		}
+		statements = append(statements, fmt.Sprintf(`
+			UPDATE %[1]s SET instrument_id = (SELECT keep_id FROM instrument_merge m WHERE m.id = %[1]s.instrument_id)
+			WHERE instrument_id IN (SELECT id FROM instrument_merge)`, table))
+	}
+	statements = append(statements, `DELETE FROM instruments WHERE id IN (SELECT id FROM instrument_merge)`)
+	statements = append(statements, l.schema.unifyTable...)
+	statements = append(statements, `DROP TABLE instrument_merge`)
+
+	return execAll(l.tx, statements)
+}
+
+// execAll executes statements in order, stopping at the first error
+func execAll(tx *sql.Tx, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
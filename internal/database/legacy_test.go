@@ -0,0 +1,237 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// Baseline tables created by the former DB.createTables, in SQLite types
+const (
+	baselineInstruments = `CREATE TABLE instruments (
+		id INTEGER PRIMARY KEY,
+		symbol VARCHAR(50) NOT NULL,
+		timeframe VARCHAR(10) NOT NULL,
+		exchange VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(symbol, timeframe, exchange)
+	)`
+	baselineStrategies = `CREATE TABLE strategies (
+		id INTEGER PRIMARY KEY,
+		name VARCHAR(100) NOT NULL UNIQUE,
+		config TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+	baselineResults = `CREATE TABLE backtest_results (
+		id INTEGER PRIMARY KEY,
+		instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+		strategy_id INTEGER NOT NULL REFERENCES strategies(id),
+		start_time TIMESTAMP NOT NULL,
+		end_time TIMESTAMP NOT NULL,
+		total_trades INTEGER NOT NULL,
+		winning_trades INTEGER NOT NULL,
+		losing_trades INTEGER NOT NULL,
+		win_rate NUMERIC(5, 2) NOT NULL,
+		total_pnl NUMERIC(20, 8) NOT NULL,
+		total_return NUMERIC(10, 4) NOT NULL,
+		metrics TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	// Instrument layout candles, timestamps in milliseconds
+	baselineCandles = `CREATE TABLE candles (
+		id INTEGER PRIMARY KEY,
+		instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+		timestamp BIGINT NOT NULL,
+		open NUMERIC(20, 8) NOT NULL,
+		high NUMERIC(20, 8) NOT NULL,
+		low NUMERIC(20, 8) NOT NULL,
+		close NUMERIC(20, 8) NOT NULL,
+		volume NUMERIC(20, 8) NOT NULL,
+		UNIQUE(instrument_id, timestamp)
+	)`
+
+	// Symbol layout candles of the former PostgresDB, timestamps in seconds
+	symbolCandles = `CREATE TABLE candles (
+		id INTEGER PRIMARY KEY,
+		symbol VARCHAR(20) NOT NULL,
+		interval VARCHAR(10) NOT NULL,
+		timestamp BIGINT NOT NULL,
+		open DECIMAL(20, 8) NOT NULL,
+		high DECIMAL(20, 8) NOT NULL,
+		low DECIMAL(20, 8) NOT NULL,
+		close DECIMAL(20, 8) NOT NULL,
+		volume DECIMAL(20, 8) NOT NULL,
+		UNIQUE(symbol, interval, timestamp)
+	)`
+)
+
+// seedInstruments holds BTCUSDT twice (1h and 4h) and ETHUSDT once, with a backtest on the
+// 4h BTCUSDT instrument
+var seedInstruments = []string{
+	baselineInstruments,
+	baselineStrategies,
+	baselineResults,
+	`INSERT INTO instruments (id, symbol, timeframe, exchange) VALUES
+		(1, 'BTCUSDT', '1h', 'BINANCE'), (2, 'BTCUSDT', '4h', 'BINANCE'), (3, 'ETHUSDT', '1h', 'BINANCE')`,
+	`INSERT INTO strategies (id, name, config) VALUES (1, 'cf', '{}')`,
+	`INSERT INTO backtest_results (instrument_id, strategy_id, start_time, end_time, total_trades,
+		winning_trades, losing_trades, win_rate, total_pnl, total_return)
+		VALUES (2, 1, '2023-11-14 00:00:00', '2023-11-15 00:00:00', 0, 0, 0, 0, 0, 0)`,
+}
+
+// TestMigrateLegacyCandles checks that Up converts unversioned databases in either legacy
+// layout, next to the baseline instruments table or not
+func TestMigrateLegacyCandles(t *testing.T) {
+	tests := []struct {
+		name        string
+		seed        []string
+		candles     []string // symbol exchange interval timestamp close, by symbol, interval and time
+		instruments []string // id symbol exchange
+		backtests   []int64  // Instrument of each backtest
+	}{
+		{
+			name: "symbol layout",
+			seed: []string{
+				symbolCandles,
+				`INSERT INTO candles (symbol, interval, timestamp, open, high, low, close, volume) VALUES
+					('BTCUSDT', '1h', 1700000000, 1, 2, 0.5, 1.5, 10),
+					('BTCUSDT', '1h', 1700003600, 1.5, 2, 1, 1.75, 10),
+					('ETHUSDT', '4h', 1700000000, 3, 4, 2, 3.5, 20)`,
+			},
+			candles: []string{
+				"BTCUSDT BINANCE 1h 1700000000000 1.5",
+				"BTCUSDT BINANCE 1h 1700003600000 1.75",
+				"ETHUSDT BINANCE 4h 1700000000000 3.5",
+			},
+			instruments: []string{"1 BTCUSDT BINANCE", "2 ETHUSDT BINANCE"},
+		},
+		{
+			name: "symbol layout next to baseline instruments",
+			seed: append(append([]string{}, seedInstruments...),
+				symbolCandles,
+				`INSERT INTO candles (symbol, interval, timestamp, open, high, low, close, volume) VALUES
+					('BTCUSDT', '1h', 1700000000, 1, 2, 0.5, 1.5, 10),
+					('BTCUSDT', '4h', 1700000000, 1, 3, 0.5, 2.5, 40),
+					('SOLUSDT', '1h', 1700003600, 5, 6, 4, 5.5, 30)`,
+			),
+			candles: []string{
+				"BTCUSDT BINANCE 1h 1700000000000 1.5",
+				"BTCUSDT BINANCE 4h 1700000000000 2.5",
+				"SOLUSDT BINANCE 1h 1700003600000 5.5",
+			},
+			instruments: []string{"1 BTCUSDT BINANCE", "3 ETHUSDT BINANCE", "4 SOLUSDT BINANCE"},
+			backtests:   []int64{1},
+		},
+		{
+			name: "instrument layout",
+			seed: append(append([]string{}, seedInstruments...),
+				baselineCandles,
+				`INSERT INTO candles (instrument_id, timestamp, open, high, low, close, volume) VALUES
+					(1, 1700000000000, 1, 2, 0.5, 1.5, 10),
+					(1, 1700003600000, 1.5, 2, 1, 1.75, 10),
+					(2, 1700000000000, 1, 3, 0.5, 2.5, 40),
+					(3, 1700000000000, 3, 4, 2, 3.5, 20)`,
+			),
+			candles: []string{
+				"BTCUSDT BINANCE 1h 1700000000000 1.5",
+				"BTCUSDT BINANCE 1h 1700003600000 1.75",
+				"BTCUSDT BINANCE 4h 1700000000000 2.5",
+				"ETHUSDT BINANCE 1h 1700000000000 3.5",
+			},
+			instruments: []string{"1 BTCUSDT BINANCE", "3 ETHUSDT BINANCE"},
+			backtests:   []int64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connString := "sqlite://" + filepath.Join(t.TempDir(), "legacy.db")
+			seedLegacy(t, connString, tt.seed)
+
+			db, err := InitDB(connString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			assertRows(t, db.conn, "candles", tt.candles, `
+				SELECT i.symbol, i.exchange, c.interval, c.timestamp, c.close
+				FROM candles c JOIN instruments i ON i.id = c.instrument_id
+				ORDER BY i.symbol, c.interval, c.timestamp`)
+			assertRows(t, db.conn, "instruments", tt.instruments, `SELECT id, symbol, exchange FROM instruments ORDER BY id`)
+
+			var backtests []string
+			for _, id := range tt.backtests {
+				backtests = append(backtests, fmt.Sprint(id))
+			}
+			assertRows(t, db.conn, "backtests", backtests, `SELECT instrument_id FROM backtest_results ORDER BY id`)
+
+			// The converted schema takes new candles and instruments
+			instrument, err := db.GetInstrumentBySymbol("BTCUSDT", "BINANCE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.conn.Exec(`INSERT INTO instruments (symbol, exchange) VALUES ('BTCUSDT', 'BINANCE')`); err == nil {
+				t.Error("instruments accepted a second BTCUSDT on BINANCE")
+			}
+			if _, err := db.conn.Exec(`INSERT INTO candles (instrument_id, interval, timestamp, open, high, low, close, volume)
+				VALUES ($1, '1d', 1700000000000, 1, 1, 1, 1, 1)`, instrument.ID); err != nil {
+				t.Errorf("failed to add a candle: %v", err)
+			}
+		})
+	}
+}
+
+// seedLegacy creates an unversioned database holding the seed tables and rows
+func seedLegacy(t *testing.T, connString string, seed []string) {
+	t.Helper()
+	db, err := Connect(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range seed {
+		if _, err := db.conn.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+}
+
+// assertRows compares the rows of query, with columns joined by spaces, to want
+func assertRows(t *testing.T, conn *sql.DB, what string, want []string, query string) {
+	t.Helper()
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			t.Fatal(err)
+		}
+		row := fmt.Sprint(values[0])
+		for _, value := range values[1:] {
+			row += " " + fmt.Sprint(value)
+		}
+		got = append(got, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s:\n got %q\nwant %q", what, got, want)
+	}
+}
@@ -0,0 +1,655 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// MemoryStore is an in-process Store for offline runs and tests
+// It follows the same uniqueness and duplicate rules as the PostgreSQL store
+// Records are copied on the way in and out, so callers never share state with the store
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	nextID map[string]int64
+
+	instruments []*models.Instrument
+	candles     map[seriesKey][]*models.Candle  // sorted by timestamp
+	funding     map[int64][]*models.FundingRate // sorted by timestamp
+	strategies  []*models.Strategy
+	versions    map[int64][]*models.StrategyVersion // by strategy ID, oldest first
+	results     map[int64]*models.BacktestResult
+	trades      map[int64][]*models.Trade
+	equity      map[int64][]*models.Equity
+	quality     map[seriesKey][]*models.QualityReport // in save order
+}
+
+// seriesKey identifies a candle series
+type seriesKey struct {
+	instrumentID int64
+	interval     string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		nextID:   make(map[string]int64),
+		candles:  make(map[seriesKey][]*models.Candle),
+		funding:  make(map[int64][]*models.FundingRate),
+		versions: make(map[int64][]*models.StrategyVersion),
+		results:  make(map[int64]*models.BacktestResult),
+		trades:   make(map[int64][]*models.Trade),
+		equity:   make(map[int64][]*models.Equity),
+		quality:  make(map[seriesKey][]*models.QualityReport),
+	}
+}
+
+// Close is a no-op; the data lives as long as the store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// id returns the next ID for table, starting at 1 like a SERIAL column
+func (m *MemoryStore) id(table string) int64 {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+// SaveInstrument saves an instrument, reusing an existing one with the same symbol and exchange
+// whose kind and volatility bucket are only replaced by non-empty values
+func (m *MemoryStore) SaveInstrument(inst *models.Instrument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.instruments {
+		if existing.Symbol == inst.Symbol && existing.Exchange == inst.Exchange {
+			if inst.Kind != "" {
+				existing.Kind = inst.Kind
+			}
+			if inst.VolBucket != "" {
+				existing.VolBucket = inst.VolBucket
+			}
+			inst.ID = existing.ID
+			inst.Kind = existing.Kind
+			inst.VolBucket = existing.VolBucket
+			inst.CreatedAt = existing.CreatedAt
+			return nil
+		}
+	}
+
+	inst.ID = int(m.id("instruments"))
+	inst.CreatedAt = time.Now().Unix()
+	stored := *inst
+	m.instruments = append(m.instruments, &stored)
+	return nil
+}
+
+// GetInstrumentByID retrieves an instrument by ID
+func (m *MemoryStore) GetInstrumentByID(id int64) (*models.Instrument, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, inst := range m.instruments {
+		if int64(inst.ID) == id {
+			found := *inst
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetInstrumentBySymbol retrieves an instrument by symbol and exchange
+func (m *MemoryStore) GetInstrumentBySymbol(symbol, exchange string) (*models.Instrument, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, inst := range m.instruments {
+		if inst.Symbol == symbol && inst.Exchange == exchange {
+			found := *inst
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetAllInstruments retrieves all instruments ordered by symbol
+func (m *MemoryStore) GetAllInstruments() ([]*models.Instrument, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	instruments := make([]*models.Instrument, 0, len(m.instruments))
+	for _, inst := range m.instruments {
+		found := *inst
+		instruments = append(instruments, &found)
+	}
+	sort.SliceStable(instruments, func(i, j int) bool { return instruments[i].Symbol < instruments[j].Symbol })
+	return instruments, nil
+}
+
+// SaveCandle saves a candle; a candle already stored for the same bar is kept
+func (m *MemoryStore) SaveCandle(candle *models.Candle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.insertCandle(candle)
+	return nil
+}
+
+// SaveCandles saves a batch of candles and returns how many were new
+func (m *MemoryStore) SaveCandles(candles []*models.Candle) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := 0
+	for _, candle := range candles {
+		if m.insertCandle(candle) {
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+// insertCandle adds a candle to its series, reporting false for a duplicate bar
+func (m *MemoryStore) insertCandle(candle *models.Candle) bool {
+	key := seriesKey{candle.InstrumentID, candle.Interval}
+	series := m.candles[key]
+
+	// Candles usually arrive in order, so check the tail before searching
+	i := len(series)
+	if i > 0 && series[i-1].Timestamp >= candle.Timestamp {
+		i = sort.Search(len(series), func(j int) bool { return series[j].Timestamp >= candle.Timestamp })
+		if i < len(series) && series[i].Timestamp == candle.Timestamp {
+			return false // Duplicate, ignore
+		}
+	}
+
+	candle.ID = m.id("candles")
+	stored := *candle
+	series = append(series, nil)
+	copy(series[i+1:], series[i:])
+	series[i] = &stored
+	m.candles[key] = series
+	return true
+}
+
+// GetCandlesByTimeRange retrieves candles for an instrument and interval within a time range
+func (m *MemoryStore) GetCandlesByTimeRange(instrumentID int64, interval string, startTime, endTime time.Time) ([]*models.Candle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	series := m.candles[seriesKey{instrumentID, interval}]
+	start, end := startTime.UnixMilli(), endTime.UnixMilli()
+	from := sort.Search(len(series), func(i int) bool { return series[i].Timestamp >= start })
+
+	var candles []*models.Candle
+	for _, candle := range series[from:] {
+		if candle.Timestamp > end {
+			break
+		}
+		found := *candle
+		candles = append(candles, &found)
+	}
+	return candles, nil
+}
+
+// GetCandleSeries lists the stored intervals of an instrument with their time range and size
+func (m *MemoryStore) GetCandleSeries(instrumentID int64) ([]*models.CandleSeries, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var series []*models.CandleSeries
+	for key, candles := range m.candles {
+		if key.instrumentID != instrumentID || len(candles) == 0 {
+			continue
+		}
+		series = append(series, &models.CandleSeries{
+			InstrumentID: instrumentID,
+			Interval:     key.interval,
+			First:        candles[0].Timestamp,
+			Last:         candles[len(candles)-1].Timestamp,
+			Count:        len(candles),
+		})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Interval < series[j].Interval })
+	return series, nil
+}
+
+// SaveFundingRate saves a funding event; an event already stored for the same time is kept
+func (m *MemoryStore) SaveFundingRate(rate *models.FundingRate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rates := m.funding[rate.InstrumentID]
+	i := sort.Search(len(rates), func(j int) bool { return rates[j].Timestamp >= rate.Timestamp })
+	if i < len(rates) && rates[i].Timestamp == rate.Timestamp {
+		return nil // Duplicate, ignore
+	}
+
+	rate.ID = m.id("funding_rates")
+	stored := *rate
+	rates = append(rates, nil)
+	copy(rates[i+1:], rates[i:])
+	rates[i] = &stored
+	m.funding[rate.InstrumentID] = rates
+	return nil
+}
+
+// GetFundingRates retrieves funding events for an instrument within a time range
+func (m *MemoryStore) GetFundingRates(instrumentID int64, startTime, endTime time.Time) ([]*models.FundingRate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rates []*models.FundingRate
+	for _, rate := range m.funding[instrumentID] {
+		if rate.Timestamp >= startTime.UnixMilli() && rate.Timestamp <= endTime.UnixMilli() {
+			found := *rate
+			rates = append(rates, &found)
+		}
+	}
+	return rates, nil
+}
+
+// SaveStrategy saves a strategy; a new config of an existing one is added as its next version
+func (m *MemoryStore) SaveStrategy(strategy *models.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.strategies {
+		if existing.Name == strategy.Name {
+			m.saveStrategyConfig(existing, strategy.Config)
+			*strategy = *existing
+			strategy.Config = copyConfig(existing.Config)
+			return nil
+		}
+	}
+
+	m.createStrategy(strategy)
+	return nil
+}
+
+// CreateStrategy saves a new strategy with its config as version 1, returning ErrConflict if
+// the name is taken
+func (m *MemoryStore) CreateStrategy(strategy *models.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.strategies {
+		if existing.Name == strategy.Name {
+			return fmt.Errorf("strategy %q already exists: %w", strategy.Name, ErrConflict)
+		}
+	}
+	m.createStrategy(strategy)
+	return nil
+}
+
+// createStrategy stores a copy of a new strategy and reads back its ID, version and timestamps
+func (m *MemoryStore) createStrategy(strategy *models.Strategy) {
+	stored := &models.Strategy{
+		ID:        int(m.id("strategies")),
+		Name:      strategy.Name,
+		CreatedAt: time.Now().Unix(),
+	}
+	m.saveStrategyConfig(stored, strategy.Config)
+	m.strategies = append(m.strategies, stored)
+	*strategy = *stored
+	strategy.Config = copyConfig(stored.Config)
+}
+
+// saveStrategyConfig adds config as the next version of a stored strategy unless it equals
+// the latest one
+func (m *MemoryStore) saveStrategyConfig(stored *models.Strategy, config models.StrategyConfig) {
+	key := int64(stored.ID)
+	versions := m.versions[key]
+	if len(versions) > 0 && sameConfig(versions[len(versions)-1].Config, config) {
+		return
+	}
+
+	version := &models.StrategyVersion{
+		ID:         m.id("strategy_versions"),
+		StrategyID: key,
+		Version:    len(versions) + 1,
+		Config:     copyConfig(config),
+		CreatedAt:  time.Now().Unix(),
+	}
+	m.versions[key] = append(versions, version)
+	stored.Config = copyConfig(config)
+	stored.Version = version.Version
+	stored.UpdatedAt = version.CreatedAt
+}
+
+// GetStrategyByName retrieves a strategy by name
+func (m *MemoryStore) GetStrategyByName(name string) (*models.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, strategy := range m.strategies {
+		if strategy.Name == name {
+			found := *strategy
+			found.Config = copyConfig(strategy.Config)
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetStrategyByID retrieves a strategy by ID
+func (m *MemoryStore) GetStrategyByID(id int64) (*models.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, strategy := range m.strategies {
+		if int64(strategy.ID) == id {
+			found := *strategy
+			found.Config = copyConfig(strategy.Config)
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetAllStrategies retrieves all strategies ordered by name
+func (m *MemoryStore) GetAllStrategies() ([]*models.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	strategies := make([]*models.Strategy, 0, len(m.strategies))
+	for _, strategy := range m.strategies {
+		found := *strategy
+		found.Config = copyConfig(strategy.Config)
+		strategies = append(strategies, &found)
+	}
+	sort.Slice(strategies, func(i, j int) bool { return strategies[i].Name < strategies[j].Name })
+	return strategies, nil
+}
+
+// UpdateStrategy renames the strategy with strategy.ID and adds its config as the next version
+// if it changed
+func (m *MemoryStore) UpdateStrategy(strategy *models.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var target *models.Strategy
+	for _, existing := range m.strategies {
+		if existing.ID == strategy.ID {
+			target = existing
+		} else if existing.Name == strategy.Name {
+			return fmt.Errorf("strategy %q already exists: %w", strategy.Name, ErrConflict)
+		}
+	}
+	if target == nil {
+		return sql.ErrNoRows
+	}
+
+	target.Name = strategy.Name
+	m.saveStrategyConfig(target, strategy.Config)
+	strategy.CreatedAt = target.CreatedAt
+	strategy.UpdatedAt = target.UpdatedAt
+	strategy.Version = target.Version
+	return nil
+}
+
+// DeleteStrategy deletes a strategy by ID
+// Strategies referenced by backtest results cannot be deleted
+func (m *MemoryStore) DeleteStrategy(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, strategy := range m.strategies {
+		if int64(strategy.ID) != id {
+			continue
+		}
+		for _, result := range m.results {
+			if result.StrategyID == id {
+				return fmt.Errorf("strategy %q is referenced by backtest %d: %w", strategy.Name, result.ID, ErrConflict)
+			}
+		}
+		m.strategies = append(m.strategies[:i], m.strategies[i+1:]...)
+		delete(m.versions, id)
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+// GetStrategyVersions retrieves all config versions of a strategy, oldest first
+func (m *MemoryStore) GetStrategyVersions(strategyID int64) ([]*models.StrategyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.versions[strategyID]
+	versions := make([]*models.StrategyVersion, 0, len(stored))
+	for _, version := range stored {
+		found := *version
+		found.Config = copyConfig(version.Config)
+		versions = append(versions, &found)
+	}
+	return versions, nil
+}
+
+// GetStrategyVersion retrieves a config version of a strategy
+func (m *MemoryStore) GetStrategyVersion(strategyID int64, version int) (*models.StrategyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.versions[strategyID]
+	if version < 1 || version > len(versions) {
+		return nil, sql.ErrNoRows
+	}
+	found := *versions[version-1]
+	found.Config = copyConfig(found.Config)
+	return &found, nil
+}
+
+// SaveBacktestResult saves a backtest result, without trades or equity
+func (m *MemoryStore) SaveBacktestResult(result *models.BacktestResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saveBacktestResult(result)
+	return nil
+}
+
+// SaveBacktestRun saves a backtest result with its trades and equity curve at once
+func (m *MemoryStore) SaveBacktestRun(result *models.BacktestResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saveBacktestResult(result)
+	for _, trade := range result.Trades {
+		trade.ID = m.id("trades")
+		for _, fill := range trade.Fills {
+			fill.ID = m.id("trade_fills")
+			fill.TradeID = trade.ID
+		}
+		m.trades[result.ID] = append(m.trades[result.ID], copyTrade(trade))
+	}
+
+	curve := make([]*models.Equity, 0, len(result.Equity))
+	for _, point := range result.Equity {
+		point.StrategyRunID = result.ID
+		stored := *point
+		stored.ID = m.id("equity_points")
+		curve = append(curve, &stored)
+	}
+	sort.SliceStable(curve, func(i, j int) bool { return curve[i].TS < curve[j].TS })
+	m.equity[result.ID] = curve
+	return nil
+}
+
+// saveBacktestResult stores result and sets its ID; m.mu must be held
+func (m *MemoryStore) saveBacktestResult(result *models.BacktestResult) {
+	result.ID = m.id("backtest_results")
+	if result.CreatedAt.IsZero() {
+		result.CreatedAt = time.Now()
+	}
+	if result.Status == "" {
+		result.Status = models.BacktestDone
+	}
+	m.results[result.ID] = copyResult(result)
+}
+
+// GetBacktestResult retrieves a backtest result by ID, without trades or equity
+func (m *MemoryStore) GetBacktestResult(id int64) (*models.BacktestResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result, ok := m.results[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyResult(result), nil
+}
+
+// ListBacktestResults retrieves the backtest results matching filter, without trades or equity
+func (m *MemoryStore) ListBacktestResults(filter *BacktestFilter) ([]*models.BacktestResult, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	if !ValidBacktestSort(sortBy) {
+		return nil, fmt.Errorf("invalid sort key %q", sortBy)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// before reports whether a sorts before b, comparing IDs when the sort values are equal
+	before := func(a float64, aID int64, b float64, bID int64) bool {
+		if filter.Desc {
+			return a > b || (a == b && aID > bID)
+		}
+		return a < b || (a == b && aID < bID)
+	}
+
+	var results []*models.BacktestResult
+	for _, result := range m.results {
+		switch {
+		case filter.InstrumentID != 0 && result.InstrumentID != filter.InstrumentID,
+			filter.StrategyID != 0 && result.StrategyID != filter.StrategyID,
+			filter.Status != "" && result.Status != filter.Status,
+			!filter.From.IsZero() && result.EndTime.Unix() < filter.From.Unix(),
+			!filter.To.IsZero() && result.StartTime.Unix() > filter.To.Unix():
+			continue
+		}
+		if filter.After != nil && !before(filter.After.Value, filter.After.ID, BacktestSortValue(result, sortBy), result.ID) {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return before(BacktestSortValue(results[i], sortBy), results[i].ID, BacktestSortValue(results[j], sortBy), results[j].ID)
+	})
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+	for i, result := range results {
+		results[i] = copyResult(result)
+	}
+	return results, nil
+}
+
+// GetTradesByBacktestID retrieves the trades of a backtest with their exit fills
+func (m *MemoryStore) GetTradesByBacktestID(backtestID int64) ([]*models.Trade, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.trades[backtestID]
+	trades := make([]*models.Trade, 0, len(stored))
+	for _, trade := range stored {
+		trades = append(trades, copyTrade(trade))
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].EntryTime.Before(trades[j].EntryTime) })
+	return trades, nil
+}
+
+// GetEquityByBacktestID retrieves the equity curve of a backtest
+func (m *MemoryStore) GetEquityByBacktestID(backtestID int64) ([]*models.Equity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.equity[backtestID]
+	points := make([]*models.Equity, 0, len(stored))
+	for _, point := range stored {
+		found := *point
+		points = append(points, &found)
+	}
+	return points, nil
+}
+
+// SaveQualityReport saves a data quality report
+func (m *MemoryStore) SaveQualityReport(report *models.QualityReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	report.ID = m.id("quality_reports")
+	key := seriesKey{report.InstrumentID, report.Interval}
+	m.quality[key] = append(m.quality[key], copyQualityReport(report))
+	return nil
+}
+
+// GetLatestQualityReport retrieves the most recent quality report of a candle series
+func (m *MemoryStore) GetLatestQualityReport(instrumentID int64, interval string) (*models.QualityReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reports := m.quality[seriesKey{instrumentID, interval}]
+	if len(reports) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return copyQualityReport(reports[len(reports)-1]), nil
+}
+
+// copyConfig copies a strategy config including its slices
+func copyConfig(config models.StrategyConfig) models.StrategyConfig {
+	config.TakeProfitTargets = append([]models.TakeProfitTarget(nil), config.TakeProfitTargets...)
+	return config
+}
+
+// copyResult copies the stored columns of a backtest result
+func copyResult(result *models.BacktestResult) *models.BacktestResult {
+	stored := *result
+	stored.Trades = nil
+	stored.Equity = nil
+	stored.Debug = nil
+	stored.Quality = nil
+	if result.Config != nil {
+		config := copyConfig(*result.Config)
+		stored.Config = &config
+	}
+	stored.Metrics = make(map[string]float64, len(result.Metrics))
+	for k, v := range result.Metrics {
+		stored.Metrics[k] = v
+	}
+	return &stored
+}
+
+// copyTrade copies a trade and its fills
+func copyTrade(trade *models.Trade) *models.Trade {
+	stored := *trade
+	stored.Fills = make([]*models.TradeFill, 0, len(trade.Fills))
+	for _, fill := range trade.Fills {
+		f := *fill
+		stored.Fills = append(stored.Fills, &f)
+	}
+	return &stored
+}
+
+// copyQualityReport copies a quality report with its counts, gaps and issues
+func copyQualityReport(report *models.QualityReport) *models.QualityReport {
+	stored := *report
+	stored.IssueCounts = make(map[string]int, len(report.IssueCounts))
+	for k, v := range report.IssueCounts {
+		stored.IssueCounts[k] = v
+	}
+	stored.Gaps = append([]models.CandleGap(nil), report.Gaps...)
+	stored.Issues = append([]models.QualityIssue(nil), report.Issues...)
+	return &stored
+}
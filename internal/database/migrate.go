@@ -0,0 +1,306 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var embeddedMigrations embed.FS
+
+// ErrSchemaOutOfDate is returned when the database schema does not match the binary
+var ErrSchemaOutOfDate = errors.New("database schema is out of date")
+
+// migrationFile matches NNNN_name.up.sql and NNNN_name.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applies and rolls back versioned migrations, recording them in schema_migrations
+type Migrator struct {
+	conn       *sql.DB
+	dialect    *dialect
+	migrations []Migration
+}
+
+// newMigrator creates a migrator for the embedded migrations of a backend
+func newMigrator(conn *sql.DB, d *dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(embeddedMigrations, d.migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, dialect: d, migrations: migrations}, nil
+}
+
+// loadMigrations reads up/down pairs from dir, ordered by version
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// LatestVersion returns the highest known migration version
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CurrentVersion returns the highest applied migration version (0 if none)
+func (m *Migrator) CurrentVersion() (int, error) {
+	exists, err := m.versionTableExists()
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := m.conn.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Check returns ErrSchemaOutOfDate unless every known migration has been applied
+// and the database has no migrations this binary does not know about
+func (m *Migrator) Check() error {
+	current, err := m.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if latest := m.LatestVersion(); current != latest {
+		return fmt.Errorf("%w: database is at version %d, expected %d (run `migrate up`)", ErrSchemaOutOfDate, current, latest)
+	}
+	return nil
+}
+
+// Up applies all pending migrations in order and returns the ones applied
+// Unversioned databases in a legacy candle layout are converted first
+func (m *Migrator) Up() ([]Migration, error) {
+	exists, err := m.versionTableExists()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if m.dialect.legacy != nil {
+			if err := migrateLegacyCandles(m.conn, m.dialect); err != nil {
+				return nil, fmt.Errorf("failed to migrate legacy candles: %w", err)
+			}
+		}
+		if err := m.createVersionTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := m.apply(migration, migration.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d_%s cannot be rolled back: no down script", migration.Version, migration.Name)
+		}
+		if err := m.apply(migration, migration.Down, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status lists all known migrations with their applied time
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	appliedAt := make(map[int]time.Time)
+
+	exists, err := m.versionTableExists()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := m.conn.Query(`SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			var at time.Time
+			if err := rows.Scan(&version, &at); err != nil {
+				return nil, err
+			}
+			appliedAt[version] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if at, ok := appliedAt[migration.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// apply runs a migration script and records the result in one transaction
+func (m *Migrator) apply(migration Migration, script string, up bool) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		direction := "down"
+		if up {
+			direction = "up"
+		}
+		return fmt.Errorf("migration %d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions returns the set of applied migration versions
+func (m *Migrator) appliedVersions() (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	exists, err := m.versionTableExists()
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := m.conn.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// versionTableExists reports whether schema_migrations has been created
+func (m *Migrator) versionTableExists() (bool, error) {
+	var exists bool
+	err := m.conn.QueryRow(m.dialect.tableExists, "schema_migrations").Scan(&exists)
+	return exists, err
+}
+
+// createVersionTable creates the schema_migrations table
+func (m *Migrator) createVersionTable() error {
+	_, err := m.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	return err
+}
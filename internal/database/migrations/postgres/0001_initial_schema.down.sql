@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS trades;
+DROP TABLE IF EXISTS backtest_results;
+DROP TABLE IF EXISTS strategies;
+DROP TABLE IF EXISTS candles;
+DROP TABLE IF EXISTS instruments;
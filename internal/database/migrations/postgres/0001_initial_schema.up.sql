@@ -0,0 +1,60 @@
+-- Core schema. IF NOT EXISTS lets databases created before versioned
+-- migrations adopt this version without changes.
+
+CREATE TABLE IF NOT EXISTS instruments (
+	id SERIAL PRIMARY KEY,
+	symbol VARCHAR(50) NOT NULL,
+	exchange VARCHAR(50) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(symbol, exchange)
+);
+
+-- Candle timestamps are bar open times in Unix milliseconds
+CREATE TABLE IF NOT EXISTS candles (
+	id SERIAL PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	interval VARCHAR(10) NOT NULL,
+	timestamp BIGINT NOT NULL,
+	open NUMERIC(20, 8) NOT NULL,
+	high NUMERIC(20, 8) NOT NULL,
+	low NUMERIC(20, 8) NOT NULL,
+	close NUMERIC(20, 8) NOT NULL,
+	volume NUMERIC(20, 8) NOT NULL,
+	UNIQUE(instrument_id, interval, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS strategies (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	config JSONB NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS backtest_results (
+	id SERIAL PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	strategy_id INTEGER NOT NULL REFERENCES strategies(id),
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP NOT NULL,
+	total_trades INTEGER NOT NULL,
+	winning_trades INTEGER NOT NULL,
+	losing_trades INTEGER NOT NULL,
+	win_rate NUMERIC(5, 2) NOT NULL,
+	total_pnl NUMERIC(20, 8) NOT NULL,
+	total_return NUMERIC(10, 4) NOT NULL,
+	metrics JSONB,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS trades (
+	id SERIAL PRIMARY KEY,
+	backtest_id INTEGER NOT NULL REFERENCES backtest_results(id),
+	side VARCHAR(10) NOT NULL,
+	entry_price NUMERIC(20, 8) NOT NULL,
+	entry_time TIMESTAMP NOT NULL,
+	exit_price NUMERIC(20, 8) NOT NULL,
+	exit_time TIMESTAMP NOT NULL,
+	size NUMERIC(20, 8) NOT NULL,
+	pnl NUMERIC(20, 8) NOT NULL
+);
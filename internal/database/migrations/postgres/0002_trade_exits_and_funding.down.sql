@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS funding_rates;
+DROP TABLE IF EXISTS trade_fills;
+
+ALTER TABLE trades DROP COLUMN IF EXISTS funding;
+ALTER TABLE trades DROP COLUMN IF EXISTS fees;
+ALTER TABLE trades DROP COLUMN IF EXISTS exit_reason;
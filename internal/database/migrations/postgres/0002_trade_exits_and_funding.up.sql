@@ -0,0 +1,25 @@
+-- Exit reasons, fees, scale-out fills and perpetual funding
+
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS exit_reason VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS fees NUMERIC(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS funding NUMERIC(20, 8) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS trade_fills (
+	id SERIAL PRIMARY KEY,
+	trade_id INTEGER NOT NULL REFERENCES trades(id) ON DELETE CASCADE,
+	fill_time TIMESTAMP NOT NULL,
+	price NUMERIC(20, 8) NOT NULL,
+	size NUMERIC(20, 8) NOT NULL,
+	fee NUMERIC(20, 8) NOT NULL,
+	pnl NUMERIC(20, 8) NOT NULL,
+	reason VARCHAR(30) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS funding_rates (
+	id SERIAL PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	timestamp BIGINT NOT NULL,
+	rate NUMERIC(20, 10) NOT NULL,
+	mark_price NUMERIC(20, 8) NOT NULL DEFAULT 0,
+	UNIQUE(instrument_id, timestamp)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE instruments DROP COLUMN IF EXISTS vol_bucket;
+ALTER TABLE instruments DROP COLUMN IF EXISTS kind;
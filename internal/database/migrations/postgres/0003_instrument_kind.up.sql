@@ -0,0 +1,4 @@
+-- Strategy kind (CFBASE or CFATRADX) and volatility bucket (low, mid, high)
+
+ALTER TABLE instruments ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE instruments ADD COLUMN IF NOT EXISTS vol_bucket VARCHAR(10) NOT NULL DEFAULT '';
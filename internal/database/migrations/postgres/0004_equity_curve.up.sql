@@ -0,0 +1,9 @@
+-- Per-bar mark-to-market equity of a backtest run
+
+CREATE TABLE IF NOT EXISTS equity_points (
+	id SERIAL PRIMARY KEY,
+	backtest_id INTEGER NOT NULL REFERENCES backtest_results(id) ON DELETE CASCADE,
+	ts BIGINT NOT NULL,
+	equity NUMERIC(20, 8) NOT NULL,
+	UNIQUE(backtest_id, ts)
+);
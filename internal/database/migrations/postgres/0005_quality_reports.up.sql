@@ -0,0 +1,17 @@
+-- Data quality checks of candle series; the latest report per series is the current one
+
+CREATE TABLE IF NOT EXISTS quality_reports (
+	id SERIAL PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id) ON DELETE CASCADE,
+	interval VARCHAR(10) NOT NULL,
+	range_start BIGINT NOT NULL,
+	range_end BIGINT NOT NULL,
+	candles INTEGER NOT NULL,
+	missing_bars INTEGER NOT NULL,
+	clean BOOLEAN NOT NULL,
+	issue_counts JSONB NOT NULL,
+	details JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quality_reports_series ON quality_reports(instrument_id, interval, created_at);
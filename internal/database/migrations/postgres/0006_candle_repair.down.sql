@@ -0,0 +1 @@
+ALTER TABLE candles DROP COLUMN IF EXISTS repaired;
@@ -0,0 +1,3 @@
+-- Flag synthetic candles created by gap repair (empty for market data)
+
+ALTER TABLE candles ADD COLUMN IF NOT EXISTS repaired VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_trades_backtest;
+DROP INDEX IF EXISTS idx_backtest_results_strategy;
+DROP INDEX IF EXISTS idx_backtest_results_instrument;
+
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS config;
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS error;
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS status;
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS interval;
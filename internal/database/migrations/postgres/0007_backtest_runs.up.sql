@@ -0,0 +1,10 @@
+-- Run status, interval and strategy config snapshot of backtests, for listing and comparing runs
+
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS interval VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'DONE';
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS error TEXT NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS config JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_backtest_results_instrument ON backtest_results(instrument_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_backtest_results_strategy ON backtest_results(strategy_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_trades_backtest ON trades(backtest_id, entry_time);
@@ -0,0 +1,7 @@
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS repair_policy;
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS quality_policy;
+ALTER TABLE backtest_results DROP COLUMN IF EXISTS strategy_version;
+
+ALTER TABLE strategies DROP COLUMN IF EXISTS version;
+
+DROP TABLE IF EXISTS strategy_versions;
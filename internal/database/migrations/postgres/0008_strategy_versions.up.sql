@@ -0,0 +1,24 @@
+-- Immutable strategy config versions; backtests record the version they ran
+
+CREATE TABLE IF NOT EXISTS strategy_versions (
+	id SERIAL PRIMARY KEY,
+	strategy_id INTEGER NOT NULL REFERENCES strategies(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	config JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (strategy_id, version)
+);
+
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+INSERT INTO strategy_versions (strategy_id, version, config, created_at)
+SELECT id, 1, config, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM strategies
+ON CONFLICT (strategy_id, version) DO NOTHING;
+
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS strategy_version INTEGER;
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS quality_policy VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN IF NOT EXISTS repair_policy VARCHAR(16) NOT NULL DEFAULT '';
+
+-- Earlier runs whose config snapshot is the current config ran version 1
+UPDATE backtest_results b SET strategy_version = 1
+FROM strategies s WHERE s.id = b.strategy_id AND b.config = s.config;
@@ -0,0 +1,60 @@
+-- Core schema, mirroring the PostgreSQL migration of the same version. IF NOT EXISTS lets
+-- databases converted from a legacy candle layout adopt this version without changes.
+
+CREATE TABLE IF NOT EXISTS instruments (
+	id INTEGER PRIMARY KEY,
+	symbol TEXT NOT NULL,
+	exchange TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(symbol, exchange)
+);
+
+-- Candle timestamps are bar open times in Unix milliseconds
+CREATE TABLE IF NOT EXISTS candles (
+	id INTEGER PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	interval TEXT NOT NULL,
+	timestamp INTEGER NOT NULL,
+	open REAL NOT NULL,
+	high REAL NOT NULL,
+	low REAL NOT NULL,
+	close REAL NOT NULL,
+	volume REAL NOT NULL,
+	UNIQUE(instrument_id, interval, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS strategies (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	config TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS backtest_results (
+	id INTEGER PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	strategy_id INTEGER NOT NULL REFERENCES strategies(id),
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP NOT NULL,
+	total_trades INTEGER NOT NULL,
+	winning_trades INTEGER NOT NULL,
+	losing_trades INTEGER NOT NULL,
+	win_rate REAL NOT NULL,
+	total_pnl REAL NOT NULL,
+	total_return REAL NOT NULL,
+	metrics TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS trades (
+	id INTEGER PRIMARY KEY,
+	backtest_id INTEGER NOT NULL REFERENCES backtest_results(id),
+	side TEXT NOT NULL,
+	entry_price REAL NOT NULL,
+	entry_time TIMESTAMP NOT NULL,
+	exit_price REAL NOT NULL,
+	exit_time TIMESTAMP NOT NULL,
+	size REAL NOT NULL,
+	pnl REAL NOT NULL
+);
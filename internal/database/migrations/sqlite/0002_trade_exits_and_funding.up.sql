@@ -0,0 +1,25 @@
+-- Exit reasons, fees, scale-out fills and perpetual funding
+
+ALTER TABLE trades ADD COLUMN exit_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE trades ADD COLUMN fees REAL NOT NULL DEFAULT 0;
+ALTER TABLE trades ADD COLUMN funding REAL NOT NULL DEFAULT 0;
+
+CREATE TABLE trade_fills (
+	id INTEGER PRIMARY KEY,
+	trade_id INTEGER NOT NULL REFERENCES trades(id) ON DELETE CASCADE,
+	fill_time TIMESTAMP NOT NULL,
+	price REAL NOT NULL,
+	size REAL NOT NULL,
+	fee REAL NOT NULL,
+	pnl REAL NOT NULL,
+	reason TEXT NOT NULL
+);
+
+CREATE TABLE funding_rates (
+	id INTEGER PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id),
+	timestamp INTEGER NOT NULL,
+	rate REAL NOT NULL,
+	mark_price REAL NOT NULL DEFAULT 0,
+	UNIQUE(instrument_id, timestamp)
+);
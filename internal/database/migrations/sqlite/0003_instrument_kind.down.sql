@@ -0,0 +1,2 @@
+ALTER TABLE instruments DROP COLUMN vol_bucket;
+ALTER TABLE instruments DROP COLUMN kind;
@@ -0,0 +1,4 @@
+-- Strategy kind (CFBASE or CFATRADX) and volatility bucket (low, mid, high)
+
+ALTER TABLE instruments ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE instruments ADD COLUMN vol_bucket TEXT NOT NULL DEFAULT '';
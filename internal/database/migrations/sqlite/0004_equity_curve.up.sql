@@ -0,0 +1,9 @@
+-- Per-bar mark-to-market equity of a backtest run
+
+CREATE TABLE equity_points (
+	id INTEGER PRIMARY KEY,
+	backtest_id INTEGER NOT NULL REFERENCES backtest_results(id) ON DELETE CASCADE,
+	ts INTEGER NOT NULL,
+	equity REAL NOT NULL,
+	UNIQUE(backtest_id, ts)
+);
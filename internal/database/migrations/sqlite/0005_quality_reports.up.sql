@@ -0,0 +1,17 @@
+-- Data quality checks of candle series; the latest report per series is the current one
+
+CREATE TABLE quality_reports (
+	id INTEGER PRIMARY KEY,
+	instrument_id INTEGER NOT NULL REFERENCES instruments(id) ON DELETE CASCADE,
+	interval TEXT NOT NULL,
+	range_start INTEGER NOT NULL,
+	range_end INTEGER NOT NULL,
+	candles INTEGER NOT NULL,
+	missing_bars INTEGER NOT NULL,
+	clean BOOLEAN NOT NULL,
+	issue_counts TEXT NOT NULL,
+	details TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_quality_reports_series ON quality_reports(instrument_id, interval, created_at);
@@ -0,0 +1,3 @@
+-- Flag synthetic candles created by gap repair (empty for market data)
+
+ALTER TABLE candles ADD COLUMN repaired TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+DROP INDEX idx_trades_backtest;
+DROP INDEX idx_backtest_results_strategy;
+DROP INDEX idx_backtest_results_instrument;
+
+ALTER TABLE backtest_results DROP COLUMN config;
+ALTER TABLE backtest_results DROP COLUMN error;
+ALTER TABLE backtest_results DROP COLUMN status;
+ALTER TABLE backtest_results DROP COLUMN interval;
@@ -0,0 +1,10 @@
+-- Run status, interval and strategy config snapshot of backtests, for listing and comparing runs
+
+ALTER TABLE backtest_results ADD COLUMN interval TEXT NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN status TEXT NOT NULL DEFAULT 'DONE';
+ALTER TABLE backtest_results ADD COLUMN error TEXT NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN config TEXT;
+
+CREATE INDEX idx_backtest_results_instrument ON backtest_results(instrument_id, created_at);
+CREATE INDEX idx_backtest_results_strategy ON backtest_results(strategy_id, created_at);
+CREATE INDEX idx_trades_backtest ON trades(backtest_id, entry_time);
@@ -0,0 +1,7 @@
+ALTER TABLE backtest_results DROP COLUMN repair_policy;
+ALTER TABLE backtest_results DROP COLUMN quality_policy;
+ALTER TABLE backtest_results DROP COLUMN strategy_version;
+
+ALTER TABLE strategies DROP COLUMN version;
+
+DROP TABLE IF EXISTS strategy_versions;
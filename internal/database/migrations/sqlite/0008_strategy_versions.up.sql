@@ -0,0 +1,23 @@
+-- Immutable strategy config versions; backtests record the version they ran
+
+CREATE TABLE strategy_versions (
+	id INTEGER PRIMARY KEY,
+	strategy_id INTEGER NOT NULL REFERENCES strategies(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	config TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (strategy_id, version)
+);
+
+ALTER TABLE strategies ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+INSERT INTO strategy_versions (strategy_id, version, config, created_at)
+SELECT id, 1, config, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM strategies;
+
+ALTER TABLE backtest_results ADD COLUMN strategy_version INTEGER;
+ALTER TABLE backtest_results ADD COLUMN quality_policy TEXT NOT NULL DEFAULT '';
+ALTER TABLE backtest_results ADD COLUMN repair_policy TEXT NOT NULL DEFAULT '';
+
+-- Earlier runs whose config snapshot is the current config ran version 1
+UPDATE backtest_results SET strategy_version = 1
+WHERE config = (SELECT s.config FROM strategies s WHERE s.id = backtest_results.strategy_id);
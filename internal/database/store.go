@@ -0,0 +1,155 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// ErrConflict is returned by writes that would duplicate a unique name or remove a record
+// still referenced by another
+var ErrConflict = errors.New("conflicting record")
+
+// Store is the repository used by the engine, uploaders and API
+// Lookups of missing records return sql.ErrNoRows in every implementation, and conflicting
+// strategy writes return ErrConflict
+type Store interface {
+	// Instruments are unique on symbol and exchange; saving an existing one reuses its ID
+	SaveInstrument(inst *models.Instrument) error
+	GetInstrumentByID(id int64) (*models.Instrument, error)
+	GetInstrumentBySymbol(symbol, exchange string) (*models.Instrument, error)
+	GetAllInstruments() ([]*models.Instrument, error)
+
+	// Candles are unique on instrument, interval and timestamp; duplicates are ignored
+	SaveCandle(candle *models.Candle) error
+	SaveCandles(candles []*models.Candle) (int, error)
+	GetCandlesByTimeRange(instrumentID int64, interval string, startTime, endTime time.Time) ([]*models.Candle, error)
+	GetCandleSeries(instrumentID int64) ([]*models.CandleSeries, error)
+
+	// Funding events are unique on instrument and timestamp; duplicates are ignored
+	SaveFundingRate(rate *models.FundingRate) error
+	GetFundingRates(instrumentID int64, startTime, endTime time.Time) ([]*models.FundingRate, error)
+
+	// Strategies are unique on name; saving an existing one with another config, or updating
+	// it, adds the config as its next immutable version, while creating one fails if the name
+	// is taken
+	SaveStrategy(strategy *models.Strategy) error
+	CreateStrategy(strategy *models.Strategy) error
+	GetStrategyByName(name string) (*models.Strategy, error)
+	GetStrategyByID(id int64) (*models.Strategy, error)
+	GetAllStrategies() ([]*models.Strategy, error)
+	UpdateStrategy(strategy *models.Strategy) error
+	DeleteStrategy(id int64) error
+	GetStrategyVersions(strategyID int64) ([]*models.StrategyVersion, error)
+	GetStrategyVersion(strategyID int64, version int) (*models.StrategyVersion, error)
+
+	// Backtest runs with their trades and equity curve; SaveBacktestResult saves a run without
+	// them (a failed one), SaveBacktestRun saves a run with its Trades and Equity atomically
+	SaveBacktestResult(result *models.BacktestResult) error
+	SaveBacktestRun(result *models.BacktestResult) error
+	GetBacktestResult(id int64) (*models.BacktestResult, error)
+	ListBacktestResults(filter *BacktestFilter) ([]*models.BacktestResult, error)
+	GetTradesByBacktestID(backtestID int64) ([]*models.Trade, error)
+	GetEquityByBacktestID(backtestID int64) ([]*models.Equity, error)
+
+	// Data quality reports of candle series; the latest one per series is the current one
+	SaveQualityReport(report *models.QualityReport) error
+	GetLatestQualityReport(instrumentID int64, interval string) (*models.QualityReport, error)
+
+	Close() error
+}
+
+// BacktestFilter selects and orders the backtest results returned by ListBacktestResults
+type BacktestFilter struct {
+	InstrumentID int64     // 0 for all instruments
+	StrategyID   int64     // 0 for all strategies
+	Status       string    // models.BacktestDone or models.BacktestFailed, empty for all
+	From, To     time.Time // Runs whose tested period overlaps this range; zero for no bound
+
+	// Order: a column (see BacktestSortColumns) or a metrics key, descending if Desc
+	// Results with equal values are ordered by ID in the same direction
+	SortBy string
+	Desc   bool
+
+	// Keyset cursor: when set, only results after the one with this sort value and ID
+	After *BacktestCursor
+
+	Limit int // 0 for no limit
+}
+
+// BacktestCursor is the position of a backtest result in a sorted listing
+type BacktestCursor struct {
+	Value float64
+	ID    int64
+}
+
+// BacktestSortColumns lists the backtest result columns a listing can be sorted by; any
+// other sort key is looked up in the metrics, where missing metrics count as 0
+// Times sort by their Unix seconds
+var BacktestSortColumns = []string{
+	"created_at", "start_time", "end_time", "total_trades", "winning_trades", "losing_trades",
+	"win_rate", "total_pnl", "total_return",
+}
+
+// metricKey matches the metric names a listing can be sorted by
+var metricKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// ValidBacktestSort reports whether a listing can be sorted by key
+func ValidBacktestSort(key string) bool {
+	return metricKey.MatchString(key)
+}
+
+// BacktestSortValue returns the value a listing sorted by key orders result by
+func BacktestSortValue(result *models.BacktestResult, key string) float64 {
+	switch key {
+	case "created_at":
+		return float64(result.CreatedAt.Unix())
+	case "start_time":
+		return float64(result.StartTime.Unix())
+	case "end_time":
+		return float64(result.EndTime.Unix())
+	case "total_trades":
+		return float64(result.TotalTrades)
+	case "winning_trades":
+		return float64(result.WinningTrades)
+	case "losing_trades":
+		return float64(result.LosingTrades)
+	case "win_rate":
+		return result.WinRate
+	case "total_pnl":
+		return result.TotalPnL
+	case "total_return":
+		return result.TotalReturn
+	default:
+		return result.Metrics[key]
+	}
+}
+
+// sameConfig reports whether two strategy configs are equal, as they would be stored
+func sameConfig(a, b models.StrategyConfig) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// Open opens the store selected by the connection string scheme
+//   - memory:// is an empty in-memory store
+//   - sqlite://path/to/file.db, sqlite:file.db or file:file.db is a local SQLite file
+//   - anything else is a PostgreSQL connection string
+func Open(connString string) (Store, error) {
+	if connString == "memory://" || connString == "memory:" {
+		return NewMemoryStore(), nil
+	}
+	db, err := InitDB(connString)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+var _ Store = (*DB)(nil)
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// TestStrategyConflicts checks that every store reports taken names and strategies referenced
+// by backtests as ErrConflict
+func TestStrategyConflicts(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{name: "memory", open: func(t *testing.T) Store { return NewMemoryStore() }},
+		{name: "sqlite", open: func(t *testing.T) Store {
+			db, err := InitDB("sqlite://" + filepath.Join(t.TempDir(), "strategies.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		}},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.open(t)
+			cf := &models.Strategy{Name: "cf"}
+			if err := store.CreateStrategy(cf); err != nil {
+				t.Fatal(err)
+			}
+			other := &models.Strategy{Name: "other"}
+			if err := store.CreateStrategy(other); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.CreateStrategy(&models.Strategy{Name: "cf"}); !errors.Is(err, ErrConflict) {
+				t.Errorf("create with a taken name: %v, want ErrConflict", err)
+			}
+			other.Name = "cf"
+			if err := store.UpdateStrategy(other); !errors.Is(err, ErrConflict) {
+				t.Errorf("rename to a taken name: %v, want ErrConflict", err)
+			}
+
+			instrument := &models.Instrument{Symbol: "BTCUSDT", Exchange: "BINANCE"}
+			if err := store.SaveInstrument(instrument); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+			result := &models.BacktestResult{InstrumentID: int64(instrument.ID), StrategyID: int64(cf.ID),
+				StartTime: start, EndTime: start.Add(24 * time.Hour), Status: "DONE"}
+			if err := store.SaveBacktestResult(result); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteStrategy(int64(cf.ID)); !errors.Is(err, ErrConflict) {
+				t.Errorf("delete with a backtest: %v, want ErrConflict", err)
+			}
+			if err := store.DeleteStrategy(int64(other.ID)); err != nil {
+				t.Errorf("delete without backtests: %v", err)
+			}
+		})
+	}
+}
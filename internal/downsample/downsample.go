@@ -0,0 +1,128 @@
+package downsample
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Downsampling methods
+const (
+	MethodOHLC = "OHLC" // Merge runs of consecutive candles into one bar each
+	MethodLTTB = "LTTB" // Keep the candles that best preserve the shape of the close line
+)
+
+// ParseMethod validates a downsampling method; empty selects MethodOHLC
+func ParseMethod(method string) (string, error) {
+	switch m := strings.ToUpper(method); m {
+	case "":
+		return MethodOHLC, nil
+	case MethodOHLC, MethodLTTB:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid downsampling method %q (expected OHLC or LTTB)", method)
+	}
+}
+
+// Downsample reduces candles sorted by open time to at most maxPoints with method
+// Candles already within maxPoints are returned as they are
+func Downsample(candles []*models.Candle, method string, maxPoints int) []*models.Candle {
+	if maxPoints <= 0 || len(candles) <= maxPoints {
+		return candles
+	}
+	if method == MethodLTTB {
+		return LTTB(candles, maxPoints)
+	}
+	return OHLC(candles, maxPoints)
+}
+
+// OHLC merges candles into at most buckets bars of equally many consecutive candles
+// Each bar opens at its first candle and spans the high, low, close and total volume of its
+// candles like a resampled bar, so wicks are never lost
+func OHLC(candles []*models.Candle, buckets int) []*models.Candle {
+	if buckets <= 0 || len(candles) <= buckets {
+		return candles
+	}
+	size := (len(candles) + buckets - 1) / buckets
+
+	bars := make([]*models.Candle, 0, buckets)
+	for i := 0; i < len(candles); i += size {
+		group := candles[i:min(i+size, len(candles))]
+		bar := &models.Candle{
+			InstrumentID: group[0].InstrumentID,
+			Interval:     group[0].Interval,
+			Timestamp:    group[0].Timestamp,
+			Open:         group[0].Open,
+			High:         group[0].High,
+			Low:          group[0].Low,
+			Close:        group[len(group)-1].Close,
+		}
+		for _, candle := range group {
+			bar.High = math.Max(bar.High, candle.High)
+			bar.Low = math.Min(bar.Low, candle.Low)
+			bar.Volume += candle.Volume
+			if bar.Repaired == "" {
+				bar.Repaired = candle.Repaired
+			}
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// LTTB selects threshold candles with the Largest-Triangle-Three-Buckets algorithm over
+// (open time, close); the first and last candles are always kept and the selected candles
+// are returned unchanged
+func LTTB(candles []*models.Candle, threshold int) []*models.Candle {
+	n := len(candles)
+	if threshold >= n || threshold <= 0 {
+		return candles
+	}
+	if threshold < 3 {
+		return append([]*models.Candle{candles[0]}, candles[n-1:]...)[:threshold]
+	}
+
+	x := func(i int) float64 { return float64(candles[i].Timestamp) }
+	y := func(i int) float64 { return candles[i].Close }
+
+	// The candles between the first and last are split into threshold-2 buckets
+	every := float64(n-2) / float64(threshold-2)
+	sampled := make([]*models.Candle, 0, threshold)
+	sampled = append(sampled, candles[0])
+	a := 0
+
+	for i := 0; i < threshold-2; i++ {
+		// Average point of the next bucket (the last candle for the last bucket)
+		avgStart := int(float64(i+1)*every) + 1
+		avgEnd := min(int(float64(i+2)*every)+1, n)
+		var avgX, avgY float64
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += x(j)
+			avgY += y(j)
+		}
+		if count := avgEnd - avgStart; count > 0 {
+			avgX /= float64(count)
+			avgY /= float64(count)
+		} else {
+			avgX, avgY = x(n-1), y(n-1)
+		}
+
+		// The candle of this bucket forming the largest triangle with the previous pick
+		// and the average of the next bucket
+		start := int(float64(i)*every) + 1
+		end := min(int(float64(i+1)*every)+1, n-1)
+		best, bestArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((x(a)-avgX)*(y(j)-y(a)) - (x(a)-x(j))*(avgY-y(a)))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		sampled = append(sampled, candles[best])
+		a = best
+	}
+
+	return append(sampled, candles[n-1])
+}
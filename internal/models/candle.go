@@ -1,14 +1,30 @@
 package models
 
+// Repair methods recorded on synthetic candles
+const (
+	RepairForwardFill = "FFILL"       // Flat candle at the previous close with zero volume
+	RepairInterpolate = "INTERPOLATE" // Close interpolated between the surrounding candles
+)
+
 // Candle represents a single candlestick data point
 type Candle struct {
-	ID        int64   `json:"id"`
-	Symbol    string  `json:"symbol"`    // e.g., "BTCUSDT"
-	Interval  string  `json:"interval"`  // e.g., "1h", "4h", "1d"
-	Timestamp int64   `json:"timestamp"` // Unix timestamp
-	Open      float64 `json:"open"`
-	High      float64 `json:"high"`
-	Low       float64 `json:"low"`
-	Close     float64 `json:"close"`
-	Volume    float64 `json:"volume"`
+	ID           int64   `json:"id"`
+	InstrumentID int64   `json:"instrument_id"`
+	Interval     string  `json:"interval"`  // e.g., "1h", "4h", "1d"
+	Timestamp    int64   `json:"timestamp"` // Open time, Unix milliseconds
+	Open         float64 `json:"open"`
+	High         float64 `json:"high"`
+	Low          float64 `json:"low"`
+	Close        float64 `json:"close"`
+	Volume       float64 `json:"volume"`
+	Repaired     string  `json:"repaired,omitempty"` // Repair method of a synthetic candle, empty for market data
+}
+
+// CandleSeries summarizes the stored candles of an instrument and interval
+type CandleSeries struct {
+	InstrumentID int64  `json:"instrument_id"`
+	Interval     string `json:"interval"`
+	First        int64  `json:"first"` // Open time of the first candle, Unix milliseconds
+	Last         int64  `json:"last"`  // Open time of the last candle, Unix milliseconds
+	Count        int    `json:"count"`
 }
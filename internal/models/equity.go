@@ -2,8 +2,8 @@ package models
 
 // Equity represents equity curve point for a strategy run
 type Equity struct {
-	ID             int     `json:"id"`
-	StrategyRunID  int     `json:"strategy_run_id"`
+	ID             int64   `json:"id"`
+	StrategyRunID  int64   `json:"strategy_run_id"` // Backtest result ID
 	TS             int64   `json:"ts"` // Timestamp
 	Equity         float64 `json:"equity"`
 }
@@ -21,10 +21,12 @@ type DebugCandle struct {
 	Low            float64 `json:"low"`
 	Close          float64 `json:"close"`
 	Volume         float64 `json:"volume"`
+	Repaired       string  `json:"repaired,omitempty"` // Repair method if the candle filled a gap
 	
 	// CF values
-	MainCF         float64 `json:"main_cf"`
-	SecondCF       float64 `json:"second_cf"`
+	MainCF           float64 `json:"main_cf"`
+	SecondCF         float64 `json:"second_cf"`
+	TrailingSecondCF float64 `json:"trailing_second_cf"` // SecondCF over the window ending at the candle, used by SECOND_CF stops
 	
 	// ATR/ADX (if applicable)
 	ATRShort       float64 `json:"atr_short"`
@@ -38,4 +40,7 @@ type DebugCandle struct {
 	// Window sizes
 	WindowMain     int     `json:"window_main"`     // newTotalKlines
 	WindowSecond   int     `json:"window_second"`   // secondTotalKlines
+
+	// Order lifecycle events on this candle
+	OrderEvents    []OrderEvent `json:"order_events,omitempty"`
 }
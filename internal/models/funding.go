@@ -0,0 +1,10 @@
+package models
+
+// FundingRate represents a perpetual futures funding event
+type FundingRate struct {
+	ID           int64   `json:"id"`
+	InstrumentID int64   `json:"instrument_id"`
+	Timestamp    int64   `json:"timestamp"`  // Funding time, Unix milliseconds
+	Rate         float64 `json:"rate"`       // e.g. 0.0001 = 0.01% per funding interval
+	MarkPrice    float64 `json:"mark_price"` // 0 if unknown
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Interval units in milliseconds (Binance notation: 1m, 4h, 1d, 1w)
+var intervalUnits = map[byte]int64{
+	'm': 60 * 1000,
+	'h': 60 * 60 * 1000,
+	'd': 24 * 60 * 60 * 1000,
+	'w': 7 * 24 * 60 * 60 * 1000,
+}
+
+// IntervalMillis returns the length of a candle interval in milliseconds
+// Calendar months (1M) have no fixed length and return an error
+func IntervalMillis(interval string) (int64, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+
+	unit, ok := intervalUnits[interval[len(interval)-1]]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval %q", interval)
+	}
+
+	n, err := strconv.ParseInt(interval[:len(interval)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+
+	return n * unit, nil
+}
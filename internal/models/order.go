@@ -0,0 +1,13 @@
+package models
+
+// OrderEvent records a change in a simulated order's lifecycle
+type OrderEvent struct {
+	OrderID  int     `json:"order_id"`
+	TS       int64   `json:"ts"`
+	Type     string  `json:"type"`  // MARKET, LIMIT, STOP or STOP_LIMIT
+	Side     string  `json:"side"`  // LONG or SHORT
+	Event    string  `json:"event"` // SUBMITTED, TRIGGERED, FILLED, PARTIALLY_FILLED, CANCELLED, PARTIALLY_CANCELLED, EXPIRED
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Reason   string  `json:"reason,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Data quality issue types
+const (
+	IssueGap              = "GAP"                // Bars missing between two candles
+	IssueDuplicate        = "DUPLICATE"          // Several candles for the same bar
+	IssueMisaligned       = "MISALIGNED"         // Open time not on an interval boundary
+	IssueOHLCInconsistent = "OHLC_INCONSISTENT"  // High/low do not bound open/close
+	IssueNonPositivePrice = "NON_POSITIVE_PRICE" // Zero or negative open/high/low/close
+	IssueNegativeVolume   = "NEGATIVE_VOLUME"
+	IssueOutlier          = "OUTLIER" // Close-to-close move far outside the series' usual range
+)
+
+// QualityReport summarizes data quality checks of a candle series
+type QualityReport struct {
+	ID           int64          `json:"id"`
+	InstrumentID int64          `json:"instrument_id"`
+	Interval     string         `json:"interval"`
+	RangeStart   int64          `json:"range_start"` // First candle checked, Unix milliseconds
+	RangeEnd     int64          `json:"range_end"`   // Last candle checked, Unix milliseconds
+	Candles      int            `json:"candles"`
+	MissingBars  int            `json:"missing_bars"`
+	Clean        bool           `json:"clean"` // No issues, outliers included
+	IssueCounts  map[string]int `json:"issue_counts"`
+	CreatedAt    time.Time      `json:"created_at"`
+
+	// Gaps and issues in time order (issues are capped; IssueCounts is complete)
+	Gaps   []CandleGap    `json:"gaps,omitempty"`
+	Issues []QualityIssue `json:"issues,omitempty"`
+}
+
+// CandleGap is a run of missing bars
+type CandleGap struct {
+	From    int64 `json:"from"` // Open time of the first missing bar
+	To      int64 `json:"to"`   // Open time of the last missing bar
+	Missing int   `json:"missing"`
+}
+
+// QualityIssue is a single problem found in a candle series
+type QualityIssue struct {
+	TS     int64  `json:"ts"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
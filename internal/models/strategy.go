@@ -1,6 +1,11 @@
 package models
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
 
 // Strategy represents a backtesting strategy configuration
 type Strategy struct {
@@ -8,6 +13,47 @@ type Strategy struct {
 	Name       string         `json:"name"`
 	Config     StrategyConfig `json:"config"`     // Strategy parameters
 	CreatedAt  int64          `json:"created_at"`
+	UpdatedAt  int64          `json:"updated_at"` // Unix seconds of the last config change
+	Version    int            `json:"version"`    // Current config version, from 1
+}
+
+// StrategyVersion is an immutable config of a strategy; every config change adds a version
+type StrategyVersion struct {
+	ID         int64          `json:"id"`
+	StrategyID int64          `json:"strategy_id"`
+	Version    int            `json:"version"`
+	Config     StrategyConfig `json:"config"`
+	CreatedAt  int64          `json:"created_at"`
+}
+
+// ConfigChange is a strategy config field that differs between two versions
+type ConfigChange struct {
+	Field string          `json:"field"` // JSON name of the field
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// DiffConfig lists the fields that differ between two configs, in declaration order
+func DiffConfig(from, to StrategyConfig) []ConfigChange {
+	fromFields, toFields := configFields(from), configFields(to)
+
+	changes := []ConfigChange{}
+	t := reflect.TypeOf(StrategyConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if !bytes.Equal(fromFields[name], toFields[name]) {
+			changes = append(changes, ConfigChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+	return changes
+}
+
+// configFields returns the JSON encoding of each field of config by JSON name
+func configFields(config StrategyConfig) map[string]json.RawMessage {
+	data, _ := json.Marshal(config)
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal(data, &fields)
+	return fields
 }
 
 // StrategyConfig holds all strategy parameters
@@ -37,9 +83,38 @@ type StrategyConfig struct {
 	ADXMin         float64 `json:"adx_min"`
 	Kvol           float64 `json:"kvol"`
 
+	// Execution
+	ExecutionMode        string `json:"execution_mode"`          // SIGNAL_CLOSE, NEXT_OPEN or NEXT_VWAP
+	ExecutionLatency     int    `json:"execution_latency"`       // Extra bars between signal and fill
+	ExitOnOppositeSignal bool   `json:"exit_on_opposite_signal"` // Close position on a reverse entry signal
+
+	// Entry orders
+	EntryOrderType     string  `json:"entry_order_type"`      // MARKET, LIMIT, STOP or STOP_LIMIT
+	EntryOffsetATR     float64 `json:"entry_offset_atr"`      // Limit/stop distance from signal close in ATR
+	StopLimitOffsetATR float64 `json:"stop_limit_offset_atr"` // Limit distance beyond the stop for STOP_LIMIT
+	OrderTimeInForce   string  `json:"order_time_in_force"`   // GTC or BARS
+	OrderBarsToLive    int     `json:"order_bars_to_live"`    // Lifetime for BARS orders
+	OrderMaxVolumePct  float64 `json:"order_max_volume_pct"`  // Max share of bar volume filled per bar (0 = unlimited)
+
+	// Exit management
+	TrailingStopMode string  `json:"trailing_stop_mode"` // ATR_CHANDELIER, PERCENT, SECOND_CF or empty for none
+	TrailingATRMult  float64 `json:"trailing_atr_mult"`  // Trail distance in ATR
+	TrailingPercent  float64 `json:"trailing_percent"`   // Trail distance in percent for PERCENT mode
+	BreakEvenATR     float64 `json:"break_even_atr"`     // Move stop to entry after this many ATR in profit (0 = off)
+	MaxBarsInTrade   int     `json:"max_bars_in_trade"`  // Exit after this many bars in the trade (0 = off)
+
+	// Scale-out targets; when empty the whole position is taken at 3 ATR
+	TakeProfitTargets []TakeProfitTarget `json:"take_profit_targets"`
+
 	// Fees
 	FeeRate float64 `json:"fee_rate"`
 
 	// Risk Management
 	MaxDrawdownStop float64 `json:"max_drawdown_stop"`
 }
+
+// TakeProfitTarget closes part of a position at a distance from entry
+type TakeProfitTarget struct {
+	ATRMultiple float64 `json:"atr_multiple"` // Distance from entry in ATR
+	Fraction    float64 `json:"fraction"`     // Share of the initial size to close
+}
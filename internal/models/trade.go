@@ -20,8 +20,38 @@ type BacktestResult struct {
 	TotalReturn  float64            `json:"total_return"`
 	Metrics      map[string]float64 `json:"metrics"`
 	CreatedAt    time.Time          `json:"created_at"`
+
+	// Run settings: candle interval, the strategy version and a snapshot of its config, and the
+	// data quality and gap repair policies
+	Interval        string          `json:"interval,omitempty"`
+	StrategyVersion int             `json:"strategy_version,omitempty"`
+	Config          *StrategyConfig `json:"config,omitempty"`
+	QualityPolicy   string          `json:"quality_policy,omitempty"`
+	RepairPolicy    string          `json:"repair_policy,omitempty"`
+
+	// DONE, or FAILED with the error that stopped the run
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Trades executed during the run
+	Trades []*Trade `json:"trades,omitempty"`
+
+	// Mark-to-market equity after each bar (stored separately)
+	Equity []*Equity `json:"-"`
+
+	// Data quality of the candle range (unless the check is disabled)
+	Quality *QualityReport `json:"quality,omitempty"`
+
+	// Debug trace (only when debug mode is enabled)
+	Debug []*DebugCandle `json:"debug,omitempty"`
 }
 
+// Backtest run states
+const (
+	BacktestDone   = "DONE"
+	BacktestFailed = "FAILED"
+)
+
 // Trade represents a single trading position
 type Trade struct {
 	ID         int64     `json:"id"`
@@ -30,8 +60,26 @@ type Trade struct {
 	EntryTime  time.Time `json:"entry_time"`
 	ExitPrice  float64   `json:"exit_price"`
 	ExitTime   time.Time `json:"exit_time"`
+	ExitReason string    `json:"exit_reason"` // STOP_LOSS, TAKE_PROFIT, TRAILING_STOP, BREAK_EVEN, TIME_EXIT, SIGNAL, END_OF_DATA
 	Size       float64   `json:"size"`
-	PnL        float64   `json:"pnl"`
+	Fees       float64   `json:"fees"`
+	Funding    float64   `json:"funding"` // Cumulative funding (negative = paid)
+	PnL        float64   `json:"pnl"`     // Net of fees and funding
+
+	// Exit fills (several when the position was scaled out)
+	Fills []*TradeFill `json:"fills,omitempty"`
+}
+
+// TradeFill represents a single exit fill of a trade
+type TradeFill struct {
+	ID      int64     `json:"id"`
+	TradeID int64     `json:"trade_id"`
+	Time    time.Time `json:"time"`
+	Price   float64   `json:"price"`
+	Size    float64   `json:"size"`
+	Fee     float64   `json:"fee"`
+	PnL     float64   `json:"pnl"`    // Net of the fill's fee
+	Reason  string    `json:"reason"` // Exit reason of this fill
 }
 
 // StrategyRun represents a single backtest run
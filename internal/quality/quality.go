@@ -0,0 +1,226 @@
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Policies for running a backtest on a range with quality issues
+const (
+	PolicyIgnore = "IGNORE" // Do not check
+	PolicyWarn   = "WARN"   // Check and attach the report to the result
+	PolicyRefuse = "REFUSE" // Check and fail the run unless the range is clean
+)
+
+// ErrDirtyData is returned when a range fails the checks under PolicyRefuse
+var ErrDirtyData = errors.New("candle data failed quality checks")
+
+// Options configures the checks
+type Options struct {
+	// OutlierThreshold flags close-to-close log returns further than this many robust
+	// standard deviations (1.4826 * MAD) from the median return (0 = off)
+	OutlierThreshold float64
+
+	// MaxIssues caps the issues listed in a report; counts are always complete
+	MaxIssues int
+}
+
+// DefaultOptions returns the default check options
+func DefaultOptions() Options {
+	return Options{OutlierThreshold: 12, MaxIssues: 1000}
+}
+
+// Checker validates candle series
+type Checker struct {
+	opts Options
+}
+
+// NewChecker creates a new quality checker
+func NewChecker(opts Options) *Checker {
+	return &Checker{opts: opts}
+}
+
+// ParsePolicy validates a policy name; empty selects PolicyWarn
+func ParsePolicy(policy string) (string, error) {
+	switch p := strings.ToUpper(policy); p {
+	case "":
+		return PolicyWarn, nil
+	case PolicyIgnore, PolicyWarn, PolicyRefuse:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid data quality policy %q (expected IGNORE, WARN or REFUSE)", policy)
+	}
+}
+
+// Check runs all checks on candles sorted by open time
+// Gap and alignment checks are skipped for intervals without a fixed length (1M)
+func (c *Checker) Check(candles []*models.Candle, interval string) *models.QualityReport {
+	report := &models.QualityReport{
+		Interval:    interval,
+		Candles:     len(candles),
+		IssueCounts: make(map[string]int),
+		CreatedAt:   time.Now(),
+	}
+	if len(candles) == 0 {
+		report.Clean = true
+		return report
+	}
+	report.InstrumentID = candles[0].InstrumentID
+	report.RangeStart = candles[0].Timestamp
+	report.RangeEnd = candles[len(candles)-1].Timestamp
+
+	step, err := models.IntervalMillis(interval)
+	if err != nil {
+		step = 0
+	}
+
+	for i, candle := range candles {
+		if step > 0 {
+			if candle.Timestamp%step != 0 && step <= 24*60*60*1000 {
+				c.add(report, candle.Timestamp, models.IssueMisaligned,
+					fmt.Sprintf("open time is not a multiple of %s", interval))
+			}
+			if i > 0 {
+				c.checkSpacing(report, candles[i-1], candle, step)
+			}
+		}
+		c.checkPrices(report, candle)
+	}
+
+	if c.opts.OutlierThreshold > 0 {
+		c.checkOutliers(report, candles)
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool { return report.Issues[i].TS < report.Issues[j].TS })
+
+	// Outlier spikes count like any other issue: bad ticks are what REFUSE guards against
+	report.Clean = true
+	for _, count := range report.IssueCounts {
+		if count > 0 {
+			report.Clean = false
+		}
+	}
+	return report
+}
+
+// checkSpacing reports duplicates and gaps between consecutive candles
+func (c *Checker) checkSpacing(report *models.QualityReport, prev, candle *models.Candle, step int64) {
+	diff := candle.Timestamp - prev.Timestamp
+	switch {
+	case diff == 0:
+		c.add(report, candle.Timestamp, models.IssueDuplicate, "several candles for this bar")
+	case diff > step:
+		missing := int((diff - 1) / step)
+		gap := models.CandleGap{
+			From:    prev.Timestamp + step,
+			To:      prev.Timestamp + int64(missing)*step,
+			Missing: missing,
+		}
+		report.Gaps = append(report.Gaps, gap)
+		report.MissingBars += missing
+		c.add(report, gap.From, models.IssueGap, fmt.Sprintf("%d bars missing until %s", missing,
+			time.UnixMilli(gap.To).UTC().Format(time.RFC3339)))
+	}
+}
+
+// checkPrices reports non-positive prices, negative volume and inconsistent OHLC
+func (c *Checker) checkPrices(report *models.QualityReport, candle *models.Candle) {
+	if candle.Open <= 0 || candle.High <= 0 || candle.Low <= 0 || candle.Close <= 0 {
+		c.add(report, candle.Timestamp, models.IssueNonPositivePrice, fmt.Sprintf("O=%g H=%g L=%g C=%g",
+			candle.Open, candle.High, candle.Low, candle.Close))
+	}
+	if candle.Volume < 0 {
+		c.add(report, candle.Timestamp, models.IssueNegativeVolume, fmt.Sprintf("volume %g", candle.Volume))
+	}
+	if candle.High < math.Max(candle.Open, candle.Close) || candle.Low > math.Min(candle.Open, candle.Close) ||
+		candle.High < candle.Low {
+		c.add(report, candle.Timestamp, models.IssueOHLCInconsistent, fmt.Sprintf("O=%g H=%g L=%g C=%g",
+			candle.Open, candle.High, candle.Low, candle.Close))
+	}
+}
+
+// checkOutliers flags close-to-close moves far outside the series' typical range
+// Median and MAD are robust to the spikes being searched for, unlike mean and stddev
+func (c *Checker) checkOutliers(report *models.QualityReport, candles []*models.Candle) {
+	returns := make([]float64, 0, len(candles))
+	idx := make([]int, 0, len(candles))
+	for i := 1; i < len(candles); i++ {
+		if candles[i-1].Close > 0 && candles[i].Close > 0 {
+			returns = append(returns, math.Log(candles[i].Close/candles[i-1].Close))
+			idx = append(idx, i)
+		}
+	}
+	if len(returns) < 10 {
+		return
+	}
+
+	median := medianOf(returns)
+	deviations := make([]float64, len(returns))
+	for i, r := range returns {
+		deviations[i] = math.Abs(r - median)
+	}
+	sigma := 1.4826 * medianOf(deviations)
+	if sigma == 0 {
+		return
+	}
+
+	for i, r := range returns {
+		if score := math.Abs(r-median) / sigma; score > c.opts.OutlierThreshold {
+			candle := candles[idx[i]]
+			c.add(report, candle.Timestamp, models.IssueOutlier, fmt.Sprintf("close moved %.2f%% (%.1f robust sigma)",
+				(math.Exp(r)-1)*100, score))
+		}
+	}
+}
+
+// add counts an issue and lists it while under MaxIssues
+func (c *Checker) add(report *models.QualityReport, ts int64, issueType, detail string) {
+	report.IssueCounts[issueType]++
+	if c.opts.MaxIssues <= 0 || len(report.Issues) < c.opts.MaxIssues {
+		report.Issues = append(report.Issues, models.QualityIssue{TS: ts, Type: issueType, Detail: detail})
+	}
+}
+
+// Summary returns a one-line description of a report
+func Summary(report *models.QualityReport) string {
+	if report.Candles == 0 {
+		return "no candles"
+	}
+
+	types := make([]string, 0, len(report.IssueCounts))
+	for issueType := range report.IssueCounts {
+		types = append(types, issueType)
+	}
+	sort.Strings(types)
+
+	parts := make([]string, 0, len(types))
+	for _, issueType := range types {
+		parts = append(parts, fmt.Sprintf("%d %s", report.IssueCounts[issueType], issueType))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no issues")
+	}
+
+	return fmt.Sprintf("%d %s candles from %s to %s: %s (%d missing bars)",
+		report.Candles, report.Interval,
+		time.UnixMilli(report.RangeStart).UTC().Format(time.RFC3339),
+		time.UnixMilli(report.RangeEnd).UTC().Format(time.RFC3339),
+		strings.Join(parts, ", "), report.MissingBars)
+}
+
+// medianOf returns the median of values
+func medianOf(values []float64) float64 {
+	values = append([]float64(nil), values...)
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,72 @@
+package quality
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// walk returns n consistent hourly candles of a random walk, with the closes at spikes
+// multiplied by the factor given and the bars at gaps left out
+func walk(n int, spikes map[int]float64, gaps map[int]bool) []*models.Candle {
+	rng := rand.New(rand.NewSource(1))
+	var candles []*models.Candle
+	price := 100.0
+	for i := 0; i < n; i++ {
+		open := price
+		price *= 1 + rng.NormFloat64()*0.005
+		close := price
+		if factor, ok := spikes[i]; ok {
+			close *= factor
+		}
+		if gaps[i] {
+			continue
+		}
+		candles = append(candles, &models.Candle{
+			Timestamp: int64(i) * 3600_000,
+			Open:      open,
+			High:      max(open, close) * 1.001,
+			Low:       min(open, close) * 0.999,
+			Close:     close,
+			Volume:    1000,
+		})
+	}
+	return candles
+}
+
+// TestRefuseDecision checks which ranges PolicyRefuse runs, with and without gap repair
+func TestRefuseDecision(t *testing.T) {
+	spike := map[int]float64{100: 1.5}
+	gap := map[int]bool{150: true, 151: true}
+
+	tests := []struct {
+		name     string
+		candles  []*models.Candle
+		outliers int
+		clean    bool // Clean as checked
+		repaired bool // Clean once gaps are forward filled
+	}{
+		{"clean", walk(200, nil, nil), 0, true, true},
+		{"outlier spike", walk(200, spike, nil), 2, false, false}, // The spike and its reversal
+		{"gap", walk(200, nil, gap), 0, false, true},
+		{"gap and outlier spike", walk(200, spike, gap), 2, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewChecker(DefaultOptions()).Check(tt.candles, "1h")
+			if got := report.IssueCounts[models.IssueOutlier]; got != tt.outliers {
+				t.Errorf("%d outliers, want %d: %+v", got, tt.outliers, report.Issues)
+			}
+			if report.Clean != tt.clean {
+				t.Errorf("clean %v, want %v: %s", report.Clean, tt.clean, Summary(report))
+			}
+			if got := CleanAfterRepair(report, RepairForwardFill); got != tt.repaired {
+				t.Errorf("clean after repair %v, want %v: %s", got, tt.repaired, Summary(report))
+			}
+			if got := CleanAfterRepair(report, RepairNone); got != tt.clean {
+				t.Errorf("clean without repair %v, want %v", got, tt.clean)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Policies for repairing gaps in a candle series
+const (
+	RepairNone        = "NONE"                   // Leave gaps as they are
+	RepairForwardFill = models.RepairForwardFill // Fill missing bars with flat candles at the previous close
+	RepairInterpolate = models.RepairInterpolate // Fill missing bars on a straight line to the next open
+	RepairSplit       = "SPLIT"                  // Cut the series at gaps into separate sessions (on load only)
+)
+
+// ParseRepairPolicy validates a repair policy name; empty selects RepairNone
+func ParseRepairPolicy(policy string) (string, error) {
+	switch p := strings.ToUpper(policy); p {
+	case "":
+		return RepairNone, nil
+	case RepairNone, RepairForwardFill, RepairInterpolate, RepairSplit:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid gap repair policy %q (expected NONE, FFILL, INTERPOLATE or SPLIT)", policy)
+	}
+}
+
+// FillGap returns synthetic candles for the bars missing between prev and next
+// Filled candles have zero volume and Repaired set to the policy; nothing is returned
+// unless policy is RepairForwardFill or RepairInterpolate and next is more than one step after prev
+func FillGap(prev, next *models.Candle, step int64, policy string) []*models.Candle {
+	if step <= 0 || next.Timestamp-prev.Timestamp <= step {
+		return nil
+	}
+	if policy != RepairForwardFill && policy != RepairInterpolate {
+		return nil
+	}
+
+	missing := int((next.Timestamp - prev.Timestamp - 1) / step)
+	filled := make([]*models.Candle, 0, missing)
+	open := prev.Close
+	for k := 1; k <= missing; k++ {
+		close := prev.Close
+		if policy == RepairInterpolate {
+			close = prev.Close + (next.Open-prev.Close)*float64(k)/float64(missing+1)
+		}
+		filled = append(filled, &models.Candle{
+			InstrumentID: prev.InstrumentID,
+			Interval:     prev.Interval,
+			Timestamp:    prev.Timestamp + int64(k)*step,
+			Open:         open,
+			High:         math.Max(open, close),
+			Low:          math.Min(open, close),
+			Close:        close,
+			Repaired:     policy,
+		})
+		open = close
+	}
+	return filled
+}
+
+// Repair fills the gaps of candles sorted by open time and returns the repaired series
+// with the number of candles added; RepairNone and RepairSplit return candles unchanged
+func Repair(candles []*models.Candle, interval, policy string) ([]*models.Candle, int, error) {
+	if policy != RepairForwardFill && policy != RepairInterpolate || len(candles) < 2 {
+		return candles, 0, nil
+	}
+	step, err := models.IntervalMillis(interval)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot repair gaps: %w", err)
+	}
+
+	repaired := make([]*models.Candle, 0, len(candles))
+	added := 0
+	for i, candle := range candles {
+		if i > 0 {
+			filled := FillGap(candles[i-1], candle, step, policy)
+			repaired = append(repaired, filled...)
+			added += len(filled)
+		}
+		repaired = append(repaired, candle)
+	}
+	return repaired, added, nil
+}
+
+// Split cuts candles sorted by open time into runs without gaps
+// Series whose interval has no fixed length (1M) are returned as one segment
+func Split(candles []*models.Candle, interval string) [][]*models.Candle {
+	step, err := models.IntervalMillis(interval)
+	if err != nil || len(candles) == 0 {
+		return [][]*models.Candle{candles}
+	}
+
+	var segments [][]*models.Candle
+	start := 0
+	for i := 1; i < len(candles); i++ {
+		if candles[i].Timestamp-candles[i-1].Timestamp > step {
+			segments = append(segments, candles[start:i])
+			start = i
+		}
+	}
+	return append(segments, candles[start:])
+}
+
+// CleanAfterRepair reports whether a range is clean once its gaps are handled by policy,
+// so that a range whose only issues are gaps can be run under PolicyRefuse
+func CleanAfterRepair(report *models.QualityReport, policy string) bool {
+	if report.Clean || policy == RepairNone {
+		return report.Clean
+	}
+	for issueType, count := range report.IssueCounts {
+		if issueType != models.IssueGap && count > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,329 @@
+package resample
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Policies for target bars not fully covered by source candles
+const (
+	PartialDrop = "DROP" // Leave incomplete bars out
+	PartialKeep = "KEEP" // Build incomplete bars from the candles available
+)
+
+// ErrIncompatible is returned when the target interval is not a whole multiple of the source
+var ErrIncompatible = errors.New("intervals cannot be resampled")
+
+// day is the length of a day in milliseconds
+const day = 24 * 60 * 60 * 1000
+
+// weekOffset aligns weekly bars to Monday 00:00 UTC; the Unix epoch was a Thursday
+const weekOffset = 4 * day
+
+// Report summarizes a resampling run
+type Report struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	SourceCandles int    `json:"source_candles"`
+	Candles       int    `json:"candles"`  // Bars built
+	Partial       int    `json:"partial"`  // Bars with fewer source candles than the interval holds
+	Dropped       int    `json:"dropped"`  // Partial bars left out under PartialDrop
+	Inserted      int    `json:"inserted"` // Bars that were new to the store (Materialize only)
+}
+
+// ParsePartial validates a partial bar policy; empty selects PartialDrop
+func ParsePartial(policy string) (string, error) {
+	switch p := strings.ToUpper(policy); p {
+	case "":
+		return PartialDrop, nil
+	case PartialDrop, PartialKeep:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid partial bar policy %q (expected DROP or KEEP)", policy)
+	}
+}
+
+// frame computes the bar boundaries of an interval in UTC
+// Bars of up to a day open on multiples of their length from the epoch, weekly bars
+// on Mondays and monthly bars (1M) on the first of the month
+type frame struct {
+	step   int64 // Bar length in milliseconds, 0 for calendar months
+	offset int64 // Bar boundary offset from the epoch
+}
+
+// newFrame returns the frame of an interval
+func newFrame(interval string) (frame, error) {
+	if interval == "1M" {
+		return frame{}, nil
+	}
+	step, err := models.IntervalMillis(interval)
+	if err != nil {
+		return frame{}, err
+	}
+	f := frame{step: step}
+	if step%(7*day) == 0 {
+		f.offset = weekOffset
+	}
+	return f, nil
+}
+
+// start returns the open time of the bar containing ts
+func (f frame) start(ts int64) int64 {
+	if f.step == 0 {
+		t := time.UnixMilli(ts).UTC()
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	n := (ts - f.offset) / f.step
+	if (ts-f.offset)%f.step < 0 {
+		n--
+	}
+	return n*f.step + f.offset
+}
+
+// next returns the open time of the bar after the one opening at start
+func (f frame) next(start int64) int64 {
+	if f.step == 0 {
+		return time.UnixMilli(start).UTC().AddDate(0, 1, 0).UnixMilli()
+	}
+	return start + f.step
+}
+
+// covers reports whether every bar of f is made of whole bars of src
+func (f frame) covers(src frame) bool {
+	switch {
+	case src.step == 0:
+		return false
+	case f.step == 0:
+		return src.offset == 0 && day%src.step == 0
+	default:
+		return f.step > src.step && f.step%src.step == 0 && (f.offset-src.offset)%src.step == 0
+	}
+}
+
+// CanResample reports whether candles of interval from can be aggregated into interval to
+func CanResample(from, to string) bool {
+	src, err := newFrame(from)
+	if err != nil {
+		return false
+	}
+	dst, err := newFrame(to)
+	if err != nil {
+		return false
+	}
+	return dst.covers(src)
+}
+
+// Resample aggregates candles of interval from, sorted by open time, into bars of interval to
+// Each bar opens with its first candle, closes with its last and spans their high, low and
+// total volume; bars built from repaired candles keep the repair method
+func Resample(candles []*models.Candle, from, to, partial string) ([]*models.Candle, *Report, error) {
+	src, err := newFrame(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := newFrame(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !dst.covers(src) {
+		return nil, nil, fmt.Errorf("%w: %s into %s", ErrIncompatible, from, to)
+	}
+
+	report := &Report{From: from, To: to, SourceCandles: len(candles)}
+	var bars []*models.Candle
+	var bar *models.Candle
+	count := 0
+
+	emit := func() {
+		if bar == nil {
+			return
+		}
+		if expected := int((dst.next(bar.Timestamp) - bar.Timestamp) / src.step); count < expected {
+			report.Partial++
+			if partial != PartialKeep {
+				report.Dropped++
+				return
+			}
+		}
+		bars = append(bars, bar)
+	}
+
+	for _, candle := range candles {
+		if start := dst.start(candle.Timestamp); bar == nil || start != bar.Timestamp {
+			emit()
+			bar = &models.Candle{
+				InstrumentID: candle.InstrumentID,
+				Interval:     to,
+				Timestamp:    start,
+				Open:         candle.Open,
+				High:         candle.High,
+				Low:          candle.Low,
+			}
+			count = 0
+		}
+		bar.High = math.Max(bar.High, candle.High)
+		bar.Low = math.Min(bar.Low, candle.Low)
+		bar.Close = candle.Close
+		bar.Volume += candle.Volume
+		if bar.Repaired == "" {
+			bar.Repaired = candle.Repaired
+		}
+		count++
+	}
+	emit()
+
+	report.Candles = len(bars)
+	return bars, report, nil
+}
+
+// Source picks the stored interval to build interval from: the coarsest stored interval
+// it is a multiple of, or "" if there is none
+func Source(series []*models.CandleSeries, interval string) string {
+	dst, err := newFrame(interval)
+	if err != nil {
+		return ""
+	}
+	best, bestStep := "", int64(0)
+	for _, s := range series {
+		src, err := newFrame(s.Interval)
+		if err != nil || s.Count == 0 || !dst.covers(src) {
+			continue
+		}
+		if src.step > bestStep {
+			best, bestStep = s.Interval, src.step
+		}
+	}
+	return best
+}
+
+// Load retrieves the candles of an interval within a time range like
+// Store.GetCandlesByTimeRange; when the interval is not stored for the instrument, the
+// bars are computed from the coarsest stored interval that divides it
+// Returns the candles and the interval they were read from (empty if nothing is stored)
+func Load(store database.Store, instrumentID int64, interval string, startTime, endTime time.Time) ([]*models.Candle, string, error) {
+	candles, err := store.GetCandlesByTimeRange(instrumentID, interval, startTime, endTime)
+	if err != nil || len(candles) > 0 {
+		return candles, interval, err
+	}
+
+	// Nothing in range: resample only if the interval is not stored at all
+	series, err := store.GetCandleSeries(instrumentID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, s := range series {
+		if s.Interval == interval {
+			return candles, interval, nil
+		}
+	}
+	source := Source(series, interval)
+	if source == "" {
+		return nil, "", nil
+	}
+
+	// Read whole bars around the range, then keep the bars opening inside it
+	dst, err := newFrame(interval)
+	if err != nil {
+		return nil, "", err
+	}
+	start, end := startTime.UnixMilli(), endTime.UnixMilli()
+	candles, err = store.GetCandlesByTimeRange(instrumentID, source,
+		time.UnixMilli(dst.start(start)), time.UnixMilli(dst.next(dst.start(end))-1))
+	if err != nil {
+		return nil, "", err
+	}
+	bars, _, err := Resample(candles, source, interval, PartialDrop)
+	if err != nil {
+		return nil, "", err
+	}
+
+	inRange := bars[:0]
+	for _, bar := range bars {
+		if bar.Timestamp >= start && bar.Timestamp <= end {
+			inRange = append(inRange, bar)
+		}
+	}
+	return inRange, source, nil
+}
+
+// materializeWindow is the number of source candles read at a time by Materialize
+const materializeWindow = 100000
+
+// Materialize builds bars of interval to from the stored candles of interval from within
+// a time range and saves them; bars already stored are kept
+// Bars are built window by window, so the source series is never loaded at once
+// With PartialKeep, an incomplete last bar is stored as is and not updated by later runs
+func Materialize(store database.Store, instrumentID int64, from, to string, startTime, endTime time.Time, partial string) (*Report, error) {
+	src, err := newFrame(from)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := newFrame(to)
+	if err != nil {
+		return nil, err
+	}
+	if !dst.covers(src) {
+		return nil, fmt.Errorf("%w: %s into %s", ErrIncompatible, from, to)
+	}
+
+	report := &Report{From: from, To: to}
+
+	// Clamp the range to the stored source candles
+	series, err := store.GetCandleSeries(instrumentID)
+	if err != nil {
+		return nil, err
+	}
+	var first, last int64 = math.MaxInt64, math.MinInt64
+	for _, s := range series {
+		if s.Interval == from {
+			first, last = s.First, s.Last
+		}
+	}
+	start, end := max(startTime.UnixMilli(), first), min(endTime.UnixMilli(), last)
+	if start > end {
+		return report, nil
+	}
+
+	// Whole target bars per window
+	barsPerWindow := 1
+	if dst.step > 0 {
+		barsPerWindow = max(1, int(materializeWindow*src.step/dst.step))
+	} else {
+		barsPerWindow = max(1, int(materializeWindow*src.step/(31*day)))
+	}
+
+	for cursor := dst.start(start); cursor <= end; {
+		windowEnd := cursor
+		for n := 0; n < barsPerWindow && windowEnd <= end; n++ {
+			windowEnd = dst.next(windowEnd)
+		}
+
+		candles, err := store.GetCandlesByTimeRange(instrumentID, from, time.UnixMilli(cursor), time.UnixMilli(windowEnd-1))
+		if err != nil {
+			return report, err
+		}
+		bars, window, err := Resample(candles, from, to, partial)
+		if err != nil {
+			return report, err
+		}
+		inserted, err := store.SaveCandles(bars)
+		if err != nil {
+			return report, fmt.Errorf("failed to save %s bars from %s: %w", to,
+				time.UnixMilli(cursor).UTC().Format(time.RFC3339), err)
+		}
+
+		report.SourceCandles += window.SourceCandles
+		report.Candles += window.Candles
+		report.Partial += window.Partial
+		report.Dropped += window.Dropped
+		report.Inserted += inserted
+		cursor = windowEnd
+	}
+	return report, nil
+}
@@ -27,6 +27,9 @@ func (c *CFCalculator) CalculateMainCF(candles []*models.Candle, t int, prevCF f
 	// Step 1: Calculate newTotalKlines (adaptive window)
 	newTotalKlines = c.calculateNewTotalKlines(candles, t)
 	
+	// The window cannot reach before the first candle (newTotalKlinesMax > totalKlines)
+	newTotalKlines = c.clamp(newTotalKlines, 0, t+1)
+	
 	// Step 2: Extract window
 	window := candles[t-newTotalKlines+1 : t+1]
 	
@@ -108,7 +111,7 @@ func (c *CFCalculator) CalculateSecondCF(candles []*models.Candle, entryIdx int,
 	secondTotalKlines = c.calculateSecondTotalKlines(mainCFEntry, prevSecondTotalKlines)
 	
 	// Need enough candles
-	if entryIdx+secondTotalKlines >= len(candles) {
+	if secondTotalKlines < 0 || entryIdx+secondTotalKlines >= len(candles) {
 		return 0, secondTotalKlines
 	}
 	
@@ -121,6 +124,15 @@ func (c *CFCalculator) CalculateSecondCF(candles []*models.Candle, entryIdx int,
 	return secondCF, secondTotalKlines
 }
 
+// CalculateTrailingSecondCF calculates SecondCF over the secondTotalKlines candles ending at t
+// Unlike CalculateSecondCF it reads no candle after t, so it can drive decisions on bar t
+func (c *CFCalculator) CalculateTrailingSecondCF(candles []*models.Candle, t int, secondTotalKlines int) float64 {
+	if secondTotalKlines <= 0 || t-secondTotalKlines+1 < 0 || t >= len(candles) {
+		return 0
+	}
+	return c.calculateCF(candles[t-secondTotalKlines+1:t+1], 0)
+}
+
 // calculateSecondTotalKlines calculates window size for SecondCF
 // TZ Section 7.1
 func (c *CFCalculator) calculateSecondTotalKlines(mainCFEntry float64, prevSecondTotalKlines int) int {
@@ -0,0 +1,76 @@
+package strategy
+
+import (
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// IndicatorPeriod is the ATR and ADX period the backtester trades on
+const IndicatorPeriod = 14
+
+// Series holds the indicators of a candle series; every slice is aligned with the candles
+type Series struct {
+	MainCF            []float64 `json:"main_cf"`
+	NewTotalKlines    []int     `json:"new_total_klines"` // Adaptive MainCF window, 0 before TotalKlines candles
+	SecondCF          []float64 `json:"second_cf"`
+	SecondTotalKlines []int     `json:"second_total_klines"` // SecondCF window, 0 where SecondCF is not computed
+	TrailingSecondCF  []float64 `json:"trailing_second_cf"`  // SecondCF over the window ending at the candle, for trailing stops
+	ATR               []float64 `json:"atr"`
+	ATRPercent        []float64 `json:"atr_percent"`
+	ADX               []float64 `json:"adx"`
+	PlusDI            []float64 `json:"plus_di"`
+	MinusDI           []float64 `json:"minus_di"`
+}
+
+// CalculateSeries computes the indicators the backtester trades on for candles
+// Values start from the first candle, so they match a backtest over the same candles
+func CalculateSeries(candles []*models.Candle, config *models.StrategyConfig) *Series {
+	n := len(candles)
+	series := &Series{
+		MainCF:            make([]float64, n),
+		NewTotalKlines:    make([]int, n),
+		SecondCF:          make([]float64, n),
+		SecondTotalKlines: make([]int, n),
+		TrailingSecondCF:  make([]float64, n),
+	}
+
+	cf := NewCFCalculator(config)
+	prevSecondTotalKlines := config.SecondTotalKlinesMin
+	prevMainCF := 0.0
+
+	for i := range candles {
+		if i < config.TotalKlines {
+			continue
+		}
+
+		// Calculate MainCF
+		series.MainCF[i], series.NewTotalKlines[i] = cf.CalculateMainCF(candles, i, prevMainCF)
+		prevMainCF = series.MainCF[i]
+
+		// Calculate SecondCF (for visualization; its window starts at the candle and looks ahead)
+		// and the trailing SecondCF over the same number of candles up to this one
+		if i > 0 && series.MainCF[i-1] != 0 {
+			series.SecondCF[i], prevSecondTotalKlines = cf.CalculateSecondCF(candles, i, series.MainCF[i-1], prevSecondTotalKlines)
+			series.SecondTotalKlines[i] = prevSecondTotalKlines
+			series.TrailingSecondCF[i] = cf.CalculateTrailingSecondCF(candles, i, prevSecondTotalKlines)
+		}
+	}
+
+	series.ATR = NewATRCalculator(IndicatorPeriod).Calculate(candles)
+	series.ATRPercent = CalculateATRPercent(candles, series.ATR)
+	series.ADX, series.PlusDI, series.MinusDI = NewADXCalculator(IndicatorPeriod).Calculate(candles)
+	return series
+}
+
+// Append adds the indicators of the following candles, such as the next session of a split series
+func (s *Series) Append(other *Series) {
+	s.MainCF = append(s.MainCF, other.MainCF...)
+	s.NewTotalKlines = append(s.NewTotalKlines, other.NewTotalKlines...)
+	s.SecondCF = append(s.SecondCF, other.SecondCF...)
+	s.SecondTotalKlines = append(s.SecondTotalKlines, other.SecondTotalKlines...)
+	s.TrailingSecondCF = append(s.TrailingSecondCF, other.TrailingSecondCF...)
+	s.ATR = append(s.ATR, other.ATR...)
+	s.ATRPercent = append(s.ATRPercent, other.ATRPercent...)
+	s.ADX = append(s.ADX, other.ADX...)
+	s.PlusDI = append(s.PlusDI, other.PlusDI...)
+	s.MinusDI = append(s.MinusDI, other.MinusDI...)
+}
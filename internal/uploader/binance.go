@@ -0,0 +1,275 @@
+package uploader
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Checksum states of an archive
+const (
+	ChecksumVerified = "VERIFIED" // Matches the .CHECKSUM file
+	ChecksumMissing  = "MISSING"  // No .CHECKSUM file next to the archive
+	ChecksumMismatch = "MISMATCH" // Differs from the .CHECKSUM file; the archive is not imported
+)
+
+// ErrChecksumMismatch is returned for archives whose SHA-256 differs from their .CHECKSUM file
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// archiveName matches data.binance.vision kline archives:
+// SYMBOL-INTERVAL-YYYY-MM.zip (monthly) and SYMBOL-INTERVAL-YYYY-MM-DD.zip (daily)
+var archiveName = regexp.MustCompile(`^([A-Z0-9]+)-(\d+(?:s|m|h|d|w|mo))-(\d{4}-\d{2}(?:-\d{2})?)\.zip$`)
+
+// ArchiveFileReport describes the import of one archive
+type ArchiveFileReport struct {
+	File         string        `json:"file"`
+	Symbol       string        `json:"symbol"`
+	Interval     string        `json:"interval"`
+	Period       string        `json:"period"` // YYYY-MM or YYYY-MM-DD
+	InstrumentID int64         `json:"instrument_id,omitempty"`
+	Checksum     string        `json:"checksum"`
+	Ingest       *IngestReport `json:"ingest,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// ArchiveReport summarizes the import of a directory of archives
+type ArchiveReport struct {
+	Files       []*ArchiveFileReport `json:"files"`
+	Skipped     []string             `json:"skipped,omitempty"` // Files in the directory that are not kline archives
+	Instruments []string             `json:"instruments_created,omitempty"`
+	Inserted    int                  `json:"inserted"`
+	Duplicates  int                  `json:"duplicates"`
+	Invalid     int                  `json:"invalid"`
+	Failed      int                  `json:"failed"`
+	Duration    time.Duration        `json:"duration_ns"`
+}
+
+// Summary returns a one-line description of the report
+func (r ArchiveReport) Summary() string {
+	return fmt.Sprintf("%d archives (%d failed): %d inserted, %d duplicate, %d invalid in %s",
+		len(r.Files), r.Failed, r.Inserted, r.Duplicates, r.Invalid, r.Duration.Round(time.Millisecond))
+}
+
+// ArchiveImporter imports data.binance.vision kline archives (zipped CSV) without unpacking them
+// Symbol and interval come from the file name; instruments are created on first use
+// Candles already stored are skipped, so archives can be imported again, and daily archives
+// can overlap monthly ones
+type ArchiveImporter struct {
+	db database.Store
+
+	// Exchange of the instruments (default BINANCE)
+	Exchange string
+
+	// RequireChecksum refuses archives without a .CHECKSUM file
+	RequireChecksum bool
+
+	// CSV configures the candle import of each archive; its Format is always binance
+	CSV *CSVUploader
+
+	// Progress, if set, is called after every archive
+	Progress func(file *ArchiveFileReport)
+}
+
+// NewArchiveImporter creates a new Binance archive importer
+func NewArchiveImporter(db database.Store) *ArchiveImporter {
+	return &ArchiveImporter{db: db, Exchange: "BINANCE", CSV: NewCSVUploader(db)}
+}
+
+// ImportDir imports every kline archive in dir in file name order
+// An archive that fails (bad checksum, unreadable zip) is recorded in the report and the
+// import continues; errors are returned only when the directory or store cannot be used
+func (a *ArchiveImporter) ImportDir(dir string) (*ArchiveReport, error) {
+	started := time.Now()
+	report := &ArchiveReport{}
+	defer func() { report.Duration = time.Since(started) }()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return report, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir() || strings.HasSuffix(name, ".CHECKSUM"):
+		case archiveName.MatchString(name):
+			names = append(names, name)
+		default:
+			report.Skipped = append(report.Skipped, name)
+		}
+	}
+	sort.Strings(names)
+
+	instruments := make(map[string]int64)
+	for _, name := range names {
+		file, err := a.importArchive(filepath.Join(dir, name), instruments, report)
+		if err != nil && file == nil {
+			return report, err
+		}
+		report.Files = append(report.Files, file)
+		if file.Error != "" {
+			report.Failed++
+		}
+		if file.Ingest != nil {
+			report.Inserted += file.Ingest.Inserted
+			report.Duplicates += file.Ingest.Duplicates
+			report.Invalid += file.Ingest.Invalid
+		}
+		if a.Progress != nil {
+			a.Progress(file)
+		}
+	}
+	return report, nil
+}
+
+// ImportFile imports a single kline archive
+func (a *ArchiveImporter) ImportFile(path string) (*ArchiveFileReport, error) {
+	file, err := a.importArchive(path, make(map[string]int64), &ArchiveReport{})
+	if err != nil {
+		return file, err
+	}
+	if file.Error != "" {
+		return file, errors.New(file.Error)
+	}
+	return file, nil
+}
+
+// importArchive verifies and imports one archive, caching instrument IDs by symbol
+// Problems with the archive itself are recorded in the file report; a nil file report
+// with an error means the store failed
+func (a *ArchiveImporter) importArchive(path string, instruments map[string]int64, report *ArchiveReport) (*ArchiveFileReport, error) {
+	name := filepath.Base(path)
+	match := archiveName.FindStringSubmatch(name)
+	if match == nil {
+		return nil, fmt.Errorf("%s is not a kline archive name (SYMBOL-INTERVAL-YYYY-MM[-DD].zip)", name)
+	}
+	file := &ArchiveFileReport{File: name, Symbol: match[1], Interval: archiveInterval(match[2]), Period: match[3]}
+
+	var err error
+	if file.Checksum, err = verifyChecksum(path); err != nil {
+		file.Error = err.Error()
+		return file, nil
+	}
+	if file.Checksum == ChecksumMissing && a.RequireChecksum {
+		file.Error = "no .CHECKSUM file"
+		return file, nil
+	}
+
+	instrumentID, ok := instruments[file.Symbol]
+	if !ok {
+		created := false
+		if instrumentID, created, err = a.instrument(file.Symbol); err != nil {
+			return nil, err
+		}
+		if created {
+			report.Instruments = append(report.Instruments, file.Symbol)
+		}
+		instruments[file.Symbol] = instrumentID
+	}
+	file.InstrumentID = instrumentID
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		file.Error = fmt.Sprintf("failed to open archive: %v", err)
+		return file, nil
+	}
+	defer archive.Close()
+
+	csvUploader := *a.CSV
+	csvUploader.Format, _ = FormatPreset("binance")
+
+	file.Ingest = &IngestReport{}
+	for _, entry := range archive.File {
+		if !strings.HasSuffix(strings.ToLower(entry.Name), ".csv") {
+			continue
+		}
+		ingest, err := csvUploader.upload(csvUploader.readCSV, entry.Open, name+"/"+entry.Name, instrumentID, file.Interval)
+		if ingest != nil {
+			file.Ingest.add(ingest)
+		}
+		if err != nil {
+			file.Error = err.Error()
+			return file, nil
+		}
+	}
+	return file, nil
+}
+
+// instrument finds the instrument of a symbol or creates it
+// Existing instruments are not saved again, so their kind and volatility bucket are kept
+func (a *ArchiveImporter) instrument(symbol string) (int64, bool, error) {
+	inst, err := a.db.GetInstrumentBySymbol(symbol, a.Exchange)
+	if err == nil {
+		return int64(inst.ID), false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, fmt.Errorf("failed to look up instrument %s: %w", symbol, err)
+	}
+
+	inst = &models.Instrument{Symbol: symbol, Exchange: a.Exchange}
+	if err := a.db.SaveInstrument(inst); err != nil {
+		return 0, false, fmt.Errorf("failed to create instrument %s: %w", symbol, err)
+	}
+	return int64(inst.ID), true, nil
+}
+
+// archiveInterval converts an interval from an archive name to the candle interval notation
+// Binance names monthly klines 1mo in file names and 1M in the API
+func archiveInterval(interval string) string {
+	if strings.HasSuffix(interval, "mo") {
+		return strings.TrimSuffix(interval, "mo") + "M"
+	}
+	return interval
+}
+
+// verifyChecksum compares the SHA-256 of an archive with its .CHECKSUM file
+// The file holds "<hex digest>  <file name>" as written by sha256sum
+func verifyChecksum(path string) (string, error) {
+	checksumFile, err := os.Open(path + ".CHECKSUM")
+	if errors.Is(err, os.ErrNotExist) {
+		return ChecksumMissing, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	defer checksumFile.Close()
+
+	line, err := bufio.NewReader(checksumFile).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read checksum: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	expected := strings.ToLower(fields[0])
+
+	archive, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archive.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, archive); err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return ChecksumMismatch, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	return ChecksumVerified, nil
+}
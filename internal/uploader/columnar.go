@@ -0,0 +1,506 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// Candle file formats
+const (
+	FileFormatCSV     = "csv"
+	FileFormatParquet = "parquet"
+	FileFormatArrow   = "arrow" // Arrow IPC file (Feather v2) or stream
+)
+
+// FileFormatOf returns the candle file format for a path by its extension, ignoring a
+// trailing .gz; unknown extensions are read as CSV
+func FileFormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(strings.ToLower(path), ".gz"))) {
+	case ".parquet", ".pq":
+		return FileFormatParquet
+	case ".arrow", ".feather", ".ipc", ".arrows":
+		return FileFormatArrow
+	default:
+		return FileFormatCSV
+	}
+}
+
+// UploadParquet uploads a Parquet file with candle data like UploadCSV
+// Columns are found by name (timestamp or time/date/open_time, open, high, low, close and
+// optionally volume) or mapped with Format.Columns; the timestamp column may be a
+// timestamp, date, integer or float (unit by Format.TimeUnit or magnitude) or text column
+func (u *CSVUploader) UploadParquet(filePath string, instrumentID int64, interval string) (*IngestReport, error) {
+	return u.upload(u.readParquet, openFile(filePath), filePath, instrumentID, interval)
+}
+
+// UploadArrow uploads an Arrow IPC file or stream with candle data like UploadParquet
+func (u *CSVUploader) UploadArrow(filePath string, instrumentID int64, interval string) (*IngestReport, error) {
+	return u.upload(u.readArrow, openFile(filePath), filePath, instrumentID, interval)
+}
+
+// readParquet reads the row groups of a Parquet file batch by batch
+func (u *CSVUploader) readParquet(open opener, name string, instrumentID int64, interval string, fn rowFunc) error {
+	f, err := open()
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	source, err := readerAt(f)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	pf, err := file.NewParquetReader(source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	defer pf.Close()
+
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: 64 * 1024}, memory.DefaultAllocator)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	records, err := fr.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	defer records.Release()
+
+	if err := u.readRecords(records, instrumentID, interval, fn); err != nil {
+		return err
+	}
+	if err := records.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// readArrow reads an Arrow IPC file, or an IPC stream if the data has no file footer
+func (u *CSVUploader) readArrow(open opener, name string, instrumentID int64, interval string, fn rowFunc) error {
+	f, err := open()
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	source, err := readerAt(f)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+
+	if fileReader, err := ipc.NewFileReader(source); err == nil {
+		defer fileReader.Close()
+		records := &ipcFileRecords{reader: fileReader}
+		if err := u.readRecords(records, instrumentID, interval, fn); err != nil {
+			return err
+		}
+		if records.err != nil {
+			return fmt.Errorf("error reading %s: %w", name, records.err)
+		}
+		return nil
+	}
+
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	streamReader, err := ipc.NewReader(source)
+	if err != nil {
+		return fmt.Errorf("error reading %s: not an Arrow IPC file or stream: %w", name, err)
+	}
+	defer streamReader.Release()
+
+	if err := u.readRecords(streamReader, instrumentID, interval, fn); err != nil {
+		return err
+	}
+	if err := streamReader.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// recordIterator yields record batches; Record is valid until the next call to Next
+type recordIterator interface {
+	Next() bool
+	Record() arrow.Record
+}
+
+// ipcFileRecords iterates the record batches of an Arrow IPC file
+type ipcFileRecords struct {
+	reader *ipc.FileReader
+	next   int
+	record arrow.Record
+	err    error
+}
+
+func (r *ipcFileRecords) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.err != nil || r.next >= r.reader.NumRecords() {
+		return false
+	}
+	r.record, r.err = r.reader.RecordAt(r.next)
+	r.next++
+	return r.err == nil
+}
+
+func (r *ipcFileRecords) Record() arrow.Record {
+	return r.record
+}
+
+// readSeekerAt is the random access both Parquet and Arrow IPC files need
+type readSeekerAt interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// readerAt returns f as a seekable reader, buffering it in memory if needed (zip entries)
+func readerAt(f io.Reader) (readSeekerAt, error) {
+	if ra, ok := f.(readSeekerAt); ok {
+		return ra, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// readRecords converts record batches to candles and passes every row to fn
+// Rows are numbered from 1 across batches; a row with a null or invalid value is rejected
+func (u *CSVUploader) readRecords(records recordIterator, instrumentID int64, interval string, fn rowFunc) error {
+	var columns ColumnMap
+	timeUnit := TimeUnitAuto
+	if u.Format != nil {
+		columns, timeUnit = u.Format.Columns, u.Format.TimeUnit
+	}
+
+	var cols *csvColumns
+	repaired := -1
+	row := 0
+	for records.Next() {
+		record := records.Record()
+		if cols == nil {
+			names := make([]string, record.NumCols())
+			for i, field := range record.Schema().Fields() {
+				names[i] = field.Name
+			}
+			resolved, err := resolveColumns(columns, names)
+			if err != nil {
+				return err
+			}
+			if err := checkColumnTypes(record.Schema(), resolved); err != nil {
+				return err
+			}
+			cols = &resolved
+
+			// Files written by ExportCandles carry the repair method of filled candles
+			if idx := record.Schema().FieldIndices("repaired"); len(idx) == 1 && record.Schema().Field(idx[0]).Type.ID() == arrow.STRING {
+				repaired = idx[0]
+			}
+		}
+
+		for i := 0; i < int(record.NumRows()); i++ {
+			row++
+			candle, err := recordCandle(record, *cols, i, timeUnit, instrumentID, interval)
+			var rejected *RejectedRow
+			if err != nil {
+				candle, rejected = nil, &RejectedRow{Line: row, Reason: err.Error()}
+			} else if repaired >= 0 {
+				candle.Repaired = record.Column(repaired).(*array.String).Value(i)
+			}
+			if err := fn(candle, rejected); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkColumnTypes verifies that the resolved columns hold timestamps and numbers
+func checkColumnTypes(schema *arrow.Schema, cols csvColumns) error {
+	check := func(field string, idx int, allowed ...arrow.Type) error {
+		if idx < 0 {
+			return nil
+		}
+		dataType := schema.Field(idx).Type
+		for _, id := range allowed {
+			if dataType.ID() == id {
+				return nil
+			}
+		}
+		return fmt.Errorf("column %q for %s has unsupported type %s", schema.Field(idx).Name, field, dataType)
+	}
+
+	numeric := []arrow.Type{arrow.FLOAT64, arrow.FLOAT32, arrow.INT64, arrow.INT32, arrow.UINT64, arrow.UINT32}
+	if err := check(FieldTimestamp, cols.timestamp, append(numeric, arrow.TIMESTAMP, arrow.DATE64, arrow.DATE32, arrow.STRING, arrow.LARGE_STRING)...); err != nil {
+		return err
+	}
+	for field, idx := range map[string]int{FieldOpen: cols.open, FieldHigh: cols.high, FieldLow: cols.low, FieldClose: cols.close, FieldVolume: cols.volume} {
+		if err := check(field, idx, numeric...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCandle converts row i of a record to a candle
+func recordCandle(record arrow.Record, cols csvColumns, i int, timeUnit string, instrumentID int64, interval string) (*models.Candle, error) {
+	timestamp, err := timestampValue(record.Column(cols.timestamp), i, timeUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	candle := &models.Candle{InstrumentID: instrumentID, Interval: interval, Timestamp: timestamp}
+	prices := []struct {
+		field string
+		idx   int
+		value *float64
+	}{
+		{FieldOpen, cols.open, &candle.Open},
+		{FieldHigh, cols.high, &candle.High},
+		{FieldLow, cols.low, &candle.Low},
+		{FieldClose, cols.close, &candle.Close},
+		{FieldVolume, cols.volume, &candle.Volume},
+	}
+	for _, p := range prices {
+		if p.idx < 0 {
+			continue
+		}
+		value, ok := numberValue(record.Column(p.idx), i)
+		if !ok {
+			return nil, fmt.Errorf("null %s", p.field)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("invalid %s: %g is not a finite number", p.field, value)
+		}
+		*p.value = value
+	}
+	return candle, nil
+}
+
+// numberValue returns row i of a numeric column; false if it is null
+func numberValue(column arrow.Array, i int) (float64, bool) {
+	if column.IsNull(i) {
+		return 0, false
+	}
+	switch c := column.(type) {
+	case *array.Float64:
+		return c.Value(i), true
+	case *array.Float32:
+		return float64(c.Value(i)), true
+	case *array.Int64:
+		return float64(c.Value(i)), true
+	case *array.Int32:
+		return float64(c.Value(i)), true
+	case *array.Uint64:
+		return float64(c.Value(i)), true
+	case *array.Uint32:
+		return float64(c.Value(i)), true
+	}
+	return 0, false
+}
+
+// timestampValue returns row i of a timestamp column in Unix milliseconds
+func timestampValue(column arrow.Array, i int, unit string) (int64, error) {
+	if column.IsNull(i) {
+		return 0, fmt.Errorf("null timestamp")
+	}
+	switch c := column.(type) {
+	case *array.Timestamp:
+		unit := c.DataType().(*arrow.TimestampType).Unit
+		return int64(c.Value(i)) * int64(unit.Multiplier()) / int64(time.Millisecond), nil
+	case *array.Date64:
+		return int64(c.Value(i)), nil
+	case *array.Date32:
+		return int64(c.Value(i)) * 24 * 60 * 60 * 1000, nil
+	case *array.Int64:
+		// Nanoseconds do not fit a float64 exactly
+		if v := c.Value(i); unit == TimeUnitAuto && (v >= 1e17 || v <= -1e17) {
+			return v / int64(time.Millisecond), nil
+		}
+		return numberToMillis(float64(c.Value(i)), unit)
+	case *array.String:
+		return parseTimestamp(c.Value(i), unit)
+	case *array.LargeString:
+		return parseTimestamp(c.Value(i), unit)
+	}
+	value, ok := numberValue(column, i)
+	if !ok {
+		return 0, fmt.Errorf("unsupported timestamp type %s", column.DataType())
+	}
+	return numberToMillis(value, unit)
+}
+
+// candleSchema is the layout of exported candles, readable by pandas and polars as is
+func candleSchema(metadata map[string]string) *arrow.Schema {
+	md := arrow.MetadataFrom(metadata)
+	return arrow.NewSchema([]arrow.Field{
+		{Name: FieldTimestamp, Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+		{Name: FieldOpen, Type: arrow.PrimitiveTypes.Float64},
+		{Name: FieldHigh, Type: arrow.PrimitiveTypes.Float64},
+		{Name: FieldLow, Type: arrow.PrimitiveTypes.Float64},
+		{Name: FieldClose, Type: arrow.PrimitiveTypes.Float64},
+		{Name: FieldVolume, Type: arrow.PrimitiveTypes.Float64},
+		{Name: "repaired", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, &md)
+}
+
+// candleRecord builds a record batch of candles
+func candleRecord(schema *arrow.Schema, candles []*models.Candle) arrow.Record {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+
+	timestamps := b.Field(0).(*array.TimestampBuilder)
+	open := b.Field(1).(*array.Float64Builder)
+	high := b.Field(2).(*array.Float64Builder)
+	low := b.Field(3).(*array.Float64Builder)
+	closes := b.Field(4).(*array.Float64Builder)
+	volume := b.Field(5).(*array.Float64Builder)
+	repaired := b.Field(6).(*array.StringBuilder)
+	b.Reserve(len(candles))
+
+	for _, c := range candles {
+		timestamps.Append(arrow.Timestamp(c.Timestamp))
+		open.Append(c.Open)
+		high.Append(c.High)
+		low.Append(c.Low)
+		closes.Append(c.Close)
+		volume.Append(c.Volume)
+		if c.Repaired == "" {
+			repaired.AppendNull()
+		} else {
+			repaired.Append(c.Repaired)
+		}
+	}
+	return b.NewRecord()
+}
+
+// recordWriter writes record batches to a columnar file
+type recordWriter interface {
+	Write(record arrow.Record) error
+	Close() error
+}
+
+// newRecordWriter creates a writer for format on w
+// Parquet files are zstd-compressed with one row group per batch
+func newRecordWriter(format string, w io.WriteSeeker, schema *arrow.Schema) (recordWriter, error) {
+	switch format {
+	case FileFormatParquet:
+		props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Zstd))
+		return pqarrow.NewFileWriter(schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	case FileFormatArrow:
+		return ipc.NewFileWriter(w, ipc.WithSchema(schema), ipc.WithZstd())
+	default:
+		return nil, fmt.Errorf("unsupported export format %q (expected parquet or arrow)", format)
+	}
+}
+
+// exportBars is the number of candles read and written per batch when exporting
+const exportBars = 100000
+
+// ExportCandles writes the candles of an instrument and interval within a time range to
+// filePath as Parquet or Arrow IPC, chosen by the extension, and returns the number written
+// Timestamps are UTC milliseconds and prices keep full float64 precision; the file metadata
+// holds the symbol, exchange and interval
+func ExportCandles(db database.Store, filePath string, inst *models.Instrument, interval string, startTime, endTime time.Time) (n int, err error) {
+	format := FileFormatOf(filePath)
+	if format == FileFormatCSV {
+		return 0, fmt.Errorf("unsupported export format for %s (use .parquet or .arrow)", filePath)
+	}
+
+	// Clamp the range to the stored candles, so windows are not spent on empty years
+	start, end := startTime.UnixMilli(), endTime.UnixMilli()
+	series, err := db.GetCandleSeries(int64(inst.ID))
+	if err != nil {
+		return 0, err
+	}
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, s := range series {
+		if s.Interval == interval {
+			first, last = s.First, s.Last
+		}
+	}
+	start, end = max(start, first), min(end, last)
+
+	out, err := os.Create(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", filePath, err)
+	}
+	defer func() {
+		// The Parquet writer closes the file itself
+		if closeErr := out.Close(); err == nil && closeErr != nil && !errors.Is(closeErr, os.ErrClosed) {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(filePath)
+		}
+	}()
+
+	schema := candleSchema(map[string]string{
+		"symbol":   inst.Symbol,
+		"exchange": inst.Exchange,
+		"interval": interval,
+	})
+	writer, err := newRecordWriter(format, out, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	window := int64(math.MaxInt64)
+	if step, err := models.IntervalMillis(interval); err == nil {
+		window = step * exportBars
+	}
+	for from := start; from <= end; {
+		to := end
+		if end-from >= window {
+			to = from + window - 1
+		}
+		candles, err := db.GetCandlesByTimeRange(int64(inst.ID), interval, time.UnixMilli(from), time.UnixMilli(to))
+		if err != nil {
+			writer.Close()
+			return n, err
+		}
+		if len(candles) > 0 {
+			record := candleRecord(schema, candles)
+			err := writer.Write(record)
+			record.Release()
+			if err != nil {
+				writer.Close()
+				return n, fmt.Errorf("failed to write %s: %w", filePath, err)
+			}
+			n += len(candles)
+		}
+		if to == end {
+			break
+		}
+		from = to + 1
+	}
+
+	if err := writer.Close(); err != nil {
+		return n, fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	return n, nil
+}
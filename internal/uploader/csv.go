@@ -1,129 +1,337 @@
 package uploader
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 	
 	"github.com/langley-creator/cf-backtester/internal/database"
 	"github.com/langley-creator/cf-backtester/internal/models"
+	"github.com/langley-creator/cf-backtester/internal/quality"
 )
 
-// CSVUploader handles CSV file uploads
+// DefaultBatchSize is the number of candles written per transaction
+const DefaultBatchSize = 50000
+
+// CSVUploader handles candle file uploads: CSV, Parquet and Arrow IPC
 type CSVUploader struct {
-	db *database.PostgresDB
+	db database.Store
+
+	// BatchSize is the number of candles written per transaction
+	BatchSize int
+
+	// MaxRejected limits the rejected rows listed in the report; all are counted
+	MaxRejected int
+
+	// Strict aborts the import on the first invalid row; the file is validated
+	// before anything is written, so a failed strict import leaves the store unchanged
+	Strict bool
+
+	// Progress, if set, is called after every committed batch
+	Progress func(report IngestReport)
+
+	// Format describes the CSV layout; nil detects everything from the file
+	Format *CSVFormat
+
+	// Repair fills gaps between consecutive rows with synthetic candles
+	// (quality.RepairForwardFill or quality.RepairInterpolate; empty or RepairNone keeps gaps)
+	// Only gaps inside the file are filled, not gaps to candles stored earlier
+	Repair string
+
+	// meter counts the bytes read for the report, set by UploadFile
+	meter *meter
 }
 
 // NewCSVUploader creates a new CSV uploader
-func NewCSVUploader(db *database.PostgresDB) *CSVUploader {
-	return &CSVUploader{db: db}
+func NewCSVUploader(db database.Store) *CSVUploader {
+	return &CSVUploader{db: db, BatchSize: DefaultBatchSize, MaxRejected: DefaultMaxRejected}
 }
 
-// UploadCSV parses and uploads CSV file with candle data
-// Expected CSV format: timestamp,open,high,low,close,volume,...
+// rowFunc receives each data row of a CSV file with either its candle or the reason it was rejected
+// Returning an error stops reading
+type rowFunc func(candle *models.Candle, rejected *RejectedRow) error
+
+// UploadCSV parses and uploads CSV file with candle data for an instrument and interval
+// Candles are written in batches of BatchSize, each in its own transaction, so a failed
+// import keeps every batch committed before the error
+// Invalid rows are skipped and listed in the report, or abort the import in Strict mode
+// with a *RowError
+// With Repair set, gaps between consecutive rows are filled as the file is read, so the
+// file must be sorted by open time for every gap to be found
+// The delimiter, header row, columns and timestamp unit are taken from Format where set
+// and detected otherwise; headerless files default to timestamp,open,high,low,close,volume
 // Example: 1711929600000,128.32,128.32,128.07,128.09,223.266,...
-func (u *CSVUploader) UploadCSV(filePath string, symbol string, interval string) (int, error) {
-	file, err := os.Open(filePath)
+func (u *CSVUploader) UploadCSV(filePath string, instrumentID int64, interval string) (*IngestReport, error) {
+	return u.upload(u.readCSV, openFile(filePath), filePath, instrumentID, interval)
+}
+
+// opener opens the data for one pass over it
+type opener func() (io.ReadCloser, error)
+
+// openFile returns an opener for a file
+func openFile(path string) opener {
+	return func() (io.ReadCloser, error) { return os.Open(path) }
+}
+
+// rowReader reads candle data from open and passes every data row to fn
+type rowReader func(open opener, name string, instrumentID int64, interval string, fn rowFunc) error
+
+// upload imports the data returned by open with read as described for UploadCSV
+// name identifies the data in errors
+func (u *CSVUploader) upload(read rowReader, open opener, name string, instrumentID int64, interval string) (report *IngestReport, err error) {
+	started := time.Now()
+	report = &IngestReport{}
+	defer func() {
+		report.Duration = time.Since(started)
+		u.measure(report)
+	}()
+
+	maxRejected := u.MaxRejected
+	if maxRejected <= 0 {
+		maxRejected = DefaultMaxRejected
+	}
+
+	var step int64
+	switch u.Repair {
+	case "", quality.RepairNone:
+	case quality.RepairForwardFill, quality.RepairInterpolate:
+		if step, err = models.IntervalMillis(interval); err != nil {
+			return report, fmt.Errorf("cannot repair gaps: %w", err)
+		}
+	default:
+		return report, fmt.Errorf("gap repair %q is not supported at import (use FFILL or INTERPOLATE)", u.Repair)
+	}
+
+	if u.Strict {
+		err := read(open, name, instrumentID, interval, func(candle *models.Candle, rejected *RejectedRow) error {
+			if rejected != nil {
+				report.reject(*rejected, maxRejected)
+				return &RowError{*rejected}
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	batchSize := u.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	batch := make([]*models.Candle, 0, batchSize)
+	filled := 0 // synthetic candles in batch
+	var prev *models.Candle
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inserted, err := u.db.SaveCandles(batch)
+		if err != nil {
+			return fmt.Errorf("failed to save batch of %d candles after row %d: %w", len(batch), report.Rows, err)
+		}
+		report.Rows += len(batch) - filled
+		report.Repaired += filled
+		filled = 0
+		report.Inserted += inserted
+		report.Duplicates += len(batch) - inserted
+		report.Batches++
+		report.Duration = time.Since(started)
+		u.measure(report)
+		batch = batch[:0]
+		if u.Progress != nil {
+			u.Progress(*report)
+		}
+		return nil
+	}
+
+	err = read(open, name, instrumentID, interval, func(candle *models.Candle, rejected *RejectedRow) error {
+		if rejected != nil {
+			report.reject(*rejected, maxRejected)
+			return nil
+		}
+		if step > 0 && prev != nil {
+			gap := quality.FillGap(prev, candle, step, u.Repair)
+			batch = append(batch, gap...)
+			filled += len(gap)
+		}
+		if prev == nil || candle.Timestamp > prev.Timestamp {
+			prev = candle
+		}
+		batch = append(batch, candle)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, flush()
+}
+
+// measure records the bytes read so far in report
+func (u *CSVUploader) measure(report *IngestReport) {
+	if u.meter != nil {
+		report.BytesRead, report.BytesTotal = u.meter.read, u.meter.total
+	}
+}
+
+// readCSV parses candle CSV data and passes every data row to fn
+// Errors that prevent reading the data at all (missing file, unmappable header) are returned
+func (u *CSVUploader) readCSV(open opener, name string, instrumentID int64, interval string, fn rowFunc) error {
+	file, err := open()
 	if err != nil {
-		return 0, fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 	
-	reader := csv.NewReader(file)
-	reader.Comma = ','
+	format := CSVFormat{Name: "generic"}
+	if u.Format != nil {
+		format = *u.Format
+	}
+
+	buffered := bufio.NewReaderSize(file, 64*1024)
+	if format.Delimiter == 0 {
+		// Peek returns what is available when the file is shorter than the buffer
+		head, _ := buffered.Peek(64 * 1024)
+		if i := bytes.IndexByte(head, '\n'); i >= 0 {
+			head = head[:i]
+		}
+		format.Delimiter = detectDelimiter(string(head))
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = format.Delimiter
 	reader.TrimLeadingSpace = true
-	
-	count := 0
-	lineNum := 0
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	var cols *csvColumns
+	var header []string
 	
 	for {
-		lineNum++
 		record, err := reader.Read()
 		if err == io.EOF {
-			break
+			return nil
+		}
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			if err := fn(nil, &RejectedRow{Line: parseErr.Line, Reason: parseErr.Err.Error()}); err != nil {
+				return err
+			}
+			continue
 		}
 		if err != nil {
-			return count, fmt.Errorf("error reading line %d: %w", lineNum, err)
+			return fmt.Errorf("error reading %s: %w", name, err)
 		}
+		line, _ := reader.FieldPos(0)
 		
-		// Skip empty lines or lines with dashes (separators)
-		if len(record) == 0 || strings.HasPrefix(record[0], "-") {
+		// Skip comments, separator lines and repeats of the header; anything else is a data row
+		if len(record) == 0 || isComment(record) || isSeparator(record) || (header != nil && sameFields(record, header)) {
 			continue
 		}
-		
-		// Need at least 6 fields: timestamp, open, high, low, close, volume
-		if len(record) < 6 {
-			continue
+
+		// The first record decides whether the file has a header
+		if cols == nil {
+			if isHeader(record) {
+				header = append([]string(nil), record...)
+			}
+			resolved, err := resolveColumns(format.Columns, header)
+			if err != nil {
+				return err
+			}
+			cols = &resolved
+			if header != nil {
+				continue
+			}
 		}
-		
-		// Parse candle data
-		candle, err := u.parseCandle(record, symbol, interval)
-		if err != nil {
-			// Skip invalid lines but continue processing
-			continue
+
+		var candle *models.Candle
+		var rejected *RejectedRow
+		if len(record) < cols.required() {
+			rejected = &RejectedRow{Line: line, Reason: fmt.Sprintf("expected at least %d fields, got %d", cols.required(), len(record))}
+		} else if candle, err = u.parseCandle(record, *cols, format.TimeUnit, instrumentID, interval); err != nil {
+			rejected = &RejectedRow{Line: line, Reason: err.Error()}
 		}
-		
-		// Insert into database (ON CONFLICT DO NOTHING)
-		err = u.db.InsertCandle(candle)
-		if err != nil {
-			// Log but continue
-			continue
+		if rejected != nil {
+			rejected.Content = rowContent(record, format.Delimiter)
+		}
+
+		if err := fn(candle, rejected); err != nil {
+			return err
 		}
-		
-		count++
 	}
-	
-	return count, nil
 }
 
 // parseCandle parses a CSV record into a Candle model
-func (u *CSVUploader) parseCandle(record []string, symbol string, interval string) (*models.Candle, error) {
-	// Parse timestamp (milliseconds)
-	timestampMs, err := strconv.ParseInt(record[0], 10, 64)
+func (u *CSVUploader) parseCandle(record []string, cols csvColumns, timeUnit string, instrumentID int64, interval string) (*models.Candle, error) {
+	// Parse timestamp (converted to milliseconds)
+	timestamp, err := parseTimestamp(record[cols.timestamp], timeUnit)
 	if err != nil {
-		return nil, fmt.Errorf("invalid timestamp: %w", err)
+		return nil, err
+	}
+	if timestamp < 0 {
+		return nil, fmt.Errorf("timestamp %q is before 1970", strings.TrimSpace(record[cols.timestamp]))
 	}
-	
-	// Convert to seconds
-	timestamp := timestampMs / 1000
 	
 	// Parse OHLCV
-	open, err := strconv.ParseFloat(record[1], 64)
+	open, err := parsePrice(record[cols.open])
 	if err != nil {
 		return nil, fmt.Errorf("invalid open: %w", err)
 	}
 	
-	high, err := strconv.ParseFloat(record[2], 64)
+	high, err := parsePrice(record[cols.high])
 	if err != nil {
 		return nil, fmt.Errorf("invalid high: %w", err)
 	}
 	
-	low, err := strconv.ParseFloat(record[3], 64)
+	low, err := parsePrice(record[cols.low])
 	if err != nil {
 		return nil, fmt.Errorf("invalid low: %w", err)
 	}
 	
-	close, err := strconv.ParseFloat(record[4], 64)
+	close, err := parsePrice(record[cols.close])
 	if err != nil {
 		return nil, fmt.Errorf("invalid close: %w", err)
 	}
 	
-	volume, err := strconv.ParseFloat(record[5], 64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid volume: %w", err)
+	volume := 0.0
+	if cols.volume >= 0 {
+		volume, err = parsePrice(record[cols.volume])
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume: %w", err)
+		}
 	}
 	
 	return &models.Candle{
-		Symbol:    symbol,
-		Interval:  interval,
-		Timestamp: timestamp,
-		Open:      open,
-		High:      high,
-		Low:       low,
-		Close:     close,
-		Volume:    volume,
+		InstrumentID: instrumentID,
+		Interval:     interval,
+		Timestamp:    timestamp,
+		Open:         open,
+		High:         high,
+		Low:          low,
+		Close:        close,
+		Volume:       volume,
 	}, nil
 }
+
+// parsePrice parses a finite numeric field, ignoring surrounding spaces
+func parsePrice(value string) (float64, error) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", value)
+	}
+	return number, nil
+}
@@ -0,0 +1,56 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+)
+
+// TestUploadCSVReportsRejectedRows checks that only comments, separators and repeated headers
+// are skipped, and every other unusable row is counted and listed in the report
+func TestUploadCSVReportsRejectedRows(t *testing.T) {
+	data := strings.Join([]string{
+		"timestamp,open,high,low,close,volume",
+		"# exported from the exchange",
+		"-----,-----,-----,-----,-----,-----",
+		"1700000000000,1,2,0.5,1.5,10",
+		"-1700003600000,1,2,0.5,1.5,10",
+		"-x,1,2,0.5,1.5,10",
+		"timestamp,open,high,low,close,volume",
+		"1700003600000,1.5,2,1,1.75,10",
+		"1700007200000,abc,2,1,1.75,10",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "candles.csv")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := NewCSVUploader(database.NewMemoryStore()).UploadCSV(path, 1, "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 2 || report.Inserted != 2 {
+		t.Errorf("%d rows read and %d inserted, want 2 and 2", report.Rows, report.Inserted)
+	}
+
+	want := []struct {
+		line   int
+		reason string
+	}{
+		{5, "before 1970"},
+		{6, `invalid timestamp "-x"`},
+		{9, "invalid open"},
+	}
+	if report.Invalid != len(want) || len(report.Rejected) != len(want) {
+		t.Fatalf("%d invalid rows, %d listed: %+v, want %d", report.Invalid, len(report.Rejected), report.Rejected, len(want))
+	}
+	for i, w := range want {
+		row := report.Rejected[i]
+		if row.Line != w.line || !strings.Contains(row.Reason, w.reason) || row.Content == "" {
+			t.Errorf("rejected %+v, want line %d with %q", row, w.line, w.reason)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package uploader
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// UploadFile uploads a candle file in the format given by its extension: CSV, Parquet or
+// Arrow IPC, each optionally gzip-compressed (.csv.gz), or a zip archive of such files
+// A zip archive is imported entry by entry into one combined report; other entries are skipped
+// The report and Progress track the bytes of the file read so far
+func (u *CSVUploader) UploadFile(filePath string, instrumentID int64, interval string) (*IngestReport, error) {
+	metered := *u
+	metered.meter = &meter{path: filePath}
+	if info, err := os.Stat(filePath); err == nil {
+		metered.meter.total = info.Size()
+	}
+
+	if strings.EqualFold(path.Ext(filePath), ".zip") {
+		return metered.uploadZip(filePath, instrumentID, interval)
+	}
+	open := opener(metered.meter.open)
+	if strings.EqualFold(path.Ext(filePath), ".gz") {
+		open = gunzip(open)
+	}
+	return metered.upload(metered.readerFor(filePath), open, filePath, instrumentID, interval)
+}
+
+// uploadZip imports every candle file in a zip archive
+func (u *CSVUploader) uploadZip(filePath string, instrumentID int64, interval string) (*IngestReport, error) {
+	report := &IngestReport{}
+	file, err := u.meter.open()
+	if err != nil {
+		return report, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file.(io.ReaderAt), u.meter.total)
+	if err != nil {
+		return report, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	entryUploader := *u
+	found := false
+	for _, entry := range archive.File {
+		if !isCandleFile(entry.Name) {
+			continue
+		}
+		found = true
+
+		// Progress reports cover the entries imported before this one
+		if u.Progress != nil {
+			done := *report
+			entryUploader.Progress = func(progress IngestReport) {
+				total := done
+				total.add(&progress)
+				u.Progress(total)
+			}
+		}
+
+		open := opener(entry.Open)
+		if strings.EqualFold(path.Ext(entry.Name), ".gz") {
+			open = gunzip(open)
+		}
+		ingest, err := entryUploader.upload(entryUploader.readerFor(entry.Name), open, filePath+"/"+entry.Name, instrumentID, interval)
+		if ingest != nil {
+			report.add(ingest)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	if !found {
+		return report, fmt.Errorf("no candle files (.csv, .parquet, .arrow) in %s", filePath)
+	}
+	return report, nil
+}
+
+// readerFor returns the row reader for a file name
+func (u *CSVUploader) readerFor(name string) rowReader {
+	switch FileFormatOf(name) {
+	case FileFormatParquet:
+		return u.readParquet
+	case FileFormatArrow:
+		return u.readArrow
+	default:
+		return u.readCSV
+	}
+}
+
+// isCandleFile reports whether a zip entry holds candles, leaving out directories,
+// macOS resource forks and files such as checksums or notes
+func isCandleFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+		return false
+	}
+	if FileFormatOf(name) != FileFormatCSV {
+		return true
+	}
+	ext := strings.ToLower(path.Ext(strings.TrimSuffix(strings.ToLower(name), ".gz")))
+	return ext == ".csv" || ext == ".txt"
+}
+
+// gunzip returns an opener that decompresses the data of open
+func gunzip(open opener) opener {
+	return func() (io.ReadCloser, error) {
+		file, err := open()
+		if err != nil {
+			return nil, err
+		}
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("not a gzip file: %w", err)
+		}
+		return &gzipFile{Reader: reader, file: file}, nil
+	}
+}
+
+// gzipFile closes the compressed file along with its reader
+type gzipFile struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// meter counts the bytes read from a file for progress reports
+// Random access reads (Parquet footers, zip directories) are counted too, so the count is
+// approximate; it restarts whenever the file is opened
+type meter struct {
+	path  string
+	total int64
+	read  int64
+}
+
+// open opens the file and restarts the count
+func (m *meter) open() (io.ReadCloser, error) {
+	file, err := os.Open(m.path)
+	if err != nil {
+		return nil, err
+	}
+	m.read = 0
+	return &meteredFile{file: file, meter: m}, nil
+}
+
+// meteredFile counts the bytes read from a file into its meter
+type meteredFile struct {
+	file  *os.File
+	meter *meter
+}
+
+func (f *meteredFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	f.meter.read += int64(n)
+	return n, err
+}
+
+func (f *meteredFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.file.ReadAt(p, off)
+	f.meter.read += int64(n)
+	return n, err
+}
+
+func (f *meteredFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+func (f *meteredFile) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,392 @@
+package uploader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp units of a CSV file
+const (
+	TimeUnitAuto    = "" // Detect per value: ISO-8601 text or Unix time by magnitude
+	TimeUnitSeconds = "s"
+	TimeUnitMillis  = "ms"
+	TimeUnitMicros  = "us"
+	TimeUnitISO     = "iso"
+)
+
+// Candle fields that can be mapped to CSV columns
+const (
+	FieldTimestamp = "timestamp"
+	FieldOpen      = "open"
+	FieldHigh      = "high"
+	FieldLow       = "low"
+	FieldClose     = "close"
+	FieldVolume    = "volume"
+)
+
+// candleFields lists mappable fields in positional order
+var candleFields = []string{FieldTimestamp, FieldOpen, FieldHigh, FieldLow, FieldClose, FieldVolume}
+
+// ColumnMap maps candle fields to CSV columns by header name or 0-based index
+// Example: {"timestamp": "Date", "volume": "5"}
+type ColumnMap map[string]string
+
+// CSVFormat describes the layout of a candle CSV file
+// Zero values are detected from the file
+type CSVFormat struct {
+	Name      string    `json:"name"`
+	Delimiter rune      `json:"delimiter,omitempty"` // 0 = detect from the first line
+	TimeUnit  string    `json:"time_unit,omitempty"` // s, ms, us, iso or empty to detect
+	Columns   ColumnMap `json:"columns,omitempty"`   // Unmapped fields are found by header name or position
+}
+
+// Built-in formats
+var formatPresets = map[string]CSVFormat{
+	// Any delimiter, header names matched against common synonyms, headerless files positional
+	"generic": {Name: "generic"},
+
+	// data.binance.vision kline dumps: open_time,open,high,low,close,volume,close_time,...
+	// Older dumps have no header and millisecond times; newer spot dumps use microseconds
+	"binance": {
+		Name:      "binance",
+		Delimiter: ',',
+		Columns: ColumnMap{
+			FieldTimestamp: "0", FieldOpen: "1", FieldHigh: "2",
+			FieldLow: "3", FieldClose: "4", FieldVolume: "5",
+		},
+	},
+
+	// TradingView "Export chart data": time,open,high,low,close[,Volume,...]
+	// time is Unix seconds or ISO-8601 depending on the export setting; volume is only
+	// exported when the Volume indicator is on the chart
+	"tradingview": {
+		Name:      "tradingview",
+		Delimiter: ',',
+		Columns: ColumnMap{
+			FieldTimestamp: "time", FieldOpen: "open", FieldHigh: "high",
+			FieldLow: "low", FieldClose: "close",
+		},
+	},
+}
+
+// FormatPreset returns a built-in format by name
+func FormatPreset(name string) (*CSVFormat, error) {
+	preset, ok := formatPresets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown CSV format %q (available: %s)", name, strings.Join(FormatPresetNames(), ", "))
+	}
+	format := preset
+	format.Columns = make(ColumnMap, len(preset.Columns))
+	for field, column := range preset.Columns {
+		format.Columns[field] = column
+	}
+	return &format, nil
+}
+
+// ParseFormat builds a format from a preset name and optional overrides as given on
+// the command line or in query parameters; empty overrides keep the preset's values
+func ParseFormat(preset, columns, delimiter, timeUnit string) (*CSVFormat, error) {
+	if preset == "" {
+		preset = "generic"
+	}
+	format, err := FormatPreset(preset)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := ParseColumnMap(columns)
+	if err != nil {
+		return nil, err
+	}
+	for field, column := range mapping {
+		format.Columns[field] = column
+	}
+
+	if delimiter != "" {
+		if format.Delimiter, err = ParseDelimiter(delimiter); err != nil {
+			return nil, err
+		}
+	}
+
+	switch timeUnit {
+	case TimeUnitAuto:
+	case TimeUnitSeconds, TimeUnitMillis, TimeUnitMicros, TimeUnitISO:
+		format.TimeUnit = timeUnit
+	default:
+		return nil, fmt.Errorf("invalid time unit %q (expected s, ms, us or iso)", timeUnit)
+	}
+
+	return format, nil
+}
+
+// FormatPresetNames returns the names of the built-in formats
+func FormatPresetNames() []string {
+	names := make([]string, 0, len(formatPresets))
+	for name := range formatPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColumnMap parses a mapping like "timestamp=Date,volume=5"
+func ParseColumnMap(s string) (ColumnMap, error) {
+	columns := make(ColumnMap)
+	if strings.TrimSpace(s) == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		column = strings.TrimSpace(column)
+		if !ok || column == "" || !isCandleField(field) {
+			return nil, fmt.Errorf("invalid column mapping %q (expected field=column with field one of %s)",
+				pair, strings.Join(candleFields, ", "))
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// ParseDelimiter parses a delimiter flag value: a single character or tab, comma, semicolon, pipe
+func ParseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return runes[0], nil
+}
+
+// isCandleField reports whether field is a mappable candle field
+func isCandleField(field string) bool {
+	for _, f := range candleFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Header names recognised for each field, compared after lowercasing and trimming
+var fieldHeaders = map[string][]string{
+	FieldTimestamp: {"timestamp", "time", "open_time", "opentime", "open time", "date", "datetime", "date/time", "ts", "unix"},
+	FieldOpen:      {"open", "o", "open_price"},
+	FieldHigh:      {"high", "h", "high_price"},
+	FieldLow:       {"low", "l", "low_price"},
+	FieldClose:     {"close", "c", "close_price"},
+	FieldVolume:    {"volume", "vol", "v", "base_volume", "volume_base"},
+}
+
+// csvColumns holds resolved column indices (-1 if absent)
+type csvColumns struct {
+	timestamp, open, high, low, close, volume int
+}
+
+// required returns the number of fields a record needs
+func (c csvColumns) required() int {
+	n := 0
+	for _, idx := range []int{c.timestamp, c.open, c.high, c.low, c.close, c.volume} {
+		if idx+1 > n {
+			n = idx + 1
+		}
+	}
+	return n
+}
+
+// resolveColumns maps candle fields to column indices
+// With a header, mapped names and known synonyms are looked up; without one, mapped
+// indices are used and unmapped fields fall back to timestamp,open,high,low,close,volume
+// Volume is optional and read as 0 when no column matches
+func resolveColumns(mapping ColumnMap, header []string) (csvColumns, error) {
+	names := make(map[string]int, len(header))
+	for i, name := range header {
+		name = normalizeHeader(name)
+		if _, seen := names[name]; !seen {
+			names[name] = i
+		}
+	}
+
+	indices := make(map[string]int, len(candleFields))
+	for pos, field := range candleFields {
+		idx := -1
+		if column, ok := mapping[field]; ok {
+			if n, err := strconv.Atoi(column); err == nil && n >= 0 {
+				idx = n
+			} else if i, ok := names[normalizeHeader(column)]; ok {
+				idx = i
+			} else {
+				return csvColumns{}, fmt.Errorf("column %q for %s not found in header %v", column, field, header)
+			}
+		} else if header != nil {
+			for _, synonym := range fieldHeaders[field] {
+				if i, ok := names[synonym]; ok {
+					idx = i
+					break
+				}
+			}
+		} else {
+			idx = pos
+		}
+
+		if idx < 0 && field != FieldVolume {
+			return csvColumns{}, fmt.Errorf("no %s column in header %v (map it explicitly)", field, header)
+		}
+		indices[field] = idx
+	}
+
+	return csvColumns{
+		timestamp: indices[FieldTimestamp],
+		open:      indices[FieldOpen],
+		high:      indices[FieldHigh],
+		low:       indices[FieldLow],
+		close:     indices[FieldClose],
+		volume:    indices[FieldVolume],
+	}, nil
+}
+
+// normalizeHeader lowercases a header name and strips quotes, spaces and a UTF-8 BOM
+func normalizeHeader(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ToLower(strings.Trim(name, " \t\"'"))
+}
+
+// isHeader reports whether the first record is a header row: no field is numeric
+func isHeader(record []string) bool {
+	for _, field := range record {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isComment reports whether record is a comment line starting with #
+func isComment(record []string) bool {
+	return strings.HasPrefix(strings.TrimSpace(record[0]), "#")
+}
+
+// isSeparator reports whether record only holds rule characters, as in ----- or ==|==
+func isSeparator(record []string) bool {
+	var rule bool
+	for _, field := range record {
+		for _, r := range field {
+			switch r {
+			case '-', '=', '+':
+				rule = true
+			case ' ', '\t':
+			default:
+				return false
+			}
+		}
+	}
+	return rule
+}
+
+// sameFields reports whether record repeats header, ignoring case and surrounding spaces
+func sameFields(record, header []string) bool {
+	if len(record) != len(header) {
+		return false
+	}
+	for i := range record {
+		if !strings.EqualFold(strings.TrimSpace(record[i]), strings.TrimSpace(header[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectDelimiter picks the most frequent candidate delimiter in line (',' if none)
+func detectDelimiter(line string) rune {
+	best, bestCount := ',', 0
+	for _, candidate := range []rune{',', ';', '\t', '|'} {
+		if count := strings.Count(line, string(candidate)); count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+	return best
+}
+
+// ISO-8601 layouts accepted for text timestamps; layouts without a zone are UTC
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestamp converts a timestamp field to Unix milliseconds
+// With TimeUnitAuto, numbers are classified by magnitude: below 1e11 seconds,
+// below 1e14 milliseconds, below 1e17 microseconds, nanoseconds above
+func parseTimestamp(value, unit string) (int64, error) {
+	value = strings.TrimSpace(value)
+
+	if unit == TimeUnitISO {
+		return parseISOTimestamp(value)
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		if unit == TimeUnitAuto {
+			return parseISOTimestamp(value)
+		}
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return numberToMillis(number, unit)
+}
+
+// numberToMillis converts a numeric timestamp in unit to Unix milliseconds
+// With TimeUnitAuto the unit is taken from the magnitude as described for parseTimestamp
+func numberToMillis(number float64, unit string) (int64, error) {
+	if unit == TimeUnitAuto {
+		switch abs := math.Abs(number); {
+		case abs < 1e11:
+			unit = TimeUnitSeconds
+		case abs < 1e14:
+			unit = TimeUnitMillis
+		case abs < 1e17:
+			unit = TimeUnitMicros
+		default:
+			return int64(number / 1e6), nil
+		}
+	}
+
+	switch unit {
+	case TimeUnitSeconds:
+		return int64(math.Round(number * 1000)), nil
+	case TimeUnitMillis:
+		return int64(number), nil
+	case TimeUnitMicros:
+		return int64(number / 1000), nil
+	default:
+		return 0, fmt.Errorf("unknown time unit %q", unit)
+	}
+}
+
+// parseISOTimestamp parses an ISO-8601 date or date-time into Unix milliseconds
+func parseISOTimestamp(value string) (int64, error) {
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,163 @@
+package uploader
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/langley-creator/cf-backtester/internal/database"
+	"github.com/langley-creator/cf-backtester/internal/models"
+)
+
+// FundingUploader handles funding rate CSV uploads
+type FundingUploader struct {
+	db database.Store
+}
+
+// NewFundingUploader creates a new funding rate uploader
+func NewFundingUploader(db database.Store) *FundingUploader {
+	return &FundingUploader{db: db}
+}
+
+// fundingColumns maps CSV columns to funding fields
+type fundingColumns struct {
+	timestamp int
+	rate      int
+	markPrice int // -1 if absent
+}
+
+// Known header names for each column
+var (
+	fundingTimestampHeaders = []string{"calc_time", "fundingtime", "funding_time", "timestamp", "time"}
+	fundingRateHeaders      = []string{"last_funding_rate", "fundingrate", "funding_rate", "rate"}
+	fundingMarkHeaders      = []string{"markprice", "mark_price"}
+)
+
+// UploadFundingCSV parses and uploads a CSV file with funding rates for an instrument
+// Supported formats:
+//   - Binance archive: calc_time,funding_interval_hours,last_funding_rate
+//   - Binance API export: symbol,fundingTime,fundingRate,markPrice
+//   - Headerless: timestamp,rate[,mark_price]
+//
+// Timestamps are Unix milliseconds. Events already stored are kept; the count includes them
+// Rows are saved as they are read, so an import stopped by a *RowError keeps the earlier rows
+func (u *FundingUploader) UploadFundingCSV(filePath string, instrumentID int64) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	cols := fundingColumns{timestamp: 0, rate: 1, markPrice: 2}
+	count := 0
+	lineNum := 0
+
+	for {
+		lineNum++
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("error reading line %d: %w", lineNum, err)
+		}
+
+		if len(record) < 2 {
+			continue
+		}
+
+		// Header row selects the column layout
+		if lineNum == 1 {
+			if _, err := strconv.ParseInt(record[0], 10, 64); err != nil {
+				cols, err = parseFundingHeader(record)
+				if err != nil {
+					return 0, &RowError{RejectedRow{Line: lineNum, Reason: err.Error()}}
+				}
+				continue
+			}
+		}
+
+		rate, err := parseFundingRate(record, cols, instrumentID)
+		if err != nil {
+			return count, &RowError{RejectedRow{Line: lineNum, Reason: err.Error(), Content: rowContent(record, reader.Comma)}}
+		}
+
+		if err := u.db.SaveFundingRate(rate); err != nil {
+			return count, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// parseFundingHeader locates funding columns by header name
+func parseFundingHeader(header []string) (fundingColumns, error) {
+	cols := fundingColumns{timestamp: -1, rate: -1, markPrice: -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case contains(fundingTimestampHeaders, name):
+			cols.timestamp = i
+		case contains(fundingRateHeaders, name):
+			cols.rate = i
+		case contains(fundingMarkHeaders, name):
+			cols.markPrice = i
+		}
+	}
+
+	if cols.timestamp < 0 || cols.rate < 0 {
+		return cols, fmt.Errorf("funding CSV header must contain timestamp and rate columns: %v", header)
+	}
+	return cols, nil
+}
+
+// parseFundingRate parses a CSV record into a FundingRate model
+func parseFundingRate(record []string, cols fundingColumns, instrumentID int64) (*models.FundingRate, error) {
+	if cols.timestamp >= len(record) || cols.rate >= len(record) {
+		return nil, fmt.Errorf("expected at least %d fields, got %d", max(cols.timestamp, cols.rate)+1, len(record))
+	}
+
+	timestamp, err := strconv.ParseInt(record[cols.timestamp], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	rate, err := strconv.ParseFloat(record[cols.rate], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid funding rate: %w", err)
+	}
+
+	funding := &models.FundingRate{
+		InstrumentID: instrumentID,
+		Timestamp:    timestamp,
+		Rate:         rate,
+	}
+
+	if cols.markPrice >= 0 && cols.markPrice < len(record) && record[cols.markPrice] != "" {
+		funding.MarkPrice, err = strconv.ParseFloat(record[cols.markPrice], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mark price: %w", err)
+		}
+	}
+
+	return funding, nil
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
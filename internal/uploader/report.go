@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRejected is the number of rejected rows listed in a report
+const DefaultMaxRejected = 1000
+
+// maxRejectedContent truncates the raw content kept for a rejected row
+const maxRejectedContent = 200
+
+// IngestReport summarizes a candle import
+type IngestReport struct {
+	Rows       int           `json:"rows"`       // Valid candles read from the file
+	Inserted   int           `json:"inserted"`   // Candles that were new to the store
+	Duplicates int           `json:"duplicates"` // Candles already stored (or repeated in the file)
+	Invalid    int           `json:"invalid"`    // Rows rejected by parsing
+	Repaired   int           `json:"repaired"`   // Candles created to fill gaps (counted in inserted/duplicates too)
+	Batches    int           `json:"batches"`    // Committed batches
+	Duration   time.Duration `json:"duration_ns"`
+
+	// Bytes of the file read so far and its size, for files uploaded with UploadFile
+	BytesRead  int64 `json:"bytes_read,omitempty"`
+	BytesTotal int64 `json:"bytes_total,omitempty"`
+
+	// First rejected rows in file order (at most MaxRejected)
+	Rejected []RejectedRow `json:"rejected,omitempty"`
+
+	// Whether more rows were rejected than listed
+	RejectedTruncated bool `json:"rejected_truncated,omitempty"`
+}
+
+// RejectedRow describes a row that could not be imported
+type RejectedRow struct {
+	Line    int    `json:"line"` // File line for CSV, row number from 1 for Parquet and Arrow
+	Reason  string `json:"reason"`
+	Content string `json:"content,omitempty"`
+}
+
+// RowError is returned by strict imports for the first rejected row
+type RowError struct {
+	RejectedRow
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// RowsPerSecond returns the import throughput
+func (r IngestReport) RowsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Rows+r.Invalid) / r.Duration.Seconds()
+}
+
+// Completion returns the fraction of the file read, or 0 if its size is unknown
+func (r IngestReport) Completion() float64 {
+	if r.BytesTotal <= 0 {
+		return 0
+	}
+	return min(1, float64(r.BytesRead)/float64(r.BytesTotal))
+}
+
+// reject counts a rejected row and lists it while under limit
+func (r *IngestReport) reject(row RejectedRow, limit int) {
+	r.Invalid++
+	if len(r.Rejected) < limit {
+		r.Rejected = append(r.Rejected, row)
+	} else {
+		r.RejectedTruncated = true
+	}
+}
+
+// add accumulates the counts and rejected rows of another import
+func (r *IngestReport) add(other *IngestReport) {
+	r.Rows += other.Rows
+	r.Inserted += other.Inserted
+	r.Duplicates += other.Duplicates
+	r.Invalid += other.Invalid
+	r.Repaired += other.Repaired
+	r.Batches += other.Batches
+	r.Duration += other.Duration
+	r.Rejected = append(r.Rejected, other.Rejected...)
+	r.RejectedTruncated = r.RejectedTruncated || other.RejectedTruncated
+	if other.BytesTotal > 0 {
+		// Entries of one archive share the byte count of the archive
+		r.BytesRead, r.BytesTotal = other.BytesRead, other.BytesTotal
+	}
+}
+
+// Summary returns a one-line description of the report
+func (r IngestReport) Summary() string {
+	repaired := ""
+	if r.Repaired > 0 {
+		repaired = fmt.Sprintf(", %d gap-filled", r.Repaired)
+	}
+	return fmt.Sprintf("%d rows: %d inserted, %d duplicate, %d invalid%s in %s (%.0f rows/s)",
+		r.Rows+r.Invalid, r.Inserted, r.Duplicates, r.Invalid, repaired, r.Duration.Round(time.Millisecond), r.RowsPerSecond())
+}
+
+// rowContent joins a record back into a line for the report
+func rowContent(record []string, delimiter rune) string {
+	content := strings.Join(record, string(delimiter))
+	if len(content) > maxRejectedContent {
+		content = content[:maxRejectedContent] + "..."
+	}
+	return content
+}
@@ -22,7 +22,8 @@ async function apiCall(endpoint, options = {}) {
         });
         
         if (!response.ok) {
-            throw new Error(`API Error: ${response.statusText}`);
+            const body = await response.json().catch(() => ({}));
+            throw new Error(body.error || `API Error: ${response.statusText}`);
         }
         
         return await response.json();
@@ -62,11 +63,11 @@ function showNotification(message, type = 'info') {
 // Tab Navigation
 function initTabs() {
     const tabs = document.querySelectorAll('.tab');
-    const sections = document.querySelectorAll('.section');
+    const sections = document.querySelectorAll('.tab-content');
     
     tabs.forEach(tab => {
         tab.addEventListener('click', () => {
-            const targetSection = tab.dataset.section;
+            const targetSection = tab.dataset.tab;
             
             tabs.forEach(t => t.classList.remove('active'));
             sections.forEach(s => s.classList.remove('active'));
@@ -101,10 +102,11 @@ async function loadSectionData(section) {
 // Dashboard
 async function loadDashboard() {
     try {
-        const [strategies, backtests] = await Promise.all([
-            apiCall('/strategies'),
-            apiCall('/backtests')
+        const [strategies, page] = await Promise.all([
+            loadLookups(),
+            apiCall('/backtests?limit=500')
         ]);
+        const backtests = page.backtests;
         
         const dashboardHTML = `
             <div class="stats-grid">
@@ -113,16 +115,16 @@ async function loadDashboard() {
                     <div class="stat-label">Active Strategies</div>
                 </div>
                 <div class="stat-card">
-                    <div class="stat-value">${backtests.length}</div>
+                    <div class="stat-value">${backtests.length}${page.next_cursor ? '+' : ''}</div>
                     <div class="stat-label">Total Backtests</div>
                 </div>
                 <div class="stat-card">
-                    <div class="stat-value">${backtests.filter(b => b.status === 'completed').length}</div>
+                    <div class="stat-value">${backtests.filter(b => b.status === 'DONE').length}</div>
                     <div class="stat-label">Completed Tests</div>
                 </div>
                 <div class="stat-card">
-                    <div class="stat-value">${backtests.filter(b => b.status === 'running').length}</div>
-                    <div class="stat-label">Running Tests</div>
+                    <div class="stat-value">${backtests.filter(b => b.status === 'FAILED').length}</div>
+                    <div class="stat-label">Failed Tests</div>
                 </div>
             </div>
             
@@ -142,8 +144,8 @@ async function loadDashboard() {
                     <tbody>
                         ${backtests.slice(0, 10).map(bt => `
                             <tr>
-                                <td>${bt.strategy_name}</td>
-                                <td>${bt.symbol}</td>
+                                <td>${strategyName(bt.strategy_id)}</td>
+                                <td>${instrumentSymbol(bt.instrument_id)}</td>
                                 <td>${formatDate(bt.start_time)} - ${formatDate(bt.end_time)}</td>
                                 <td><span class="status-badge ${bt.status}">${bt.status}</span></td>
                                 <td class="${bt.total_pnl >= 0 ? 'positive' : 'negative'}">
@@ -182,7 +184,7 @@ async function loadStrategies() {
                     <thead>
                         <tr>
                             <th>Name</th>
-                            <th>Type</th>
+                            <th>Version</th>
                             <th>Parameters</th>
                             <th>Created</th>
                             <th>Actions</th>
@@ -192,7 +194,7 @@ async function loadStrategies() {
                         ${strategies.map(s => `
                             <tr>
                                 <td><strong>${s.name}</strong></td>
-                                <td>${s.strategy_type}</td>
+                                <td>v${s.version}</td>
                                 <td><code>${JSON.stringify(s.config).substring(0, 50)}...</code></td>
                                 <td>${formatDate(s.created_at)}</td>
                                 <td>
@@ -215,7 +217,8 @@ async function loadStrategies() {
 // Backtests
 async function loadBacktests() {
     try {
-        const backtests = await apiCall('/backtests');
+        await loadLookups();
+        const backtests = (await apiCall('/backtests')).backtests;
         state.backtests = backtests;
         
         const backtestsHTML = `
@@ -242,8 +245,8 @@ async function loadBacktests() {
                         ${backtests.map(bt => `
                             <tr>
                                 <td>#${bt.id}</td>
-                                <td>${bt.strategy_name}</td>
-                                <td>${bt.symbol}</td>
+                                <td>${strategyName(bt.strategy_id)}</td>
+                                <td>${instrumentSymbol(bt.instrument_id)}</td>
                                 <td>
                                     ${formatDate(bt.start_time)}<br/>
                                     <small>to ${formatDate(bt.end_time)}</small>
@@ -252,7 +255,7 @@ async function loadBacktests() {
                                 <td class="${bt.total_pnl >= 0 ? 'positive' : 'negative'}">
                                     ${formatNumber(bt.total_pnl)}
                                 </td>
-                                <td>${(bt.win_rate * 100).toFixed(2)}%</td>
+                                <td>${formatNumber(bt.win_rate)}%</td>
                                 <td>
                                     <button onclick="viewBacktest(${bt.id})" class="btn-icon">View</button>
                                     <button onclick="downloadReport(${bt.id})" class="btn-icon">Export</button>
@@ -273,7 +276,7 @@ async function loadBacktests() {
 // Instruments
 async function loadInstruments() {
     try {
-        const instruments = await apiCall('/instruments');
+        const instruments = (await apiCall('/instruments')) || [];
         state.instruments = instruments;
         
         const instrumentsHTML = `
@@ -287,27 +290,20 @@ async function loadInstruments() {
                     <thead>
                         <tr>
                             <th>Symbol</th>
-                            <th>Name</th>
-                            <th>Type</th>
-                            <th>Min Price</th>
-                            <th>Max Price</th>
-                            <th>Tick Size</th>
-                            <th>Actions</th>
+                            <th>Exchange</th>
+                            <th>Kind</th>
+                            <th>Volatility</th>
+                            <th>Created</th>
                         </tr>
                     </thead>
                     <tbody>
                         ${instruments.map(i => `
                             <tr>
                                 <td><strong>${i.symbol}</strong></td>
-                                <td>${i.name}</td>
-                                <td>${i.instrument_type}</td>
-                                <td>${formatNumber(i.min_price)}</td>
-                                <td>${formatNumber(i.max_price)}</td>
-                                <td>${formatNumber(i.tick_size)}</td>
-                                <td>
-                                    <button onclick="editInstrument('${i.symbol}')" class="btn-icon">Edit</button>
-                                    <button onclick="deleteInstrument('${i.symbol}')" class="btn-icon btn-danger">Delete</button>
-                                </td>
+                                <td>${i.exchange}</td>
+                                <td>${i.kind || ''}</td>
+                                <td>${i.vol_bucket || ''}</td>
+                                <td>${formatDate(i.created_at)}</td>
                             </tr>
                         `).join('')}
                     </tbody>
@@ -321,26 +317,171 @@ async function loadInstruments() {
     }
 }
 
+// Lookups for the IDs backtests refer to
+async function loadLookups() {
+    const [strategies, instruments] = await Promise.all([
+        apiCall('/strategies'),
+        apiCall('/instruments')
+    ]);
+    state.strategies = strategies;
+    state.instruments = instruments || [];
+    return strategies;
+}
+
+function strategyName(id) {
+    const strategy = state.strategies.find(s => s.id === id);
+    return strategy ? strategy.name : `#${id}`;
+}
+
+function instrumentSymbol(id) {
+    const instrument = state.instruments.find(i => i.id === id);
+    return instrument ? instrument.symbol : `#${id}`;
+}
+
+// Modals
+function showStrategyModal(strategy = null) {
+    openModal(`
+        <h2>${strategy ? 'Edit Strategy' : 'New Strategy'}</h2>
+        <form class="form" onsubmit="event.preventDefault(); createStrategy(new FormData(this))">
+            <input type="hidden" name="id" value="${strategy ? strategy.id : ''}">
+            <div class="form-group">
+                <label>Name</label>
+                <input name="name" required value="${strategy ? escapeHTML(strategy.name) : ''}">
+            </div>
+            <div class="form-group">
+                <label>Config (JSON)</label>
+                <textarea name="config" rows="16" required>${strategy ? escapeHTML(JSON.stringify(strategy.config, null, 2)) : '{}'}</textarea>
+            </div>
+            <button type="submit" class="btn-primary">Save</button>
+        </form>
+    `);
+}
+
+function showCreateStrategyModal() {
+    showStrategyModal();
+}
+
+async function editStrategy(id) {
+    showStrategyModal(await apiCall(`/strategies/${id}`));
+}
+
+async function showRunBacktestModal() {
+    await loadLookups();
+    openModal(`
+        <h2>Run Backtest</h2>
+        <form class="form" onsubmit="event.preventDefault(); runBacktest(new FormData(this))">
+            <div class="form-group">
+                <label>Strategy</label>
+                <select name="strategy_name" required>
+                    ${state.strategies.map(s => `<option value="${escapeHTML(s.name)}">${escapeHTML(s.name)} (v${s.version})</option>`).join('')}
+                </select>
+            </div>
+            <div class="form-group">
+                <label>Instrument</label>
+                <select name="instrument_id" required>
+                    ${state.instruments.map(i => `<option value="${i.id}">${i.symbol} (${i.exchange})</option>`).join('')}
+                </select>
+            </div>
+            <div class="form-group">
+                <label>Interval</label>
+                <input name="interval" value="1h" required>
+            </div>
+            <div class="form-row">
+                <div class="form-group">
+                    <label>Start Date</label>
+                    <input type="date" name="start_date" required>
+                </div>
+                <div class="form-group">
+                    <label>End Date</label>
+                    <input type="date" name="end_date" required>
+                </div>
+            </div>
+            <button type="submit" class="btn-primary">Run Backtest</button>
+        </form>
+    `);
+}
+
+function showAddInstrumentModal() {
+    openModal(`
+        <h2>Add Instrument</h2>
+        <form class="form" onsubmit="event.preventDefault(); createInstrument(new FormData(this))">
+            <div class="form-group">
+                <label>Symbol</label>
+                <input name="symbol" placeholder="BTCUSDT" required>
+            </div>
+            <div class="form-group">
+                <label>Exchange</label>
+                <input name="exchange" value="BINANCE" required>
+            </div>
+            <button type="submit" class="btn-primary">Add Instrument</button>
+        </form>
+    `);
+}
+
+async function viewBacktest(id) {
+    const bt = await apiCall(`/backtests/${id}`);
+    const metrics = Object.entries(bt.metrics || {});
+    openModal(`
+        <h2>Backtest #${bt.id}</h2>
+        <p>${strategyName(bt.strategy_id)} v${bt.strategy_version || '?'} on ${instrumentSymbol(bt.instrument_id)} ${bt.interval || ''},
+           ${formatDate(bt.start_time)} - ${formatDate(bt.end_time)}</p>
+        ${bt.error ? `<p class="negative">${escapeHTML(bt.error)}</p>` : ''}
+        <div class="table-container">
+            <table>
+                <tbody>
+                    <tr><td>Status</td><td><span class="status-badge ${bt.status}">${bt.status}</span></td></tr>
+                    <tr><td>Trades</td><td>${bt.total_trades} (${bt.winning_trades} won, ${bt.losing_trades} lost)</td></tr>
+                    <tr><td>Win Rate</td><td>${formatNumber(bt.win_rate)}%</td></tr>
+                    <tr><td>Total P&L</td><td>${formatNumber(bt.total_pnl)}</td></tr>
+                    <tr><td>Total Return</td><td>${formatNumber(bt.total_return)}%</td></tr>
+                    ${metrics.map(([name, value]) => `<tr><td>${name}</td><td>${formatNumber(value)}</td></tr>`).join('')}
+                </tbody>
+            </table>
+        </div>
+    `);
+}
+
+function downloadReport(id) {
+    window.location.href = `${API_BASE}/backtests/${id}/trades?format=csv`;
+}
+
 // CRUD Operations
 async function createStrategy(formData) {
     try {
+        const id = formData.get('id');
         const data = {
             name: formData.get('name'),
-            strategy_type: formData.get('strategy_type'),
-            config: JSON.parse(formData.get('config')),
-            description: formData.get('description')
+            config: JSON.parse(formData.get('config'))
         };
         
-        await apiCall('/strategies', {
-            method: 'POST',
+        await apiCall(id ? `/strategies/${id}` : '/strategies', {
+            method: id ? 'PUT' : 'POST',
             body: JSON.stringify(data)
         });
         
-        showNotification('Strategy created successfully', 'success');
-        closeModal('strategyModal');
+        showNotification(id ? 'Strategy updated successfully' : 'Strategy created successfully', 'success');
+        closeModal();
         await loadStrategies();
     } catch (error) {
-        showNotification('Failed to create strategy', 'error');
+        showNotification('Failed to save strategy', 'error');
+    }
+}
+
+async function createInstrument(formData) {
+    try {
+        await apiCall('/instruments', {
+            method: 'POST',
+            body: JSON.stringify({
+                symbol: formData.get('symbol'),
+                exchange: formData.get('exchange')
+            })
+        });
+        
+        showNotification('Instrument added successfully', 'success');
+        closeModal();
+        await loadInstruments();
+    } catch (error) {
+        showNotification('Failed to add instrument', 'error');
     }
 }
 
@@ -359,20 +500,20 @@ async function deleteStrategy(id) {
 async function runBacktest(formData) {
     try {
         const data = {
-            strategy_id: parseInt(formData.get('strategy_id')),
-            symbol: formData.get('symbol'),
-            start_time: new Date(formData.get('start_time')).toISOString(),
-            end_time: new Date(formData.get('end_time')).toISOString(),
-            initial_balance: parseFloat(formData.get('initial_balance'))
+            strategy_name: formData.get('strategy_name'),
+            instrument_id: parseInt(formData.get('instrument_id')),
+            interval: formData.get('interval'),
+            start_date: formData.get('start_date'),
+            end_date: formData.get('end_date')
         };
         
-        await apiCall('/backtests', {
+        const result = await apiCall('/backtests', {
             method: 'POST',
             body: JSON.stringify(data)
         });
         
-        showNotification('Backtest started successfully', 'success');
-        closeModal('backtestModal');
+        showNotification(`Backtest #${result.id} finished`, 'success');
+        closeModal();
         await loadBacktests();
     } catch (error) {
         showNotification('Failed to start backtest', 'error');
@@ -380,9 +521,10 @@ async function runBacktest(formData) {
 }
 
 // Utility Functions
-function formatDate(dateString) {
-    if (!dateString) return 'N/A';
-    const date = new Date(dateString);
+function formatDate(value) {
+    if (!value) return 'N/A';
+    // Strategies and instruments carry Unix seconds, backtests RFC 3339 times
+    const date = typeof value === 'number' ? new Date(value * 1000) : new Date(value);
     return date.toLocaleDateString('en-US', { year: 'numeric', month: 'short', day: 'numeric' });
 }
 
@@ -394,9 +536,20 @@ function formatNumber(num) {
     }).format(num);
 }
 
-function closeModal(modalId) {
-    const modal = document.getElementById(modalId);
-    if (modal) modal.remove();
+function escapeHTML(text) {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+function openModal(html) {
+    document.getElementById('modal-body').innerHTML = html;
+    document.getElementById('modal').classList.add('active');
+}
+
+function closeModal() {
+    document.getElementById('modal').classList.remove('active');
+    document.getElementById('modal-body').innerHTML = '';
 }
 
 // Initialize app
@@ -0,0 +1,19 @@
+// Package web holds the static files served by the API server
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed admin
+var files embed.FS
+
+// Admin returns the admin UI files: index.html, app.js and styles.css
+func Admin() fs.FS {
+	admin, err := fs.Sub(files, "admin")
+	if err != nil {
+		panic(err) // The directory is embedded above
+	}
+	return admin
+}